@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -139,6 +146,464 @@ func TestHandleGraphCommand_Basic(t *testing.T) {
 	}
 }
 
+func TestHandleGraphCommand_AuthoredVsReviewed(t *testing.T) {
+	resetFlags()
+	withReviews = true
+	since = time.Now().AddDate(0, 0, -7).Format(dateFormat)
+	createdAt := time.Now().AddDate(0, 0, -2).Format(time.RFC3339)
+
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			switch {
+			case strings.Contains(path, "reviewed-by%3A"):
+				for i := 0; i < 3; i++ {
+					items = append(items, GitHubItem{Number: 300 + i, HTMLURL: fmt.Sprintf("http://example.com/pr/%d", 300+i), State: "closed", CreatedAt: createdAt})
+				}
+			case strings.Contains(path, "is%3Apr"):
+				items = []GitHubItem{{Number: 101, HTMLURL: "http://example.com/pr/101", State: "open", CreatedAt: createdAt}}
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	stdout, _ := captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+
+	expected := "Authored: 1 (25%)\nReviewed: 3 (75%)\n"
+	if !strings.Contains(stdout, expected) {
+		t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout)
+	}
+
+	withReviews = false
+	stdout, _ = captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+	if strings.Contains(stdout, "Authored:") || strings.Contains(stdout, "Reviewed:") {
+		t.Errorf("Expected no authored vs. reviewed split without --with-reviews, got:\n%s", stdout)
+	}
+}
+
+func TestHandleGraphCommand_Detailed(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	since = time.Now().AddDate(0, 0, -13).Format(dateFormat)
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			switch {
+			case strings.Contains(path, "reviewed-by%3A"):
+			case strings.Contains(path, "is%3Apr"):
+				items = []GitHubItem{{Number: 1, Title: "Fix login", HTMLURL: "http://example.com/pr/1", State: "closed", CreatedAt: time.Now().AddDate(0, 0, -12).Format(time.RFC3339), ClosedAt: time.Now().AddDate(0, 0, -11).Format(time.RFC3339)}}
+			case strings.Contains(path, "is%3Aissue"):
+				items = []GitHubItem{{Number: 2, Title: "Crash on start", HTMLURL: "http://example.com/issue/2", State: "open", CreatedAt: time.Now().AddDate(0, 0, -2).Format(time.RFC3339)}}
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		},
+	}
+	graph := func() string {
+		stdout, _ := captureOutput(func() {
+			handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+		})
+		return stdout
+	}
+
+	if stdout := graph(); strings.Contains(stdout, "Fix login") {
+		t.Errorf("Expected no item list by default, got:\n%s", stdout)
+	}
+
+	detailedFlag = true
+	stdout := graph()
+	week1, week2 := strings.Index(stdout, "Week  1"), strings.Index(stdout, "Week  2")
+	pr, issue := strings.Index(stdout, "\n    • Fix login http://example.com/pr/1\n"), strings.Index(stdout, "\n    □ Crash on start http://example.com/issue/2\n")
+	if week1 < 0 || week2 < 0 || !(week1 < pr && pr < week2 && week2 < issue) {
+		t.Errorf("Expected each item listed under its week's bar, got:\n%s", stdout)
+	}
+}
+
+func TestHandleGraphCommand_MinWeekCount(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	since = time.Now().AddDate(0, 0, -20).Format(dateFormat)
+	daysAgo := func(days int) string { return time.Now().AddDate(0, 0, -days).Format(time.RFC3339) }
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			switch {
+			case strings.Contains(path, "reviewed-by%3A"):
+			case strings.Contains(path, "is%3Apr"):
+				items = []GitHubItem{{Number: 1, State: "open", CreatedAt: daysAgo(19)}}
+			case strings.Contains(path, "is%3Aissue"):
+				items = []GitHubItem{{Number: 2, State: "open", CreatedAt: daysAgo(2)}, {Number: 3, State: "open", CreatedAt: daysAgo(2)}}
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	minWeekCount = 2
+	stdout, _ := captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+	if strings.Contains(stdout, "Week  1") || strings.Contains(stdout, "Week  2") || !strings.Contains(stdout, "Week  3") {
+		t.Errorf("Expected only the week with 2 contributions, got:\n%s", stdout)
+	}
+	for _, want := range []string{"(2 of 3 weeks with fewer than 2 contributions hidden; totals include them)", "Total Contributions: 3"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestHandleGraphCommand_WeightReactions(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	since = time.Now().AddDate(0, 0, -6).Format(dateFormat)
+	created := time.Now().AddDate(0, 0, -2).Format(time.RFC3339)
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			switch {
+			case strings.HasPrefix(path, "repos/octo/repo/issues/1/reactions"):
+				return json.Unmarshal([]byte(`[{"content":"+1"},{"content":"heart"},{"content":"rocket"}]`), response)
+			case strings.HasPrefix(path, "repos/octo/repo/issues/2/reactions"):
+				return json.Unmarshal([]byte("[]"), response)
+			case strings.Contains(path, "reviewed-by%3A"):
+			case strings.Contains(path, "is%3Apr"):
+				items = []GitHubItem{
+					{Number: 1, HTMLURL: "https://github.com/octo/repo/pull/1", State: "open", CreatedAt: created},
+					{Number: 2, HTMLURL: "https://github.com/octo/repo/pull/2", State: "open", CreatedAt: created},
+				}
+			case strings.Contains(path, "is%3Aissue"):
+			default:
+				return fmt.Errorf("unexpected API call: %s", path)
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	graphWeight = "reactions"
+	stdout, _ := captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+
+	// Two PRs, but three reactions between them
+	if !strings.Contains(stdout, ": ○○○ 3\n") {
+		t.Errorf("Expected a bar of 3 reactions, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "Weighted by reactions") {
+		t.Errorf("Expected a note on the weighting, got:\n%s", stdout)
+	}
+}
+
+func TestWeightedGraphLabels(t *testing.T) {
+	resetFlags()
+	graphWeight = "reactions"
+
+	for _, tt := range []struct{ count, maxCount, want int }{
+		{3, 10, 3},     // Fits as-is
+		{100, 200, 25}, // Scaled to the busiest bar
+		{1, 200, 1},    // Never drawn as empty
+		{0, 200, 0},
+	} {
+		if got := weightedBarWidth(tt.count, tt.maxCount); got != tt.want {
+			t.Errorf("weightedBarWidth(%d, %d) = %d, want %d", tt.count, tt.maxCount, got, tt.want)
+		}
+	}
+
+	start := time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC)
+	weeks := []string{"Week  1"}
+	weekStartDates := map[string]time.Time{"Week  1": start}
+	counts := map[string]map[contributionType]int{"Week  1": {{"pr", "open"}: 7}}
+
+	var buf bytes.Buffer
+	writeGraphSVG(&buf, weeks, weekStartDates, counts, 640, 320)
+	if !strings.Contains(buf.String(), ">Weighted by reactions</text>") {
+		t.Errorf("Expected the SVG to be labeled as weighted, got:\n%s", buf.String())
+	}
+
+	stdout, _ := captureOutput(func() { writeGraphPivot(weeks, weekStartDates, counts) })
+	if !strings.HasPrefix(stdout, "Week,ClosedPR Reactions,OpenPR Reactions,") || !strings.Contains(stdout, ",Total Reactions\n") {
+		t.Errorf("Expected reaction column headers, got:\n%s", stdout)
+	}
+}
+
+func TestGraphTypes(t *testing.T) {
+	resetFlags()
+	weeks := []string{"w1", "w2", "w3", "w4"}
+	weekMap := map[string]int{"w1": 2, "w2": 0, "w3": 4, "w4": 1}
+
+	stdout, _ := captureOutput(func() { printWeekLine(weeks, weekMap) })
+	want := "4 |       *\n" +
+		"  |       |  |\n" +
+		"2 | *     |  |\n" +
+		"1 |    |  |  *\n" +
+		"0 +-------------\n" +
+		"    1  2  3  4  (week)\n"
+	if stdout != want {
+		t.Errorf("Unexpected line chart:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	for _, tt := range []struct {
+		count int
+		want  string
+	}{{0, "0"}, {1, "· 1"}, {4, "· 4"}, {5, "• 5"}, {9, "● 9"}, {16, "⬤ 16"}} {
+		if got := weekDot(tt.count, 16); got != tt.want {
+			t.Errorf("weekDot(%d, 16) = %q, want %q", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestWriteGraphSVG(t *testing.T) {
+	resetFlags()
+	start := time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC)
+	weeks := []string{"Week  1", "Week  2"}
+	weekStartDates := map[string]time.Time{"Week  1": start, "Week  2": start.AddDate(0, 0, 7)}
+	counts := map[string]map[contributionType]int{
+		"Week  1": {{"pr", "closed"}: 2, {"issue", "open"}: 1},
+		"Week  2": {{"pr", "closed"}: 1},
+	}
+
+	var buf bytes.Buffer
+	writeGraphSVG(&buf, weeks, weekStartDates, counts, 640, 320)
+	svg := buf.String()
+
+	// Well-formed: every token decodes and the root is an <svg> element
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	var root string
+	rects := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("SVG is not well-formed: %v\n%s", err, svg)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if root == "" {
+				root = start.Name.Local
+			}
+			if start.Name.Local == "rect" {
+				rects++
+			}
+		}
+	}
+	if root != "svg" {
+		t.Errorf("Expected an <svg> root element, got %q", root)
+	}
+	// Background, two legend swatches, and three bar segments
+	if rects != 6 {
+		t.Errorf("Expected 6 rects, got %d:\n%s", rects, svg)
+	}
+	for _, want := range []string{`width="640" height="320"`, ">Closed PR<", ">Open Issue<", ">Apr 15<", ">Apr 22<", ">3<"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("Expected %q in SVG, got:\n%s", want, svg)
+		}
+	}
+	if strings.Contains(svg, "Closed Review") {
+		t.Errorf("Expected the legend to omit absent series, got:\n%s", svg)
+	}
+}
+
+func TestHandleGraphCommand_ClosedSince(t *testing.T) {
+	daysAgo := func(days int) string { return time.Now().AddDate(0, 0, -days).Format(time.RFC3339) }
+	// Created long before the window, closed inside it
+	oldPR := GitHubItem{Number: 1, Title: "Long-running PR", HTMLURL: "http://example.com/pr/1", State: "closed", CreatedAt: daysAgo(90), ClosedAt: daysAgo(3)}
+
+	for _, tt := range []struct {
+		name       string
+		dateField  string
+		qualifier  string
+		wantOldPR  bool
+		wantWeekPR string
+	}{
+		{"Created", "created", "created%3A%3E", false, ""},
+		{"Closed", "closed", "closed%3A%3E", true, "Week  2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags()
+			defer resetFlags()
+			since = time.Now().AddDate(0, 0, -13).Format(dateFormat)
+			dateField = tt.dateField
+			detailedFlag = true
+			mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+				var items []GitHubItem
+				// Simulate GitHub: the old PR only matches a closed: window
+				if strings.Contains(path, "author%3A") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "closed%3A%3E") {
+					items = []GitHubItem{oldPR}
+				}
+				data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+				return json.Unmarshal(data, response)
+			}}
+
+			stdout, _ := captureOutput(func() {
+				handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+			})
+			for _, query := range mockClient.GetCalls {
+				if strings.HasPrefix(query, "search/") && !strings.Contains(query, tt.qualifier) {
+					t.Errorf("Expected every search to use %s, got %s", tt.qualifier, query)
+				}
+			}
+			if got := strings.Contains(stdout, "Long-running PR"); got != tt.wantOldPR {
+				t.Fatalf("Expected old PR shown = %v, got:\n%s", tt.wantOldPR, stdout)
+			}
+			if tt.wantOldPR {
+				week, item := strings.Index(stdout, tt.wantWeekPR), strings.Index(stdout, "Long-running PR")
+				if week < 0 || item < week {
+					t.Errorf("Expected the PR under %s, when it closed, got:\n%s", tt.wantWeekPR, stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintCalendar(t *testing.T) {
+	resetFlags()
+	location = time.UTC
+
+	sinceDate, _ := time.ParseInLocation(dateFormat, "2025-04-16", location) // A Wednesday
+	today, _ := time.ParseInLocation(dateFormat, "2025-04-29", location)
+	items := []GitHubItem{
+		{CreatedAt: "2025-04-15T09:00:00Z", ClosedAt: "2025-04-16T10:00:00Z"},
+		{CreatedAt: "2025-04-16T12:00:00Z"},
+		{CreatedAt: "2025-04-22T08:00:00Z"},
+	}
+
+	stdout, _ := captureOutput(func() {
+		printCalendar(items, sinceDate, today)
+	})
+
+	expected := "Sun   · ·\n" +
+		"Mon   · ·\n" +
+		"Tue   ▒ ·\n" +
+		"Wed █ ·\n" +
+		"Thu · ·\n" +
+		"Fri · ·\n" +
+		"Sat · ·\n" +
+		"\nLess · ░ ▒ ▓ █ More\n\n"
+	if stdout != expected {
+		t.Errorf("Expected calendar:\n%s\ngot:\n%s", expected, stdout)
+	}
+
+	asciiFlag = true
+	stdout, _ = captureOutput(func() {
+		printCalendar(items, sinceDate, today)
+	})
+	if !strings.HasPrefix(stdout, "Sun   . .\n") || !strings.Contains(stdout, "Wed # .\n") {
+		t.Errorf("Expected ASCII shading, got:\n%s", stdout)
+	}
+}
+
+func TestProcessItems_DateField(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	sinceDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []GitHubItem{{CreatedAt: "2025-01-02T00:00:00Z", UpdatedAt: "2025-01-20T00:00:00Z"}}
+
+	weekFor := func() string {
+		weekMap := make(map[string]int)
+		processItems(items, sinceDate, weekMap, make(map[string]time.Time))
+		for key := range weekMap {
+			return key
+		}
+		return ""
+	}
+
+	if week := weekFor(); !strings.HasPrefix(week, "Week  1 ") {
+		t.Errorf("Expected the created week by default, got %q", week)
+	}
+	dateField = "updated"
+	if week := weekFor(); !strings.HasPrefix(week, "Week  3 ") {
+		t.Errorf("Expected the updated week with --date-field updated, got %q", week)
+	}
+	if date, _ := contributionDate(items[0]); date.UTC().Day() != 20 {
+		t.Errorf("Expected the calendar to use updated_at, got %v", date)
+	}
+}
+
+func TestParseGranularity(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"week", 1, false},
+		{"1w", 1, false},
+		{"2w", 2, false},
+		{"0w", 0, true},
+		{"-1w", 0, true},
+		{"2", 0, true},
+		{"w", 0, true},
+		{"month", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseGranularity(tt.value)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseGranularity(%q) = %d, %v; want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGraphBucket_Sprints(t *testing.T) {
+	resetFlags()
+	location = time.UTC
+	until = "2025-05-10"
+	weeksPerBucket = 2
+	sinceDate := time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC)
+
+	if key, start := graphBucket(0, sinceDate); key != "Sprint  1 (Apr 15 - Apr 28)" || !start.Equal(sinceDate) {
+		t.Errorf("Unexpected first sprint: %q starting %v", key, start)
+	}
+	// The last sprint ends with the window
+	if key, _ := graphBucket(1, sinceDate); key != "Sprint  2 (Apr 29 - May 10)" {
+		t.Errorf("Unexpected last sprint: %q", key)
+	}
+	item := GitHubItem{State: "closed", ClosedAt: "2025-04-27T12:00:00Z"}
+	if key := itemWeekKey(item, sinceDate); key != "Sprint  1 (Apr 15 - Apr 28)" {
+		t.Errorf("Expected an item in the second week to fall in the first sprint, got %q", key)
+	}
+}
+
+func TestPrintWhenAnalysis(t *testing.T) {
+	resetFlags()
+	location = time.UTC
+
+	items := []GitHubItem{
+		{State: "closed", ClosedAt: "2025-04-15T10:05:00Z", PullRequest: &pullRequestRef{MergedAt: "2025-04-15T10:00:00Z"}}, // Tue 10h
+		{State: "closed", ClosedAt: "2025-04-16T10:30:00Z"},                                                                 // Wed 10h
+		{State: "closed", ClosedAt: "2025-04-15T22:00:00Z"},                                                                 // Tue 22h
+		{State: "open", CreatedAt: "2025-04-17T09:00:00Z"},                                                                  // Still open, ignored
+	}
+
+	stdout, _ := captureOutput(func() {
+		printWhenAnalysis(items)
+	})
+
+	full := strings.Repeat("█", whenBarWidth)
+	half := strings.Repeat("█", whenBarWidth/2)
+	expected := "Busiest days:\n" +
+		"  Sun 0\n" +
+		"  Mon 0\n" +
+		"  Tue " + full + " 2\n" +
+		"  Wed " + half + " 1\n" +
+		"  Thu 0\n" +
+		"  Fri 0\n" +
+		"  Sat 0\n" +
+		"Busiest hours:\n" +
+		"  10 " + full + " 2\n" +
+		"  22 " + half + " 1\n\n"
+	if stdout != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
 func TestHandleGraphCommand_NoPRs(t *testing.T) {
 	resetFlags()
 	mockClient := &MockGitHubClient{}
@@ -528,3 +993,498 @@ func TestHandleGraphCommand_WebURL(t *testing.T) {
 		t.Errorf("Expected URL to contain URL-encoded 'author:testuser', but it doesn't.\nOutput:\n%s", stdout)
 	}
 }
+
+func TestHandleGraphCommand_GitHubOutput(t *testing.T) {
+	resetFlags()
+	githubOutput = true
+	mockClient := &MockGitHubClient{}
+	mockGQLClient := &MockGraphQLClient{}
+	testArgs := []string{"graph", "testuser"}
+
+	recent := time.Now().AddDate(0, 0, -1).Format(time.RFC3339)
+	since = time.Now().AddDate(0, 0, -9).Format(dateFormat)
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		var items []GitHubItem
+		if strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3A") {
+			items = []GitHubItem{
+				{Number: 1, HTMLURL: "http://example.com/pr/1", State: "closed", CreatedAt: recent, ClosedAt: recent, PullRequest: &pullRequestRef{MergedAt: recent}},
+				{Number: 2, HTMLURL: "http://example.com/pr/2", State: "closed", CreatedAt: recent, ClosedAt: recent, PullRequest: &pullRequestRef{}},
+				{Number: 3, HTMLURL: "http://example.com/pr/3", State: "open", CreatedAt: recent, PullRequest: &pullRequestRef{}},
+			}
+		} else if strings.Contains(path, "is%3Aissue") {
+			items = []GitHubItem{
+				{Number: 4, HTMLURL: "http://example.com/issue/4", State: "open", CreatedAt: recent},
+			}
+		}
+		resp := GitHubResponse{TotalCount: len(items), Items: items}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	sinceDate, _ := time.Parse(dateFormat, since)
+	daysActive := int(time.Since(sinceDate).Hours()/24) + 1
+	expected := fmt.Sprintf("total_contributions=4\nprs_total=3\nprs_merged=1\nissues_total=1\navg_per_day=%.2f\n", 4/float64(daysActive))
+
+	t.Run("WritesToGitHubOutputFile", func(t *testing.T) {
+		outputPath := filepath.Join(t.TempDir(), "github_output")
+		t.Setenv("GITHUB_OUTPUT", outputPath)
+
+		stdout, stderr := captureOutput(func() {
+			handleGraphCommand(testArgs, mockClient, mockGQLClient)
+		})
+
+		if stderr != "" {
+			t.Errorf("Expected no stderr, got: %s", stderr)
+		}
+		if stdout != "" {
+			t.Errorf("Expected no stdout when $GITHUB_OUTPUT is set, got: %s", stdout)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read output file: %v", err)
+		}
+		if string(data) != expected {
+			t.Errorf("Expected output file:\n%s\nGot:\n%s", expected, string(data))
+		}
+	})
+
+	t.Run("WritesToStdoutWhenUnset", func(t *testing.T) {
+		t.Setenv("GITHUB_OUTPUT", "")
+
+		stdout, _ := captureOutput(func() {
+			handleGraphCommand(testArgs, mockClient, mockGQLClient)
+		})
+
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+	})
+}
+
+func TestHandleGraphCommand_TimezoneBoundary(t *testing.T) {
+	mockGQLClient := &MockGraphQLClient{}
+	testArgs := []string{"graph", "testuser"}
+
+	// 23:30 UTC on Apr 21 is already Apr 22 in Tokyo, so the PR lands in
+	// week 1 in UTC but week 2 in Tokyo.
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			if strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3A") {
+				items = []GitHubItem{
+					{Number: 1, HTMLURL: "http://example.com/pr/1", State: "closed", CreatedAt: "2025-04-20T10:00:00Z", ClosedAt: "2025-04-21T23:30:00Z"},
+				}
+			}
+			resp := GitHubResponse{TotalCount: len(items), Items: items}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	tests := []struct {
+		timezone string
+		wantLine string
+	}{
+		{"UTC", "Week  1 (Apr 15 - Apr 21): •"},
+		{"Asia/Tokyo", "Week  2 (Apr 22 - Apr 28): •"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			resetFlags()
+			since = "2025-04-15"
+			until = "2025-04-28"
+			timezoneFlag = tt.timezone
+			location, _ = time.LoadLocation(tt.timezone)
+
+			stdout, stderr := captureOutput(func() {
+				handleGraphCommand(testArgs, mockClient, mockGQLClient)
+			})
+
+			if stderr != "" {
+				t.Errorf("Expected no stderr, got: %s", stderr)
+			}
+			if !strings.Contains(stdout, tt.wantLine) {
+				t.Errorf("Expected output to contain '%s'.\nOutput:\n%s", tt.wantLine, stdout)
+			}
+		})
+	}
+}
+
+func TestWatchGraph(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	t.Run("RedrawsUntilCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		draws := 0
+		stdout, _ := captureOutput(func() {
+			watchGraph(ctx, time.Millisecond, func() {
+				draws++
+				fmt.Printf("frame %d\n", draws)
+				if draws == 2 {
+					cancel()
+				}
+			})
+		})
+
+		if draws != 2 {
+			t.Errorf("Expected 2 draws, got %d", draws)
+		}
+		if got := strings.Count(stdout, clearScreen); got != 2 {
+			t.Errorf("Expected the screen cleared before each frame, got %d clears", got)
+		}
+		if got := strings.Count(stdout, "Last updated: "); got != 2 {
+			t.Errorf("Expected a timestamp under each frame, got %d in:\n%q", got, stdout)
+		}
+	})
+
+	t.Run("DrawsOnceWhenPiped", func(t *testing.T) {
+		originalTerminalFunc := stdoutIsTerminalFunc
+		defer func() { stdoutIsTerminalFunc = originalTerminalFunc }()
+		stdoutIsTerminalFunc = func() bool { return false }
+		watchInterval = time.Minute
+		since = time.Now().AddDate(0, 0, -7).Format(dateFormat)
+		mockClient := &MockGitHubClient{
+			GetFunc: func(path string, response interface{}) error {
+				data, _ := json.Marshal(GitHubResponse{})
+				return json.Unmarshal(data, response)
+			},
+		}
+
+		stdout, stderr := captureOutput(func() {
+			handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+		})
+
+		if !strings.Contains(stderr, "--watch is ignored when output is piped") {
+			t.Errorf("Expected piped warning, got stderr: %q", stderr)
+		}
+		if strings.Contains(stdout, clearScreen) || strings.Contains(stdout, "Last updated") {
+			t.Errorf("Expected a plain single draw, got:\n%q", stdout)
+		}
+		searches := 0
+		for _, call := range mockClient.GetCalls {
+			if strings.HasPrefix(call, "search/") {
+				searches++
+			}
+		}
+		if searches == 0 {
+			t.Error("Expected the graph to be fetched once")
+		}
+	})
+}
+
+func TestContributionScore(t *testing.T) {
+	originalConfigFunc := scoreWeightsConfigFunc
+	defer func() { scoreWeightsConfigFunc = originalConfigFunc }()
+
+	results := &contributionResults{
+		prItems: []GitHubItem{
+			{State: "closed", PullRequest: &pullRequestRef{MergedAt: "2025-04-20T10:00:00Z"}},
+			{State: "closed", PullRequest: &pullRequestRef{}},
+			{State: "open", PullRequest: &pullRequestRef{}},
+		},
+		reviewItems:     []GitHubItem{{State: "closed"}},
+		issueItems:      []GitHubItem{{State: "closed"}, {State: "open"}},
+		discussionItems: []GitHubItem{{State: "open"}},
+	}
+
+	t.Run("DefaultWeights", func(t *testing.T) {
+		scoreWeightsConfigFunc = func() map[string]float64 { return nil }
+		weights := scoreWeights()
+		score, counts := contributionScore(results, weights)
+		// 3 merged + 0 closed PR + 2 review + 1 closed issue + 1 discussion + 2 × 0.5 open
+		if score != 8 {
+			t.Errorf("Expected score 8, got %v", score)
+		}
+		if counts["open_item"] != 2 || counts["merged_pr"] != 1 || counts["closed_pr"] != 1 {
+			t.Errorf("Unexpected counts: %v", counts)
+		}
+		expected := "3 × merged_pr + 0 × closed_pr + 2 × review + 1 × closed_issue + 1 × discussion + 0.5 × open_item"
+		if formula := scoreFormula(weights); formula != expected {
+			t.Errorf("Expected formula %q, got %q", expected, formula)
+		}
+	})
+
+	t.Run("ConfiguredWeights", func(t *testing.T) {
+		scoreWeightsConfigFunc = func() map[string]float64 {
+			return map[string]float64{"review": 5, "commits": 1}
+		}
+		var weights map[string]float64
+		_, stderr := captureOutput(func() {
+			weights = scoreWeights()
+		})
+		if !strings.Contains(stderr, "unknown score_weights category 'commits'") {
+			t.Errorf("Expected warning about unknown category, got %q", stderr)
+		}
+		if score, _ := contributionScore(results, weights); score != 11 {
+			t.Errorf("Expected score 11, got %v", score)
+		}
+	})
+
+	t.Run("GraphOutput", func(t *testing.T) {
+		scoreWeightsConfigFunc = func() map[string]float64 { return nil }
+		stdout, _ := captureOutput(func() {
+			printContributionScore(results)
+		})
+		if !strings.Contains(stdout, "Contribution Score: 8 (1 merged_pr, 1 closed_pr, 1 review, 1 closed_issue, 1 discussion, 2 open_item)") {
+			t.Errorf("Expected score line, got %q", stdout)
+		}
+		if !strings.Contains(stdout, "Score = 3 × merged_pr") {
+			t.Errorf("Expected formula line, got %q", stdout)
+		}
+	})
+}
+
+func TestWritePrometheusMetrics(t *testing.T) {
+	stats := contributionStats{
+		total:       9,
+		daysActive:  3,
+		avgPerDay:   3,
+		prs:         stateCounts{total: 4, open: 1, closed: 3},
+		mergedPRs:   2,
+		reviews:     stateCounts{total: 2, open: 1, closed: 1},
+		issues:      stateCounts{total: 2, closed: 2},
+		discussions: stateCounts{total: 1, open: 1},
+	}
+
+	var buf bytes.Buffer
+	if err := writePrometheusMetrics(&buf, stats, "octocat", `my "org"`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{
+		`gh_contrib_contributions_total{login="octocat",org="my \"org\""} 9`,
+		`gh_contrib_prs_total{login="octocat",org="my \"org\"",state="open"} 1`,
+		`gh_contrib_prs_total{login="octocat",org="my \"org\"",state="merged"} 2`,
+		`gh_contrib_prs_total{login="octocat",org="my \"org\"",state="closed"} 1`,
+		`gh_contrib_reviews_total{login="octocat",org="my \"org\"",state="open"} 1`,
+		`gh_contrib_issues_total{login="octocat",org="my \"org\"",state="closed"} 2`,
+		`gh_contrib_discussions_total{login="octocat",org="my \"org\"",state="closed"} 0`,
+		`gh_contrib_window_days{login="octocat",org="my \"org\""} 3`,
+		`gh_contrib_contributions_per_day{login="octocat",org="my \"org\""} 3.00`,
+	} {
+		if !strings.Contains(output, want+"\n") {
+			t.Errorf("Expected metric line %s, got:\n%s", want, output)
+		}
+	}
+
+	// Every line is a HELP or TYPE comment or a well-formed sample, and
+	// every sample's metric has a gauge TYPE line before it
+	comment := regexp.MustCompile(`^# (HELP|TYPE) ([a-z_]+) (.+)$`)
+	sample := regexp.MustCompile(`^([a-z_]+)\{(login="[^"\\]*(?:\\.[^"\\]*)*",org="[^"\\]*(?:\\.[^"\\]*)*"(?:,state="(?:open|closed|merged)")?)\} [0-9]+(\.[0-9]+)?$`)
+	typed := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if m := comment.FindStringSubmatch(line); m != nil {
+			if m[1] == "TYPE" {
+				if m[3] != "gauge" {
+					t.Errorf("Expected gauge type, got %q", line)
+				}
+				typed[m[2]] = true
+			}
+			continue
+		}
+		m := sample.FindStringSubmatch(line)
+		if m == nil {
+			t.Errorf("Malformed metric line: %q", line)
+			continue
+		}
+		if !typed[m[1]] {
+			t.Errorf("Sample %q appears before its TYPE line", line)
+		}
+	}
+}
+
+func TestHandleGraphCommand_Pivot(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	pivotFlag = true
+	since = "2025-04-15"
+	until = "2025-04-28"
+
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			switch {
+			case strings.Contains(path, "reviewed-by%3A"):
+				items = []GitHubItem{{Number: 5, HTMLURL: "http://example.com/pr/5", State: "open", CreatedAt: "2025-04-23T10:00:00Z"}}
+			case strings.Contains(path, "is%3Apr"):
+				items = []GitHubItem{
+					{Number: 1, HTMLURL: "http://example.com/pr/1", State: "closed", CreatedAt: "2025-04-15T10:00:00Z", ClosedAt: "2025-04-16T10:00:00Z"},
+					{Number: 2, HTMLURL: "http://example.com/pr/2", State: "open", CreatedAt: "2025-04-17T10:00:00Z"},
+				}
+			case strings.Contains(path, "is%3Aissue"):
+				items = []GitHubItem{
+					{Number: 3, HTMLURL: "http://example.com/issues/3", State: "open", CreatedAt: "2025-04-24T10:00:00Z"},
+					{Number: 4, HTMLURL: "http://example.com/issues/4", State: "closed", CreatedAt: "2025-04-22T10:00:00Z", ClosedAt: "2025-04-25T10:00:00Z"},
+				}
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	stdout, _ := captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+
+	expected := "Week,ClosedPR,OpenPR,ClosedReview,OpenReview,ClosedIssue,OpenIssue,ClosedDiscussion,OpenDiscussion,Total\n" +
+		"2025-04-15,1,1,0,0,0,0,0,0,2\n" +
+		"2025-04-22,0,0,0,1,1,1,0,0,3\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+
+	t.Run("EmptyWindow", func(t *testing.T) {
+		emptyClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+			return json.Unmarshal([]byte(`{"total_count":0,"items":[]}`), response)
+		}}
+		stdout, stderr := captureOutput(func() {
+			handleGraphCommand([]string{"graph", "testuser"}, emptyClient, &MockGraphQLClient{})
+		})
+		expected := "Week,ClosedPR,OpenPR,ClosedReview,OpenReview,ClosedIssue,OpenIssue,ClosedDiscussion,OpenDiscussion,Total\n" +
+			"2025-04-15,0,0,0,0,0,0,0,0,0\n" +
+			"2025-04-22,0,0,0,0,0,0,0,0,0\n"
+		if stdout != expected {
+			t.Errorf("Expected zero rows for an empty window:\n%s\nGot:\n%s", expected, stdout)
+		}
+		if !strings.Contains(stderr, "No contributions found") {
+			t.Errorf("Expected the notice on stderr, got %q", stderr)
+		}
+
+		pivotFlag, outputFormat = false, "svg"
+		stdout, _ = captureOutput(func() {
+			handleGraphCommand([]string{"graph", "testuser"}, emptyClient, &MockGraphQLClient{})
+		})
+		if !strings.HasPrefix(stdout, "<svg") || !strings.HasSuffix(stdout, "</svg>\n") {
+			t.Errorf("Expected an empty SVG chart, got:\n%s", stdout)
+		}
+	})
+}
+
+func TestParseWindows(t *testing.T) {
+	days, err := parseWindows("7d, 30d,90d")
+	if err != nil || fmt.Sprint(days) != "[7 30 90]" {
+		t.Errorf("Expected [7 30 90], got %v (err %v)", days, err)
+	}
+	for _, bad := range []string{"7", "0d", "2w", "7d,,30d"} {
+		if _, err := parseWindows(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestHandleGraphCommand_Windows(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	defer func() { timeNowFunc = time.Now }()
+	timeNowFunc = func() time.Time { return time.Date(2025, 5, 15, 12, 0, 0, 0, time.UTC) }
+	compareWindows = []int{7, 30}
+	since = "2025-04-15" // Set from the widest window in main
+
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			switch {
+			case strings.Contains(path, "reviewed-by%3A"):
+				items = []GitHubItem{{Number: 5, HTMLURL: "http://example.com/pr/5", State: "closed", CreatedAt: "2025-05-09T10:00:00Z"}}
+			case strings.Contains(path, "is%3Apr"):
+				items = []GitHubItem{
+					{Number: 1, HTMLURL: "http://example.com/pr/1", State: "closed", CreatedAt: "2025-05-14T10:00:00Z", PullRequest: &pullRequestRef{MergedAt: "2025-05-14T12:00:00Z"}},
+					{Number: 2, HTMLURL: "http://example.com/pr/2", State: "open", CreatedAt: "2025-04-20T10:00:00Z"},
+				}
+			case strings.Contains(path, "is%3Aissue"):
+				items = []GitHubItem{{Number: 3, HTMLURL: "http://example.com/issues/3", State: "open", CreatedAt: "2025-05-08T10:00:00Z"}}
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	stdout, _ := captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+
+	// The 7-day window starts May 9; the May 8 issue is only in the 30-day one
+	expected := "Metric,7d,30d\n" +
+		"Total,2,4\n" +
+		"Pull Requests,1,2\n" +
+		"Merged PRs,1,1\n" +
+		"Reviews,1,1\n" +
+		"Issues,0,1\n" +
+		"Discussions,0,0\n" +
+		"Avg per Day,0.29,0.13\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+
+	searches := 0
+	for _, call := range mockClient.GetCalls {
+		if strings.Contains(call, "created%3A%3E2025-04-15") {
+			searches++
+		} else if strings.Contains(call, "search/") {
+			t.Errorf("Expected every search to use the widest window, got %s", call)
+		}
+	}
+	if searches == 0 {
+		t.Error("Expected searches since 2025-04-15 for the 30-day window")
+	}
+}
+
+func TestHandleGraphCommand_ComparePrevious(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	defer func() { timeNowFunc = time.Now }()
+	timeNowFunc = func() time.Time { return time.Date(2025, 5, 15, 12, 0, 0, 0, time.UTC) }
+	comparePrevious = true
+	since = "2025-05-01"
+
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var items []GitHubItem
+			switch {
+			case strings.Contains(path, "reviewed-by%3A"):
+				items = []GitHubItem{{Number: 5, HTMLURL: "http://example.com/pr/5", State: "open", CreatedAt: "2025-04-24T10:00:00Z"}}
+			case strings.Contains(path, "is%3Apr"):
+				items = []GitHubItem{
+					{Number: 1, HTMLURL: "http://example.com/pr/1", State: "open", CreatedAt: "2025-05-02T10:00:00Z"},
+					{Number: 2, HTMLURL: "http://example.com/pr/2", State: "open", CreatedAt: "2025-05-10T10:00:00Z"},
+					{Number: 4, HTMLURL: "http://example.com/pr/4", State: "open", CreatedAt: "2025-04-17T10:00:00Z"},
+				}
+			case strings.Contains(path, "is%3Aissue"):
+				items = []GitHubItem{{Number: 3, HTMLURL: "http://example.com/issues/3", State: "open", CreatedAt: "2025-04-18T10:00:00Z"}}
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	stdout, _ := captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+
+	// The 15-day window from May 1 is compared with the 15 days from April 16
+	for _, want := range []string{
+		"Week  1 (May 01 - May 07): ○\n" +
+			"                 previous: ░░\n" +
+			"Week  2 (May 08 - May 14): ○\n" +
+			"                 previous: ░\n" +
+			"Week  3 (May 15 - May 15): \n" +
+			"                 previous: \n",
+		"░ = previous window (Apr 16 - Apr 30): 3 contributions, now 2 (-33.3%)\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected output to contain:\n%s\nGot:\n%s", want, stdout)
+		}
+	}
+
+	for _, call := range mockClient.GetCalls {
+		if strings.Contains(call, "search/") && !strings.Contains(call, "created%3A%3E2025-04-16") {
+			t.Errorf("Expected one set of searches from the previous window's start, got %s", call)
+		}
+	}
+	if since != "2025-05-01" {
+		t.Errorf("Expected --since to be restored after the fetch, got %s", since)
+	}
+}
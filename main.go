@@ -2,8 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -11,15 +15,26 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"os/user"
 
 	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/cli/go-gh/v2/pkg/auth"
+	"github.com/cli/go-gh/v2/pkg/config"
+	"github.com/cli/go-gh/v2/pkg/term"
+	"github.com/cli/go-gh/v2/pkg/text"
 	"gopkg.in/yaml.v2"
 )
 
@@ -28,6 +43,9 @@ import (
 // GitHubClient defines the methods needed to interact with the GitHub API.
 type GitHubClient interface {
 	Get(path string, response interface{}) error
+	// GetWithResponse performs a GET and returns the unparsed response,
+	// including headers. The caller must close the body.
+	GetWithResponse(path string) (*http.Response, error)
 }
 
 // GraphQLClient defines the methods needed to interact with the GitHub GraphQL API.
@@ -43,6 +61,14 @@ type TokenFetcher interface {
 // Summarizer defines the method needed to summarize text.
 type Summarizer interface {
 	Summarize(text string) (string, error)
+	// SummarizeCombined produces one cohesive summary across all entries in text.
+	SummarizeCombined(text string) (string, error)
+}
+
+// ModelLister defines the method needed to discover the models an AI
+// endpoint serves.
+type ModelLister interface {
+	ListModels() ([]string, error)
 }
 
 // --- Concrete Implementations ---
@@ -64,6 +90,55 @@ func (c *DefaultGitHubClient) Get(path string, response interface{}) error {
 	return c.client.Get(path, response)
 }
 
+func (c *DefaultGitHubClient) GetWithResponse(path string) (*http.Response, error) {
+	return c.client.Request(http.MethodGet, path, nil)
+}
+
+// limitedClient bounds the number of in-flight requests made through the
+// wrapped client. One is shared by every command and enrichment pass in a run
+// so that their combined concurrency stays under GitHub's secondary rate
+// limits.
+type limitedClient struct {
+	GitHubClient
+	sem chan struct{}
+}
+
+func newLimitedClient(client GitHubClient, limit int) *limitedClient {
+	return &limitedClient{GitHubClient: client, sem: make(chan struct{}, limit)}
+}
+
+func (c *limitedClient) Get(path string, response interface{}) error {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+	return c.GitHubClient.Get(path, response)
+}
+
+// GetWithResponse holds a slot only until the response headers arrive; the
+// body is read after the slot is released.
+func (c *limitedClient) GetWithResponse(path string) (*http.Response, error) {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+	return c.GitHubClient.GetWithResponse(path)
+}
+
+// graphQL returns client wrapped to share c's limit, so GraphQL queries
+// count against the same budget as REST calls.
+func (c *limitedClient) graphQL(client GraphQLClient) GraphQLClient {
+	return &limitedGraphQLClient{GraphQLClient: client, sem: c.sem}
+}
+
+// limitedGraphQLClient is the GraphQL counterpart of limitedClient.
+type limitedGraphQLClient struct {
+	GraphQLClient
+	sem chan struct{}
+}
+
+func (c *limitedGraphQLClient) Do(query string, variables map[string]interface{}, response interface{}) error {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+	return c.GraphQLClient.Do(query, variables, response)
+}
+
 // DefaultGraphQLClient is the default implementation using go-gh.
 type DefaultGraphQLClient struct {
 	client *api.GraphQLClient
@@ -81,6 +156,38 @@ func (c *DefaultGraphQLClient) Do(query string, variables map[string]interface{}
 	return c.client.Do(query, variables, response)
 }
 
+const viewerQuery = `query { viewer { login } }`
+
+// graphQLUserClient answers the authenticated "user" lookup with the GraphQL
+// viewer query and delegates every other request to the wrapped REST client.
+// It is used when --graphql is set.
+type graphQLUserClient struct {
+	GitHubClient
+	gql GraphQLClient
+}
+
+func (c *graphQLUserClient) Get(path string, response interface{}) error {
+	if path != "user" {
+		return c.GitHubClient.Get(path, response)
+	}
+
+	var viewer struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}
+	if err := c.gql.Do(viewerQuery, nil, &viewer); err != nil {
+		return fmt.Errorf("error querying viewer: %w", err)
+	}
+
+	// Re-encode in the REST shape so callers can keep their response types
+	data, err := json.Marshal(map[string]string{"login": viewer.Viewer.Login})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, response)
+}
+
 // GhCliTokenFetcher fetches the token using the 'gh' CLI.
 type GhCliTokenFetcher struct{}
 
@@ -100,6 +207,36 @@ func (tf *GhCliTokenFetcher) FetchToken() (string, error) {
 	return "", fmt.Errorf("github token not found in auth status output")
 }
 
+var refreshTokenFunc = refreshToken // Overridable for tests
+
+// refreshToken runs `gh auth refresh`, attached to the terminal because gh
+// may ask to re-authenticate in the browser. Its output goes to stderr so it
+// can't mix with command output.
+func refreshToken() error {
+	cmd := exec.Command("gh", "auth", "refresh")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running gh auth refresh: %w", err)
+	}
+	return nil
+}
+
+// refreshAuth handles --refresh-token: it refreshes the gh credentials, then
+// confirms a token can be fetched, and reports the outcome to w. A failed
+// refresh is not fatal; the run continues with the current token.
+func refreshAuth(w io.Writer, fetcher TokenFetcher) bool {
+	err := refreshTokenFunc()
+	if err == nil {
+		_, err = fetcher.FetchToken()
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Warning: token refresh failed: %v; continuing with the current token\n", err)
+		return false
+	}
+	fmt.Fprintln(w, "Token refreshed")
+	return true
+}
+
 // AzureAISummarizer uses the Azure AI endpoint for summarization.
 type AzureAISummarizer struct {
 	httpClient   *http.Client
@@ -117,15 +254,47 @@ func NewAzureAISummarizer(httpClient *http.Client, tokenFetcher TokenFetcher) *A
 	}
 }
 
+// Summarize requests per-entry summaries of text, which holds up to
+// --batch-size entries joined with combineSeparator, so the output budget
+// scales with the batch. With --batch-size above 1, the summaries
+// come back separated by batchSummaryDelimiter.
 func (s *AzureAISummarizer) Summarize(text string) (string, error) {
+	return s.complete(buildUserPrompt(text), summaryLengths[summaryLengthFlag].maxTokens*batchSize)
+}
+
+func (s *AzureAISummarizer) SummarizeCombined(text string) (string, error) {
+	return s.complete(buildCombinedUserPrompt(text), summaryLengths[summaryLengthFlag].maxTokens)
+}
+
+// errEmptySummary is returned when the AI endpoint answers 200 OK but with no
+// content, which happens intermittently for borderline input.
+var errEmptySummary = errors.New("no summary content available in the AI response")
+
+// complete sends the system prompt and userContent to the AI endpoint and
+// returns the first choice's content. An empty answer, or invalid JSON with
+// --summarize-format json, is retried up to --ai-retries times; HTTP and
+// parsing errors are returned immediately.
+func (s *AzureAISummarizer) complete(userContent string, maxTokens int) (string, error) {
+	content, err := s.completeOnce(userContent, maxTokens)
+	for attempt := 1; (errors.Is(err, errEmptySummary) || errors.Is(err, errInvalidJSONSummary)) && attempt <= aiRetries; attempt++ {
+		if debug {
+			fmt.Printf("%v; retrying (attempt %d of %d)\n", err, attempt, aiRetries)
+		}
+		content, err = s.completeOnce(userContent, maxTokens)
+	}
+	return content, err
+}
+
+// completeOnce makes a single request to the AI endpoint.
+func (s *AzureAISummarizer) completeOnce(userContent string, maxTokens int) (string, error) {
 	payload := map[string]interface{}{
 		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": fmt.Sprintf(userPrompt, text)},
+			{"role": "system", "content": buildSystemPrompt()},
+			{"role": "user", "content": userContent},
 		},
 		"temperature": 1.0,
 		"top_p":       1.0,
-		"max_tokens":  1000,
+		"max_tokens":  maxTokens,
 		"model":       s.model,
 	}
 
@@ -176,42 +345,172 @@ func (s *AzureAISummarizer) Summarize(text string) (string, error) {
 	}
 
 	if len(aiResponse.Choices) > 0 && aiResponse.Choices[0].Message.Content != "" {
+		if summarizeFormat == "json" {
+			return repairJSONSummary(aiResponse.Choices[0].Message.Content)
+		}
 		return aiResponse.Choices[0].Message.Content, nil
 	}
 
-	return "", fmt.Errorf("no summary content available in the AI response")
+	return "", errEmptySummary
+}
+
+// errModelListingUnsupported is returned by ListModels when the endpoint has
+// no model listing.
+var errModelListingUnsupported = errors.New("endpoint does not support listing models")
+
+// modelsURL derives the OpenAI-compatible /models listing URL from a chat
+// completions endpoint.
+func modelsURL(endpoint string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), "/chat/completions") + "/models"
+}
+
+// ListModels returns the IDs of the models available at the endpoint. It
+// accepts both the OpenAI {"data": [...]} envelope and a bare array.
+func (s *AzureAISummarizer) ListModels() ([]string, error) {
+	githubToken, err := s.tokenFetcher.FetchToken()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving GitHub token: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, modelsURL(s.endpoint), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating GET request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", githubToken))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making GET request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, errModelListingUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("model listing failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	type model struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	var models []model
+	var envelope struct {
+		Data []model `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Data != nil {
+		models = envelope.Data
+	} else if err := json.Unmarshal(body, &models); err != nil {
+		return nil, errModelListingUnsupported
+	}
+
+	var ids []string
+	for _, m := range models {
+		// Bare-array listings carry a registry path in id and the usable
+		// model name in name
+		id := m.ID
+		if m.Name != "" {
+			id = m.Name
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// cachingSummarizer wraps a Summarizer with an on-disk cache keyed by a hash
+// of the model and the full prompt, so re-running a report doesn't pay for
+// the same summaries again. Cache errors are ignored; only successful
+// summaries are stored.
+type cachingSummarizer struct {
+	Summarizer
+	dir   string
+	model string
+}
+
+func (c *cachingSummarizer) Summarize(text string) (string, error) {
+	return c.cached(BuildPrompt(text), func() (string, error) { return c.Summarizer.Summarize(text) })
+}
+
+func (c *cachingSummarizer) SummarizeCombined(text string) (string, error) {
+	return c.cached(BuildCombinedPrompt(text), func() (string, error) { return c.Summarizer.SummarizeCombined(text) })
+}
+
+// cached returns the stored summary for prompt, or calls summarize and
+// stores its result.
+func (c *cachingSummarizer) cached(prompt string, summarize func() (string, error)) (string, error) {
+	sum := sha256.Sum256([]byte(c.model + "\x00" + prompt))
+	path := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+
+	if data, err := os.ReadFile(path); err == nil {
+		if debug {
+			fmt.Printf("Summary cache hit: %s\n", path)
+		}
+		return string(data), nil
+	}
+
+	summary, err := summarize()
+	if err != nil {
+		return "", err
+	}
+	writePrivateFile(c.dir, path, []byte(summary))
+	return summary, nil
 }
 
 // BuildPrompt constructs the prompt that would be sent to the AI endpoint
 // without making any API call. This enables composability with external
 // agentic workflows.
 func BuildPrompt(text string) string {
-	return fmt.Sprintf("System:\n%s\n\nUser:\n%s", systemPrompt, fmt.Sprintf(userPrompt, text))
+	return fmt.Sprintf("System:\n%s\n\nUser:\n%s", buildSystemPrompt(), buildUserPrompt(text))
+}
+
+// BuildCombinedPrompt is the --combine counterpart of BuildPrompt.
+func BuildCombinedPrompt(text string) string {
+	return fmt.Sprintf("System:\n%s\n\nUser:\n%s", buildSystemPrompt(), buildCombinedUserPrompt(text))
 }
 
 const (
-	defaultOrg     = "github"
-	dateFormat     = "2006-01-02"
-	defaultModel   = "gpt-4o"
-	aiEndpoint     = "https://models.inference.ai.azure.com/chat/completions"
-	tokenPrefix    = "  - Token:"
-	entryDelimiter = "---END-OF-ENTRY---"
-	startOfEntry   = "---START-OF-ENTRY---"
-	startOfPR      = "---START-OF-PR---"
-	startOfIssue   = "---START-OF-ISSUE---"
-	startOfReview  = "---START-OF-REVIEW---"
+	defaultOrg        = "github"
+	dateFormat        = "2006-01-02"
+	defaultModel      = "gpt-4o"
+	aiEndpoint        = "https://models.inference.ai.azure.com/chat/completions"
+	tokenPrefix       = "  - Token:"
+	entryDelimiter    = "---END-OF-ENTRY---"
+	startOfEntry      = "---START-OF-ENTRY---"
+	startOfPR         = "---START-OF-PR---"
+	startOfIssue      = "---START-OF-ISSUE---"
+	startOfReview     = "---START-OF-REVIEW---"
 	startOfDiscussion = "---START-OF-DISCUSSION---"
-	endOfPR        = "---END-OF-PR---"
-	endOfIssue     = "---END-OF-ISSUE---"
-	endOfReview    = "---END-OF-REVIEW---"
-	endOfDiscussion = "---END-OF-DISCUSSION---"
+	endOfPR           = "---END-OF-PR---"
+	endOfIssue        = "---END-OF-ISSUE---"
+	endOfReview       = "---END-OF-REVIEW---"
+	endOfDiscussion   = "---END-OF-DISCUSSION---"
 
-	systemPrompt = `You are an expert engineering manager assistant designed to
+	searchResultCap       = 1000 // GitHub search never returns more than this many results
+	utf8BOM               = "\ufeff"
+	enrichmentConcurrency = 5 // Max in-flight requests for per-item enrichment passes
+	defaultMaxConcurrency = 8 // Default --max-concurrency across all API calls in a run
+
+	systemPromptTemplate = `You are an expert engineering manager assistant designed to
 	summarize the bodies of GitHub issues and pull requests. Your goal is to
 	extract key details, provide concise summaries, and ignore irrelevant
-	sections or headers such as 'Mitigation and Rollback Strategies', 'Testing',
-	'Deployment Plan', and 'Approval Responsibility'. Ensure the summaries are
-	actionable and easy to understand. Your responses should be in Markdown
+	sections or headers such as %s. Ensure the summaries are
+	actionable and easy to understand.%s
+
+  For each distinct entry, provide a summary that captures the essence of the
+  content, while ensuring that any links to artifacts are included. Do not
+  include any headers or irrelevant sections in your summaries.`
+
+	markdownFormatInstructions = ` Your responses should be in Markdown
 	format without wrapping Markdown in a code fence and geared for a technical
 	audience with an emphasis on readability.
 
@@ -222,18 +521,167 @@ const (
   - [Link to Artifact 1](<URL>)
   - [Link to Artifact 2](<URL>)
 
-  <br /><br />
+  <br /><br />`
 
-  For each distinct entry, provide a summary that captures the essence of the
-  content, while ensuring that any links to artifacts are included. Do not
-  include any headers or irrelevant sections in your summaries.`
+	jsonFormatInstructions = ` Respond with only a JSON array, without a
+	code fence or any text around it, holding one object per summary with the
+	fields "title" (a descriptive title), "summary" (the summary text), and
+	"links" (an array of artifact URLs).`
+
+	bulletsFormatInstructions = ` Respond with terse Markdown bullets,
+	without a code fence, one per summary:
+  - **<descriptive title>**: <the summary in at most 25 words>
+    - <URL of each artifact>`
 
-	userPrompt = `Summarize the following text while ignoring sections with
-	headers like (e.g., 'Mitigation and Rollback Strategies', 'Testing',
-	'Deployment Plan', 'Approval Responsibility'), include links to all
+	userPromptTemplate = `Summarize the following text %s per entry while ignoring sections with
+	headers like (e.g., %s), include links to all
 	artifacts: %s`
+
+	batchUserPromptTemplate = `The following text holds several entries separated
+	by lines holding only ---. Summarize each entry separately %s, in the order
+	given, while ignoring sections with headers like (e.g., %s), and include
+	links to all artifacts.%s Entries: %s`
+
+	// batchSummaryDelimiter separates the per-entry summaries in the answer to
+	// a --batch-size request, so it can be split back into one per entry
+	batchSummaryDelimiter = "=== END OF SUMMARY ==="
+
+	combinedUserPromptTemplate = `Rather than summarizing each entry separately, write
+	one cohesive summary %s of all the work described in the following text
+	under a single title, ignoring sections with headers like (e.g., %s), and
+	include links to the most important artifacts: %s`
+
+	maxOutputTokensPerRequest = 4000 // Output cap for one request, which bounds --batch-size
+
+	combineSeparator  = "\n\n---\n\n"
+	combineCharBudget = 24000 // ~6k tokens at ~4 characters per token
+)
+
+// defaultIgnoreSections are the section headers the summarizer is told to
+// skip when neither --ignore-section nor the config provides a list.
+var defaultIgnoreSections = []string{"Mitigation and Rollback Strategies", "Testing", "Deployment Plan", "Approval Responsibility"}
+
+// quotedSections renders section names as a quoted, comma-separated list.
+func quotedSections(sections []string) string {
+	quoted := make([]string, len(sections))
+	for i, section := range sections {
+		quoted[i] = "'" + section + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Prompt overrides loaded from --system-prompt-file and --user-prompt-file,
+// empty when unset.
+var (
+	systemPromptOverride string
+	userPromptOverride   string
 )
 
+// loadPromptFile reads a prompt override from path. With requireText the
+// prompt must contain %s, which marks where the entries are inserted.
+func loadPromptFile(path string, requireText bool) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading prompt file: %w", err)
+	}
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" {
+		return "", fmt.Errorf("prompt file %s is empty", path)
+	}
+	if requireText && !strings.Contains(prompt, "%s") {
+		return "", fmt.Errorf("prompt file %s must contain %%s where the entries go", path)
+	}
+	return prompt, nil
+}
+
+// warnOverriddenPromptFlags warns when a prompt file replaces the built-in
+// wording an explicitly set --length or --summarize-format would add.
+func warnOverriddenPromptFlags(setFlags map[string]bool) {
+	if userPromptOverride != "" && setFlags["length"] {
+		fmt.Fprintf(warningWriter(), "Warning: --user-prompt-file replaces the --length %s wording; the length only caps the response at about %d tokens, so ask for it in the prompt file\n", summaryLengthFlag, summaryLengths[summaryLengthFlag].maxTokens)
+	}
+	if systemPromptOverride != "" && setFlags["summarize-format"] {
+		fmt.Fprintf(warningWriter(), "Warning: --system-prompt-file replaces the --summarize-format %s instructions; describe the format in the prompt file\n", summarizeFormat)
+	}
+}
+
+// buildSystemPrompt returns the system prompt with the effective ignore
+// list, or the --system-prompt-file override verbatim.
+func buildSystemPrompt() string {
+	if systemPromptOverride != "" {
+		return systemPromptOverride
+	}
+	return fmt.Sprintf(systemPromptTemplate, quotedSections(getEffectiveIgnoreSections()), summaryFormats[summarizeFormat])
+}
+
+// summaryFormats maps each --summarize-format value to the output
+// instructions in the system prompt. markdown is the original format.
+var summaryFormats = map[string]string{
+	"markdown": markdownFormatInstructions,
+	"json":     jsonFormatInstructions,
+	"bullets":  bulletsFormatInstructions,
+}
+
+// errInvalidJSONSummary is returned when --summarize-format json gets an
+// answer that isn't valid JSON even after repairJSONSummary.
+var errInvalidJSONSummary = errors.New("AI response is not valid JSON")
+
+// repairJSONSummary returns content as valid JSON, stripping a code fence or
+// any text around the outermost array or object, which models add despite
+// instructions. It returns errInvalidJSONSummary when no valid JSON remains.
+func repairJSONSummary(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if json.Valid([]byte(content)) {
+		return content, nil
+	}
+	start := strings.IndexAny(content, "[{")
+	end := strings.LastIndexAny(content, "]}")
+	if start >= 0 && end > start && json.Valid([]byte(content[start:end+1])) {
+		return content[start : end+1], nil
+	}
+	return "", errInvalidJSONSummary
+}
+
+// buildUserPrompt returns the user prompt for text with the effective ignore
+// list. With --batch-size above 1 it asks for one summary per entry, which
+// outside --summarize-format json (already one object per summary) are
+// separated by batchSummaryDelimiter.
+func buildUserPrompt(text string) string {
+	if userPromptOverride != "" {
+		return strings.Replace(userPromptOverride, "%s", text, 1)
+	}
+	if batchSize > 1 {
+		delimiter := ""
+		if summarizeFormat != "json" {
+			delimiter = " Put a line holding only " + batchSummaryDelimiter + " after each entry's summary."
+		}
+		return fmt.Sprintf(batchUserPromptTemplate, summaryLengths[summaryLengthFlag].phrase, quotedSections(getEffectiveIgnoreSections()), delimiter, text)
+	}
+	return fmt.Sprintf(userPromptTemplate, summaryLengths[summaryLengthFlag].phrase, quotedSections(getEffectiveIgnoreSections()), text)
+}
+
+// buildCombinedUserPrompt returns the --combine user prompt for text.
+func buildCombinedUserPrompt(text string) string {
+	if userPromptOverride != "" {
+		return strings.Replace(userPromptOverride, "%s", text, 1)
+	}
+	return fmt.Sprintf(combinedUserPromptTemplate, summaryLengths[summaryLengthFlag].phrase, quotedSections(getEffectiveIgnoreSections()), text)
+}
+
+// summaryLength sets the token budget and prompt wording for a --length value.
+type summaryLength struct {
+	maxTokens int
+	phrase    string
+}
+
+// summaryLengths maps each --length value to its settings. medium keeps the
+// original 1000-token budget.
+var summaryLengths = map[string]summaryLength{
+	"short":  {maxTokens: 300, phrase: "in one sentence"},
+	"medium": {maxTokens: 1000, phrase: "in a concise paragraph"},
+	"long":   {maxTokens: 2000, phrase: "in a detailed paragraph"},
+}
+
 // Structs for API responses
 type GitHubItem struct {
 	Number     int    `json:"number"`
@@ -243,9 +691,36 @@ type GitHubItem struct {
 	Body       string `json:"body,omitempty"`
 	CreatedAt  string `json:"created_at"`
 	ClosedAt   string `json:"closed_at"`
+	UpdatedAt  string `json:"updated_at"`
 	Repository struct {
 		Name string `json:"name"`
 	} `json:"repository"`
+	AuthorAssociation string          `json:"author_association"`        // OWNER, MEMBER, CONTRIBUTOR, NONE, ...
+	PullRequest       *pullRequestRef `json:"pull_request,omitempty"`    // Only set for pull requests
+	TotalReactions    int             `json:"total_reactions,omitempty"` // Populated by enrichWithReactions, not by search
+	Comments          int             `json:"comments"`
+	ClosedByPR        string          `json:"closed_by_pr,omitempty"` // Populated by enrichWithClosingPRs, not by search
+	Duplicates        int             `json:"duplicates,omitempty"`   // Populated by dedupeItems, not by search
+	Labels            []itemLabel     `json:"labels,omitempty"`
+	ReviewState       string          `json:"review_state,omitempty"`      // Populated by enrichWithReviewStates, not by search
+	FirstResponseAt   string          `json:"first_response_at,omitempty"` // Populated by enrichWithFirstResponses, not by search
+	StateReason       string          `json:"state_reason,omitempty"`      // completed, not_planned, duplicate, or reopened; issues only
+	CanonicalState    string          `json:"canonical_state,omitempty"`   // Set from canonicalState with --normalize-state, not by search
+}
+
+// itemLabel is a label attached to an issue or pull request.
+type itemLabel struct {
+	Name string `json:"name"`
+}
+
+// pullRequestRef is the pull request metadata search attaches to PR items.
+type pullRequestRef struct {
+	MergedAt string `json:"merged_at"`
+}
+
+// isMerged reports whether the item is a merged pull request.
+func (i GitHubItem) isMerged() bool {
+	return i.PullRequest != nil && i.PullRequest.MergedAt != ""
 }
 
 // Define contribution type struct to be used as map key
@@ -259,49 +734,290 @@ type GitHubResponse struct {
 	Items      []GitHubItem `json:"items"`
 }
 
+// bodyMarkers holds the sentinels printed around each body in --body-only
+// output and used by summarize to split entries.
+type bodyMarkers struct {
+	entry           string
+	prStart         string
+	prEnd           string
+	issueStart      string
+	issueEnd        string
+	reviewStart     string
+	reviewEnd       string
+	discussionStart string
+	discussionEnd   string
+}
+
 // Global variables
 var (
-	debug          bool
-	since          string
-	bodyOnly       bool
-	orgFlag        string
-	modelFlag      string // Global variable to store the value of the --model flag
-	promptOnly     bool   // Global variable to store the value of the --prompt-only flag
-	visibilityFlag string // Filter by repository visibility: "public" or "private"
+	debug             bool
+	since             string
+	bodyOnly          bool
+	orgFlag           string
+	aiModelFlag       string // Global variable to store the value of the --ai-model flag
+	modelFlag         string // Deprecated alias for --ai-model
+	promptOnly        bool   // Global variable to store the value of the --prompt-only flag
+	visibilityFlag    string // Filter by repository visibility: "public" or "private"
+	withReactions     bool   // Fetch reaction totals for each item (one extra API call per item)
+	maxItems          int    // Cap on the number of items rendered; 0 means no cap
+	githubOutput      bool   // Write graph stats as GitHub Actions outputs instead of drawing the graph
+	withReviews       bool   // graph: add the authored vs. reviewed split to the summary
+	markers           bodyMarkers
+	until             string          // Filter results created on or before this date
+	periodFlag        string          // Named preset that sets both since and until
+	associationFlag   string          // Comma-separated author associations to keep, e.g. "MEMBER,OWNER"
+	bomFlag           bool            // Prefix CSV output with a UTF-8 byte order mark for Excel
+	timezoneFlag      string          // IANA time zone for date boundaries and graph bucketing
+	outputFormat      string          // Output format for item lists: "csv", "tsv", "json", or "table"
+	rawOutput         bool            // Print the first unparsed search response and exit
+	ignoreSections    stringSliceFlag // Section headers the summarizer should skip (repeatable)
+	baseFlag          string          // Only include authored PRs targeting this base branch
+	summaryLengthFlag string          // Summary depth: "short", "medium", or "long"
+	combineFlag       bool            // Summarize all entries together instead of one by one
+	emojiFlag         bool            // Render the State column as icons
+	useGraphQL        bool            // Resolve the authenticated user via GraphQL
+	outputPath        string          // Write list output to this file instead of stdout
+	appendOutput      bool            // Append to --output instead of overwriting
+	splitByRepo       bool            // Write one CSV per repository into outputDir
+	outputDir         string          // Directory for --split-by-repo files
+	calendarFlag      bool            // graph: draw a day-by-week calendar instead of weekly bars
+	asciiFlag         bool            // graph: ASCII shading for the calendar
+	jsonStream        bool            // summarize: emit one JSON line per entry as it completes
+	filterFlag        string          // Regular expression items must match, applied client-side
+	filterBody        bool            // Match --filter against the body instead of the title
+	topRepos          int             // footprint: repositories shown before collapsing into "others"
+	checkClock        bool            // Compare the local clock with GitHub's before running
+	whenFlag          bool            // graph: histograms of completion day of week and hour
+	sortFlag          string          // Render order for lists: "created" or "comments"
+	includeClosedByPR bool            // Look up the pull request that closed each closed issue
+	quiet             bool            // Suppress routine warnings
+	suppressWarnings  bool            // Suppress all warnings, including data-loss ones
+	retryEmpty        int             // Re-run empty searches up to this many times
+	dedupeByTitle     bool            // Collapse items with the same normalized title
+	dedupeStrip       string          // Regular expression removed from titles before deduping
+	systemPromptFile  string          // File holding a replacement system prompt
+	userPromptFile    string          // File holding a replacement user prompt
+	countOnly         bool            // Print only the number of reviewed pull requests
+	sinceLastRun      bool            // Set --since from the previous successful run
+	stateFile         string          // Where --since-last-run records the last run
+	linkedFlag        string          // linked: search qualifier value, pr or issue
+	normalizeURLs     bool            // Omit the trailing space after URLs
+	failFast          bool            // Stop a multi-user run at the first failing user
+	continueOnError   bool            // Skip failing users in a multi-user run (the default)
+	withReviewState   bool            // Look up the user's latest review decision on each reviewed PR
+	noSummaryCache    bool            // Bypass the on-disk summary cache
+	maxConcurrency    int             // Global cap on in-flight GitHub API requests
+	exportPreset      bool            // --export: TSV with a fixed column schema
+	dateField         string          // Search date field for the window: "created", "updated", or "closed"
+	aiRetries         int             // Retries when the AI endpoint returns empty content
+	estimateFlag      bool            // summarize: print a token estimate instead of calling the AI
+	detailedFlag      bool            // graph: list each week's items under its bar
+	minWeekCount      int             // graph: hide weeks below this many contributions
+	editFlag          bool            // summarize: open the summaries in $EDITOR before printing
+	graphType         string          // graph: "bar", "dot", or "line"
+	svgWidth          int             // graph --format svg: image width in pixels
+	svgHeight         int             // graph --format svg: image height in pixels
+	milestoneFlag     string          // Only include authored PRs and issues in this milestone
+	authorCompany     bool            // repo-activity: group users by profile company
+	closedSince       bool            // Same as --date-field closed
+	queryExtra        string          // Raw qualifiers appended to authored searches
+	summaryFooter     string          // summarize: login whose activity links end the output
+	batchSize         int             // summarize: entries per AI request
+	onlyPRs           bool            // all: fetch only authored pull requests
+	onlyIssues        bool            // all: fetch only authored issues
+	granularityFlag   string          // graph: week or Nw buckets
+	authorEmail       bool            // repo-activity: add each user's public email
+	preserveLinksFlag bool            // summarize: append input URLs the summary omits
+	refreshTokenFlag  bool            // Run gh auth refresh before creating clients
+	summarizeFormat   string          // summarize: markdown, json, or bullets
+	graphWeight       string          // graph: count or reactions
+	orgReposFilter    string          // Only count contributions in org repos with this topic
+	csvDelimiter      string          // CSV field delimiter
+	untilInclusive    bool            // Count all of the --until day
+	summarizeParallel int             // summarize: concurrent AI requests
+	scoreFlag         bool            // graph, repo-activity: add a weighted contribution score
+	watchInterval     time.Duration   // graph: redraw interval for --watch, 0 to draw once
+	prometheusFlag    bool            // graph: write stats as Prometheus metrics
+	withResponseTime  bool            // issues: report first-response times
+	normalizeState    bool            // Add a canonical open/merged/closed/not_planned state column
+	noSessionCache    bool            // Bypass the short-lived on-disk search cache
+	redactFlag        bool            // Replace URLs and emails in body and summary output
+	redactAllow       stringSliceFlag // Domains --redact keeps (repeatable)
+	titleTemplate     string          // summarize: template for generated titles, with {number} and {title}
+	orgMembersOnly    bool            // repo-activity: skip users who aren't org members
+	pivotFlag         bool            // graph: write weekly counts as CSV
+	comparePrevious   bool            // graph: overlay the equal-length window before --since
+	windowsFlag       string          // graph: comma-separated trailing windows to compare
+	manifestPath      string          // Write a JSON manifest of the run's output files here
+	releaseAnchor     string          // With --since release:, pick the latest or earliest release
+	strictOrg         bool            // Error instead of falling back to defaultOrg when no org is set
+	withCommentCount  bool            // Add a Comments column to list output without re-sorting
 )
 
+// stringSliceFlag is a flag.Value that collects every occurrence of a
+// repeatable flag.
+type stringSliceFlag []string
+
+func (f *stringSliceFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringSliceFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func init() {
-	flag.BoolVar(&debug, "debug", false, "Enable debug mode")
-	defaultSince := time.Now().AddDate(0, 0, -30).Format(dateFormat)
-	flag.StringVar(&since, "since", defaultSince, "Filter results created since the specified date (e.g., 2025-04-11)")
-	flag.BoolVar(&bodyOnly, "body-only", false, "Fetch and print only the body of the pull requests")
-	flag.StringVar(&orgFlag, "org", "", "Override the configured organization")
-	flag.StringVar(&modelFlag, "model", "", "Override the configured or default model")
-	flag.BoolVar(&promptOnly, "prompt-only", false, "Output the raw prompt without sending to the AI endpoint")
-	flag.StringVar(&visibilityFlag, "visibility", "", "Filter by repository visibility: public or private")
+	registerFlags(flag.CommandLine)
+}
+
+// registerFlags defines the global flags on fs. It is used both for the
+// default flag set (for help output) and for the flag set main parses.
+func registerFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.IntVar(&maxConcurrency, "max-concurrency", defaultMaxConcurrency, "Max in-flight GitHub API requests across the whole run, shared by all enrichment passes")
+	fs.IntVar(&retryEmpty, "retry-empty", 0, "Re-run a search up to N times when it returns no results, for freshly created items")
+	fs.BoolVar(&quiet, "quiet", false, "Suppress routine warnings; warnings about incomplete or truncated results still print")
+	fs.BoolVar(&suppressWarnings, "suppress-warnings", false, "Suppress all warnings, including those about incomplete or truncated results")
+	fs.StringVar(&since, "since", "", "Filter results created since the specified date (e.g., 2025-04-11), ISO week (e.g., 2025-W16, from its Monday), issue:owner/repo#123 for that issue's creation date, or release:repo-a,repo-b for the latest release across those repositories (default 30 days ago)")
+	fs.StringVar(&releaseAnchor, "release-anchor", "latest", "With --since release:, use the 'latest' or 'earliest' of the repositories' latest releases")
+	fs.StringVar(&until, "until", "", "Filter results created on or before the specified date (e.g., 2025-04-30)")
+	fs.BoolVar(&untilInclusive, "until-inclusive", true, "Count all of the --until day, through 23:59:59 in --timezone; set --until-inclusive=false to end the window at the start of that day")
+	fs.StringVar(&dateField, "date-field", "created", "Which date --since/--until filter on: created, updated to include older items with recent activity, or closed")
+	fs.BoolVar(&closedSince, "closed-since", false, "Filter --since/--until on when items were closed, matching how the graph buckets them (same as --date-field closed)")
+	fs.StringVar(&timezoneFlag, "timezone", "", "IANA time zone (e.g., Europe/Berlin) for --since/--until, the default window, and graph weeks (default UTC, matching GitHub)")
+	fs.BoolVar(&sinceLastRun, "since-last-run", false, "Set --since from the previous successful run recorded in --state-file, and record this run on success")
+	fs.StringVar(&stateFile, "state-file", "", "State file for --since-last-run (default ~/.cache/gh-contrib/lastrun)")
+	fs.StringVar(&periodFlag, "period", "", "Set --since/--until from a preset: "+strings.Join(periodNames, ", "))
+	fs.BoolVar(&bodyOnly, "body-only", false, "Fetch and print only the body of the pull requests")
+	fs.StringVar(&orgFlag, "org", "", "Override the configured organization")
+	fs.BoolVar(&strictOrg, "strict-org", false, "Error when neither --org nor the config sets an organization instead of searching '"+defaultOrg+"'")
+	fs.StringVar(&aiModelFlag, "ai-model", "", "Override the configured or default AI model")
+	fs.StringVar(&modelFlag, "model", "", "Deprecated: use --ai-model")
+	fs.StringVar(&titleTemplate, "title-template", "", "summarize: rewrite each generated title with this template, e.g. '#{number} {title}', using the item number from the --body-only markers")
+	fs.BoolVar(&redactFlag, "redact", false, "Replace URLs and email addresses in --body-only and summarize output with placeholders")
+	fs.Var(&redactAllow, "redact-allow", "With --redact, keep URLs and emails on this domain and its subdomains (repeatable)")
+	fs.Var(&ignoreSections, "ignore-section", "Section header the summarizer should ignore (repeatable; replaces the defaults)")
+	fs.StringVar(&summaryLengthFlag, "length", "medium", "Summary length for summarize: short (~300 tokens), medium (~1000), or long (~2000)")
+	fs.BoolVar(&combineFlag, "combine", false, "summarize: produce one cohesive summary across all entries instead of one per entry")
+	fs.BoolVar(&preserveLinksFlag, "preserve-links", false, "summarize: append a ### Links section with any URL from the input that the summary omits")
+	fs.StringVar(&summarizeFormat, "summarize-format", "markdown", "summarize: output format the prompt asks for: markdown, json (validated; title, summary, and links per summary), or bullets")
+	fs.IntVar(&summarizeParallel, "parallel", 1, "summarize: summarize up to N entries (or batches) at once; output keeps the input order")
+	fs.IntVar(&batchSize, "batch-size", 1, "summarize: send up to N entries per AI request, still printing one summary per entry (limited by the --length output budget)")
+	fs.BoolVar(&jsonStream, "json-stream", false, "summarize: emit one JSON object per line ({index, summary, error}) as each entry finishes")
+	fs.IntVar(&aiRetries, "ai-retries", 1, "summarize: retry up to N times when the AI endpoint returns an empty summary, or invalid JSON with --summarize-format json (HTTP errors are not retried)")
+	fs.BoolVar(&noSessionCache, "no-session-cache", false, "Always run searches instead of reusing results cached for 10 minutes in ~/.cache/gh-contrib/searches")
+	fs.BoolVar(&noSummaryCache, "no-summary-cache", false, "summarize: always call the AI endpoint instead of reusing cached summaries from ~/.cache/gh-contrib/summaries")
+	fs.StringVar(&summaryFooter, "summary-footer", "", "summarize: append a footer with the org, date range, and GitHub links for this login's activity")
+	fs.BoolVar(&editFlag, "edit", false, "summarize: open the summaries in $EDITOR and print the edited text when it exits (only when stdout is a terminal)")
+	fs.BoolVar(&estimateFlag, "estimate", false, "summarize: print the number of requests and an approximate token count without calling the AI endpoint")
+	fs.BoolVar(&promptOnly, "prompt-only", false, "Output the raw prompt without sending to the AI endpoint")
+	fs.StringVar(&systemPromptFile, "system-prompt-file", "", "summarize: read the system prompt from this file instead of the built-in one")
+	fs.StringVar(&userPromptFile, "user-prompt-file", "", "summarize: read the user prompt from this file; it must contain %s where the entries go")
+	fs.StringVar(&orgReposFilter, "org-repos-filter", "", "Only count contributions in org repositories tagged with this topic (e.g., kubernetes); costs one repository search per 100 repos")
+	fs.StringVar(&queryExtra, "query-extra", "", "Raw search qualifiers appended as-is to authored pull request and issue searches (e.g. 'review:approved -label:wip'); not validated")
+	fs.StringVar(&milestoneFlag, "milestone", "", "Only include pull requests and issues in this milestone (e.g., v2.0)")
+	fs.StringVar(&baseFlag, "base", "", "Only include pull requests targeting this base branch (e.g., main)")
+	fs.StringVar(&visibilityFlag, "visibility", "", "Filter by repository visibility: public or private")
+	fs.BoolVar(&authorEmail, "author-email", false, "repo-activity: add an Email column with each user's public profile email, blank when hidden (one extra API call per user)")
+	fs.BoolVar(&authorCompany, "author-company", false, "repo-activity: group users by the company on their GitHub profile (one extra API call per user)")
+	fs.BoolVar(&failFast, "fail-fast", false, "repo-activity: stop at the first user whose fetch fails and exit non-zero")
+	fs.BoolVar(&continueOnError, "continue", false, "repo-activity: skip users whose fetch fails, report them at the end, and exit non-zero (default)")
+	fs.IntVar(&topRepos, "top-repos", 0, "footprint, repo-activity: show only the N most active repositories and collapse the rest into an 'others' row or column (0 = all)")
+	fs.StringVar(&sortFlag, "sort", "created", "Order list output: created (newest first) or comments (most discussed first, adds a Comments column)")
+	fs.BoolVar(&withCommentCount, "include-comment-count", false, "Add a Comments column with each item's comment count from the search results, keeping the --sort order")
+	fs.IntVar(&maxItems, "max-items", 0, "Cap the number of items rendered, after sorting (0 = no cap)")
+	fs.BoolVar(&calendarFlag, "calendar", false, "graph: draw a GitHub-style day-by-week calendar instead of weekly bars")
+	fs.BoolVar(&detailedFlag, "detailed", false, "graph: list each week's items (title and URL) under its bar")
+	fs.IntVar(&svgWidth, "width", 800, "graph --format svg: image width in pixels")
+	fs.IntVar(&svgHeight, "height", 400, "graph --format svg: image height in pixels")
+	fs.StringVar(&graphType, "graph-type", "bar", "graph: how to draw weekly totals: bar (one symbol per item), dot (a scaled dot and count), or line (an ASCII line chart; ignores --detailed and --min-week-count)")
+	fs.BoolVar(&orgMembersOnly, "org-members-only", false, "repo-activity: only include users who are members of the org, skipping external contributors")
+	fs.BoolVar(&scoreFlag, "score", false, "graph, repo-activity: add a weighted contribution score and its formula (weights from score_weights in the config; repo-activity makes two more searches per user)")
+	fs.DurationVar(&watchInterval, "watch", 0, "graph: redraw every interval (e.g., 5m, at least 1m) as a live dashboard until Ctrl-C; ignored when output is piped")
+	fs.StringVar(&graphWeight, "weight", "count", "graph: what sizes the bars: count (one per item) or reactions (total reactions per item; one extra API call per item)")
+	fs.StringVar(&granularityFlag, "granularity", "week", "graph: bucket size: week, or Nw to merge N weeks per bucket (e.g., 2w for two-week sprints)")
+	fs.IntVar(&minWeekCount, "min-week-count", 0, "graph: hide weeks with fewer than N contributions from the bars; totals still include them (1 hides empty weeks)")
+	fs.BoolVar(&whenFlag, "when", false, "graph: add histograms of merged/closed items by day of week and hour of day")
+	fs.BoolVar(&asciiFlag, "ascii", false, "graph: use plain ASCII shading for --calendar")
+	fs.StringVar(&windowsFlag, "windows", "", "graph: compare the summary numbers across trailing windows instead of drawing the graph, e.g. 7d,30d,90d")
+	fs.BoolVar(&comparePrevious, "compare-previous", false, "graph: draw the same weeks of the equal-length window before --since under each bar and summarize the change")
+	fs.BoolVar(&pivotFlag, "pivot", false, "graph: write the weekly counts as CSV, one row per week and one column per type and state, instead of drawing the graph")
+	fs.BoolVar(&prometheusFlag, "prometheus", false, "graph: write summary stats as Prometheus text-format metrics instead of drawing the graph")
+	fs.BoolVar(&githubOutput, "github-output", false, "graph: write summary stats as name=value lines to $GITHUB_OUTPUT (or stdout)")
+	fs.BoolVar(&withReviews, "with-reviews", false, "graph: add Authored and Reviewed lines to the summary showing the balance between your own work and reviews")
+	fs.StringVar(&markers.entry, "entry-delimiter", entryDelimiter, "Delimiter printed after each entry in --body-only output and used to split summarize input")
+	fs.StringVar(&markers.prStart, "pr-start", startOfPR, "Marker printed before each pull request body")
+	fs.StringVar(&markers.prEnd, "pr-end", endOfPR, "Marker printed after each pull request body")
+	fs.StringVar(&markers.issueStart, "issue-start", startOfIssue, "Marker printed before each issue body")
+	fs.StringVar(&markers.issueEnd, "issue-end", endOfIssue, "Marker printed after each issue body")
+	fs.StringVar(&markers.reviewStart, "review-start", startOfReview, "Marker printed before each reviewed pull request body")
+	fs.StringVar(&markers.reviewEnd, "review-end", endOfReview, "Marker printed after each reviewed pull request body")
+	fs.StringVar(&markers.discussionStart, "discussion-start", startOfDiscussion, "Marker printed before each discussion body")
+	fs.StringVar(&markers.discussionEnd, "discussion-end", endOfDiscussion, "Marker printed after each discussion body")
+	fs.StringVar(&associationFlag, "association", "", "Keep only items whose author association matches (comma-separated, e.g. MEMBER,OWNER) and add an Association column")
+	fs.BoolVar(&checkClock, "check-clock", false, "Warn if the local clock differs from GitHub's by more than 5 minutes (always on with --debug)")
+	fs.BoolVar(&refreshTokenFlag, "refresh-token", false, "Run gh auth refresh before starting, so long batch runs begin with fresh credentials")
+	fs.BoolVar(&useGraphQL, "graphql", false, "Look up the authenticated user through the GraphQL API instead of REST")
+	fs.StringVar(&filterFlag, "filter", "", "Keep only items whose title matches this regular expression (e.g. '(?i)flaky|retry')")
+	fs.BoolVar(&filterBody, "filter-body", false, "Match --filter against the item body instead of the title")
+	fs.StringVar(&linkedFlag, "linked", "", "Keep only issues linked to a pull request (pr) or pull requests linked to an issue (issue)")
+	fs.BoolVar(&countOnly, "count", false, "reviews: print only the number of pull requests reviewed in the window")
+	fs.BoolVar(&dedupeByTitle, "dedupe-by-title", false, "Collapse items with the same normalized title, keeping the most recent and adding a Duplicates column")
+	fs.StringVar(&dedupeStrip, "dedupe-strip", "", "Regular expression removed from titles before --dedupe-by-title compares them (e.g. '(?i)\\[backport\\]|\\(#\\d+\\)')")
+	fs.BoolVar(&rawOutput, "raw", false, "Print the verbatim JSON of the first search response page and exit (for debugging)")
+	fs.StringVar(&outputFormat, "format", "csv", "Output format: csv, tsv, json, table, contributors-json (all only), or svg (graph only)")
+	fs.BoolVar(&exportPreset, "export", false, "Preset for loading into data tools: TSV with clean URLs and the fixed columns "+strings.Join(exportHeaders, ", "))
+	fs.BoolVar(&normalizeState, "normalize-state", false, "Add a Canonical State column (canonical_state in JSON): open, merged, closed, or not_planned across every contribution type")
+	fs.BoolVar(&emojiFlag, "emoji", false, "Render the State column as 🟢 open, 🟣 merged, or 🔴 closed (not machine-friendly)")
+	fs.StringVar(&outputPath, "output", "", "Write CSV, table, or JSON list output to this file instead of stdout")
+	fs.BoolVar(&appendOutput, "append", false, "With --output, append to the file instead of overwriting it; the CSV header is only written if the file is empty")
+	fs.BoolVar(&splitByRepo, "split-by-repo", false, "Write one CSV file per repository (owner-repo.csv) into --output-dir")
+	fs.StringVar(&outputDir, "output-dir", "", "Directory for --split-by-repo files")
+	fs.StringVar(&manifestPath, "manifest", "", "Write a JSON manifest of the files this run produced (path, type, item count) to this path")
+	fs.BoolVar(&normalizeURLs, "normalize-urls", false, "Print clean URLs, without the space after each one that makes it easier to click in a terminal (JSON is always clean)")
+	fs.StringVar(&csvDelimiter, "csv-delimiter", ",", "Field delimiter for CSV output, e.g. ';' for European Excel")
+	fs.BoolVar(&bomFlag, "bom", false, "Prefix CSV output with a UTF-8 byte order mark so Excel displays non-ASCII text correctly")
+	fs.BoolVar(&withResponseTime, "with-response-time", false, "issues: report the median and average time to a first comment from someone other than the author (one extra API call per commented issue)")
+	fs.BoolVar(&includeClosedByPR, "include-closed-by-pr", false, "issues, all: add a Closed By column with the pull request that closed each issue (one extra API call per closed issue)")
+	fs.BoolVar(&withReviewState, "with-review-state", false, "reviews, all: add a Review State column with your latest review decision on each PR (one extra API call per reviewed PR)")
+	fs.BoolVar(&onlyPRs, "only-prs", false, "all: list only pull requests you authored, skipping the review, issue, and discussion searches")
+	fs.BoolVar(&onlyIssues, "only-issues", false, "all: list only issues you authored, skipping the pull request, review, and discussion searches")
+	fs.BoolVar(&withReactions, "with-reactions", false, "Add a Reactions column with each item's total reactions (one extra API call per item)")
+}
+
+// isBoolFlag reports whether arg names a boolean flag defined on fs.
+func isBoolFlag(fs *flag.FlagSet, arg string) bool {
+	f := fs.Lookup(strings.TrimLeft(arg, "-"))
+	if f == nil {
+		return false
+	}
+	bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
 }
 
 func main() {
 	// Create a custom FlagSet to handle flags in any position
 	var cmdFlags flag.FlagSet
-	cmdFlags.BoolVar(&debug, "debug", false, "Enable debug mode")
-	defaultSince := time.Now().AddDate(0, 0, -30).Format(dateFormat)
-	cmdFlags.StringVar(&since, "since", defaultSince, "Filter results created since the specified date (e.g., 2025-04-11)")
-	cmdFlags.BoolVar(&bodyOnly, "body-only", false, "Fetch and print only the body of the pull requests")
-	cmdFlags.StringVar(&orgFlag, "org", "", "Override the configured organization")
-	cmdFlags.StringVar(&modelFlag, "model", "", "Override the configured or default model")
-	cmdFlags.BoolVar(&promptOnly, "prompt-only", false, "Output the raw prompt without sending to the AI endpoint")
-	cmdFlags.StringVar(&visibilityFlag, "visibility", "", "Filter by repository visibility: public or private")
+	registerFlags(&cmdFlags)
 
 	// Process all the arguments to find and extract flags anywhere in the command
 	args := os.Args[1:] // Skip the program name
 
 	// Extract all flags and non-flags separately
 	var nonFlagArgs []string
+	var helpRequested bool
 	var i int
 	for i < len(args) {
 		arg := args[i]
 
+		// Help is routed before dispatch so it never reaches the API
+		if arg == "-h" || arg == "--help" || arg == "-help" {
+			helpRequested = true
+			i++
+			continue
+		}
+
 		// Check if argument is a flag
 		if strings.HasPrefix(arg, "-") {
 			// Handle --flag=value style
@@ -314,7 +1030,7 @@ func main() {
 			// Handle --flag value style
 			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
 				// Check if the flag requires a value
-				if arg == "-debug" || arg == "--debug" || arg == "-body-only" || arg == "--body-only" || arg == "-prompt-only" || arg == "--prompt-only" {
+				if isBoolFlag(&cmdFlags, arg) {
 					// Boolean flags don't require a value
 					cmdFlags.Parse([]string{arg})
 					i++
@@ -344,169 +1060,546 @@ func main() {
 		subcommandArgs = append([]string{subcommand}, nonFlagArgs[1:]...)
 	}
 
-	// Validate --visibility flag
-	if visibilityFlag != "" && visibilityFlag != "public" && visibilityFlag != "private" {
-		fmt.Fprintf(os.Stderr, "Error: --visibility must be 'public' or 'private', got '%s'\n", visibilityFlag)
-		os.Exit(1)
-	}
-
-	if debug {
-		fmt.Println("Debug mode enabled")
-		fmt.Printf("Arguments: %v\n", subcommandArgs)
-		fmt.Printf("Using AI model: %s\n", getEffectiveModel())
-		if visibilityFlag != "" {
-			fmt.Printf("Filtering by visibility: %s\n", visibilityFlag)
+	if helpRequested {
+		if !printCommandHelp(subcommand, &cmdFlags) {
+			printUsage()
 		}
+		return
 	}
 
-	ghClient, err := NewDefaultGitHubClient()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing GitHub client: %v\n", err)
-		os.Exit(1)
+	// Static content, so it needs no clients or authentication
+	if subcommand == "examples" {
+		printExamples()
+		return
 	}
 
-	gqlClient, err := NewDefaultGraphQLClient()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing GitHub GraphQL client: %v\n", err)
-		os.Exit(1)
-	}
+	// Record which flags were set explicitly so presets can detect conflicts
+	setFlags := make(map[string]bool)
+	cmdFlags.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
 
-	tokenFetcher := &GhCliTokenFetcher{}
-	httpClient := &http.Client{}
-	summarizer := NewAzureAISummarizer(httpClient, tokenFetcher)
+	if timezoneFlag != "" {
+		loc, err := time.LoadLocation(timezoneFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: unknown --timezone '%s': %v\n", timezoneFlag, err)
+			os.Exit(1)
+		}
+		location = loc
+	}
 
-	if len(nonFlagArgs) == 0 {
-		printHelp(ghClient)
+	// Needs only the time zone, so it runs before any clients are created
+	if subcommand == "periods" {
+		printPeriods(timeNowFunc().In(location))
 		return
 	}
 
-	cmd := subcommand
-	switch cmd {
-	case "pulls":
-		handlePullsCommand(subcommandArgs, ghClient)
-	case "reviews":
-		handleReviewsCommand(subcommandArgs, ghClient)
-	case "issues":
-		handleIssuesCommand(subcommandArgs, ghClient)
-	case "discussions":
-		handleDiscussionsCommand(subcommandArgs, ghClient, gqlClient)
-	case "all":
-		handleAllCommand(subcommandArgs, ghClient, gqlClient)
-	case "summarize":
-		handleSummarizeCommand(subcommandArgs, summarizer, promptOnly)
-	case "graph":
-		handleGraphCommand(subcommandArgs, ghClient, gqlClient)
-	default:
-		fmt.Printf("Unknown command: %s\n", cmd)
-		printHelp(ghClient)
+	// Computed here rather than as the flag default so it follows --timezone
+	if !setFlags["since"] {
+		since = defaultSince()
 	}
-}
 
-func handlePullsCommand(args []string, client GitHubClient) {
-	login, err := resolveLogin(args, client)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+	if periodFlag != "" {
+		if setFlags["since"] || setFlags["until"] {
+			fmt.Fprintln(os.Stderr, "Error: --period cannot be combined with --since or --until")
+			os.Exit(1)
+		}
+		start, end, err := resolvePeriod(periodFlag, timeNowFunc().In(location))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		since = start.Format(dateFormat)
+		until = end.Format(dateFormat)
+		sinceSource = "flag (--period)"
+	} else if setFlags["since"] {
+		sinceSource = "flag (--since)"
 	}
 
-	org, err := orgConfigFunc()
-	if err != nil {
-		org = defaultOrg
+	runStart := timeNowFunc()
+	if sinceLastRun {
+		if setFlags["since"] || periodFlag != "" {
+			fmt.Fprintln(os.Stderr, "Error: --since-last-run cannot be combined with --since or --period")
+			os.Exit(1)
+		}
+		if stateFile == "" {
+			stateFile = defaultStateFile()
+		}
+		lastRun, ok, err := readLastRun(stateFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if ok {
+			since = sinceForLastRun(lastRun)
+			sinceSource = "state file (" + stateFile + ")"
+		}
+		if debug {
+			fmt.Printf("Since last run (state file %s): %s\n", stateFile, since)
+		}
 	}
 
-	query := buildQuery("is:pr", login)
-	searchURL := fmt.Sprintf("search/issues?q=%s", query)
+	if looksLikeISOWeek(since) {
+		monday, err := resolveISOWeek(since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		since = monday
+	}
 
-	if debug {
-		fmt.Printf("Calling GitHub API with URL: %s\n", searchURL)
+	if until != "" {
+		if _, err := time.Parse(dateFormat, until); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --until must be a date in YYYY-MM-DD format, got '%s'\n", until)
+			os.Exit(1)
+		}
 	}
 
-	responseItems, err := fetchAllResults(client, searchURL)
-	if err != nil {
-		fmt.Println("Error fetching pull requests:", err)
-		return
+	if associationFlag != "" {
+		if err := validateAssociations(associationFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	if len(responseItems) == 0 {
-		fmt.Printf("No pull requests found for user '%s' in the '%s' organization.\n", login, org)
-		return
+	if filterFlag != "" {
+		pattern, err := regexp.Compile(filterFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --filter regular expression: %v\n", err)
+			os.Exit(1)
+		}
+		filterPattern = pattern
+	} else if filterBody {
+		fmt.Fprintln(os.Stderr, "Error: --filter-body requires --filter")
+		os.Exit(1)
 	}
 
-	if bodyOnly {
-		printBodies(responseItems, startOfPR, endOfPR)
-		return
+	if systemPromptFile != "" {
+		prompt, err := loadPromptFile(systemPromptFile, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --system-prompt-file: %v\n", err)
+			os.Exit(1)
+		}
+		systemPromptOverride = prompt
+	}
+	if userPromptFile != "" {
+		prompt, err := loadPromptFile(userPromptFile, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --user-prompt-file: %v\n", err)
+			os.Exit(1)
+		}
+		userPromptOverride = prompt
 	}
 
-	printPullRequestsAsCSV(responseItems)
-}
+	if dedupeStrip != "" {
+		pattern, err := regexp.Compile(dedupeStrip)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --dedupe-strip regular expression: %v\n", err)
+			os.Exit(1)
+		}
+		dedupeStripPattern = pattern
+	}
 
-func handleReviewsCommand(args []string, client GitHubClient) {
-	login, err := resolveLogin(args, client)
+	comma, err := parseCSVDelimiter(csvDelimiter)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	csvComma = comma
+	if setFlags["csv-delimiter"] && (outputFormat == "tsv" || exportPreset) {
+		fmt.Fprintln(os.Stderr, "Error: --csv-delimiter cannot be combined with --format tsv or --export, which use tabs")
+		os.Exit(1)
 	}
 
-	org, err := orgConfigFunc()
+	if exportPreset {
+		if setFlags["format"] || setFlags["split-by-repo"] {
+			fmt.Fprintln(os.Stderr, "Error: --export sets its own format and cannot be combined with --format or --split-by-repo")
+			os.Exit(1)
+		}
+		outputFormat = "tsv"
+		normalizeURLs = true
+	}
+
+	if outputFormat != "csv" && outputFormat != "tsv" && outputFormat != "json" && outputFormat != "table" && outputFormat != "contributors-json" && outputFormat != "svg" {
+		fmt.Fprintf(os.Stderr, "Error: --format must be 'csv', 'tsv', 'json', 'table', 'contributors-json', or 'svg', got '%s'\n", outputFormat)
+		os.Exit(1)
+	}
+	if outputFormat == "svg" && subcommand != "graph" {
+		fmt.Fprintln(os.Stderr, "Error: --format svg is only supported by 'graph'")
+		os.Exit(1)
+	}
+	if outputFormat == "svg" && calendarFlag {
+		fmt.Fprintln(os.Stderr, "Error: --format svg draws the weekly bars and cannot be combined with --calendar")
+		os.Exit(1)
+	}
+	if svgWidth < 200 || svgHeight < 150 {
+		fmt.Fprintf(os.Stderr, "Error: --width must be at least 200 and --height at least 150, got %dx%d\n", svgWidth, svgHeight)
+		os.Exit(1)
+	}
+	if outputFormat == "contributors-json" && subcommand != "all" {
+		fmt.Fprintln(os.Stderr, "Error: --format contributors-json is only supported by 'all'")
+		os.Exit(1)
+	}
+
+	if _, ok := summaryLengths[summaryLengthFlag]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: --length must be 'short', 'medium', or 'long', got '%s'\n", summaryLengthFlag)
+		os.Exit(1)
+	}
+
+	// Validate --visibility flag
+	if visibilityFlag != "" && visibilityFlag != "public" && visibilityFlag != "private" {
+		fmt.Fprintf(os.Stderr, "Error: --visibility must be 'public' or 'private', got '%s'\n", visibilityFlag)
+		os.Exit(1)
+	}
+
+	if _, ok := linkedTargets[linkedFlag]; linkedFlag != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Error: --linked must be 'pr' or 'issue', got '%s'\n", linkedFlag)
+		os.Exit(1)
+	}
+
+	if markers.entry == "" {
+		fmt.Fprintln(os.Stderr, "Error: --entry-delimiter must not be empty")
+		os.Exit(1)
+	}
+
+	if splitByRepo {
+		if outputDir == "" {
+			fmt.Fprintln(os.Stderr, "Error: --split-by-repo requires --output-dir")
+			os.Exit(1)
+		}
+		if outputFormat != "csv" || outputPath != "" {
+			fmt.Fprintln(os.Stderr, "Error: --split-by-repo only writes CSV and cannot be combined with --format or --output")
+			os.Exit(1)
+		}
+	}
+
+	if failFast && continueOnError {
+		fmt.Fprintln(os.Stderr, "Error: --fail-fast and --continue cannot be combined")
+		os.Exit(1)
+	}
+
+	if appendOutput && outputPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: --append requires --output")
+		os.Exit(1)
+	}
+	if appendOutput && (outputFormat == "json" || outputFormat == "contributors-json" || outputFormat == "svg") {
+		fmt.Fprintf(os.Stderr, "Error: --append adds rows to CSV, TSV, or table output; appending to a --format %s file would leave it invalid\n", outputFormat)
+		os.Exit(1)
+	}
+
+	if closedSince {
+		if setFlags["date-field"] && dateField != "closed" {
+			fmt.Fprintln(os.Stderr, "Error: --closed-since cannot be combined with --date-field")
+			os.Exit(1)
+		}
+		dateField = "closed"
+	}
+	if dateField != "created" && dateField != "updated" && dateField != "closed" {
+		fmt.Fprintf(os.Stderr, "Error: --date-field must be 'created', 'updated', or 'closed', got '%s'\n", dateField)
+		os.Exit(1)
+	}
+
+	if sortFlag != "created" && sortFlag != "comments" {
+		fmt.Fprintf(os.Stderr, "Error: --sort must be 'created' or 'comments', got '%s'\n", sortFlag)
+		os.Exit(1)
+	}
+
+	if maxConcurrency < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --max-concurrency must be at least 1, got %d\n", maxConcurrency)
+		os.Exit(1)
+	}
+	if setFlags["watch"] && watchInterval < minWatchInterval {
+		fmt.Fprintf(os.Stderr, "Error: --watch must be at least %s to stay under the search rate limit, got %s\n", minWatchInterval, watchInterval)
+		os.Exit(1)
+	}
+	if watchInterval > 0 && githubOutput {
+		fmt.Fprintln(os.Stderr, "Error: --watch cannot be used with --github-output")
+		os.Exit(1)
+	}
+	if releaseAnchor != "latest" && releaseAnchor != "earliest" {
+		fmt.Fprintf(os.Stderr, "Error: --release-anchor must be 'latest' or 'earliest', got '%s'\n", releaseAnchor)
+		os.Exit(1)
+	}
+	if setFlags["release-anchor"] && !strings.HasPrefix(since, sinceReleasePrefix) {
+		fmt.Fprintln(os.Stderr, "Error: --release-anchor requires --since release:REPOS")
+		os.Exit(1)
+	}
+	if windowsFlag != "" {
+		days, err := parseWindows(windowsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if setFlags["since"] || setFlags["until"] || periodFlag != "" || sinceLastRun {
+			fmt.Fprintln(os.Stderr, "Error: --windows sets its own date ranges and cannot be combined with --since, --until, --period, or --since-last-run")
+			os.Exit(1)
+		}
+		if pivotFlag || calendarFlag || outputFormat == "svg" || prometheusFlag || githubOutput {
+			fmt.Fprintln(os.Stderr, "Error: --windows prints its own comparison table and cannot be combined with --pivot, --calendar, --format svg, --prometheus, or --github-output")
+			os.Exit(1)
+		}
+		compareWindows = days
+		// Fetch the widest window once; the search's date:>since qualifier
+		// excludes the since day itself
+		since = timeNowFunc().In(location).AddDate(0, 0, -slices.Max(days)).Format(dateFormat)
+	}
+	if pivotFlag && (calendarFlag || outputFormat == "svg") {
+		fmt.Fprintln(os.Stderr, "Error: --pivot cannot be combined with --calendar or --format svg")
+		os.Exit(1)
+	}
+	if comparePrevious && (calendarFlag || pivotFlag || outputFormat == "svg" || graphType == "line" || len(compareWindows) > 0) {
+		fmt.Fprintln(os.Stderr, "Error: --compare-previous draws on the bar and dot graphs and cannot be combined with --calendar, --pivot, --format svg, --graph-type line, or --windows")
+		os.Exit(1)
+	}
+	if prometheusFlag && (githubOutput || watchInterval > 0) {
+		fmt.Fprintln(os.Stderr, "Error: --prometheus cannot be used with --github-output or --watch")
+		os.Exit(1)
+	}
+	if graphWeight != "count" && graphWeight != "reactions" {
+		fmt.Fprintf(os.Stderr, "Error: --weight must be 'count' or 'reactions', got '%s'\n", graphWeight)
+		os.Exit(1)
+	}
+	if graphWeight == "reactions" && calendarFlag {
+		fmt.Fprintln(os.Stderr, "Error: --calendar shades days by item count and cannot be combined with --weight reactions")
+		os.Exit(1)
+	}
+	if graphType != "bar" && graphType != "dot" && graphType != "line" {
+		fmt.Fprintf(os.Stderr, "Error: --graph-type must be 'bar', 'dot', or 'line', got '%s'\n", graphType)
+		os.Exit(1)
+	}
+	bucketWeeks, err := parseGranularity(granularityFlag)
 	if err != nil {
-		org = defaultOrg
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
+	weeksPerBucket = bucketWeeks
 
-	query := buildReviewQuery(login)
-	searchURL := fmt.Sprintf("search/issues?q=%s", query)
+	if minWeekCount < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --min-week-count must be zero or positive, got %d\n", minWeekCount)
+		os.Exit(1)
+	}
+	if _, ok := summaryFormats[summarizeFormat]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: --summarize-format must be 'markdown', 'json', or 'bullets', got '%s'\n", summarizeFormat)
+		os.Exit(1)
+	}
+	warnOverriddenPromptFlags(setFlags)
+	if len(redactAllow) > 0 && !redactFlag {
+		fmt.Fprintln(os.Stderr, "Error: --redact-allow requires --redact")
+		os.Exit(1)
+	}
+	if summarizeFormat == "json" && (preserveLinksFlag || summaryFooter != "") {
+		fmt.Fprintln(os.Stderr, "Error: --preserve-links and --summary-footer add Markdown and cannot be used with --summarize-format json")
+		os.Exit(1)
+	}
+	if titleTemplate != "" && (combineFlag || summarizeFormat == "json") {
+		fmt.Fprintln(os.Stderr, "Error: --title-template needs per-entry Markdown titles and cannot be used with --combine or --summarize-format json")
+		os.Exit(1)
+	}
+	if titleTemplate != "" && !strings.Contains(titleTemplate, "{title}") {
+		fmt.Fprintln(os.Stderr, "Error: --title-template must contain {title}, e.g. '#{number} {title}'")
+		os.Exit(1)
+	}
+	if summarizeParallel < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --parallel must be at least 1, got %d\n", summarizeParallel)
+		os.Exit(1)
+	}
+	if summarizeParallel > 1 && combineFlag {
+		fmt.Fprintln(os.Stderr, "Error: --parallel cannot be combined with --combine, which makes a single summary")
+		os.Exit(1)
+	}
+	if batchSize < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --batch-size must be at least 1, got %d\n", batchSize)
+		os.Exit(1)
+	}
+	if batchSize > 1 && combineFlag {
+		fmt.Fprintln(os.Stderr, "Error: --batch-size cannot be combined with --combine, which already sends as many entries per request as fit")
+		os.Exit(1)
+	}
+	if budget := summaryLengths[summaryLengthFlag].maxTokens; batchSize*budget > maxOutputTokensPerRequest {
+		fmt.Fprintf(os.Stderr, "Error: --batch-size %d needs up to %d output tokens per request with --length %s, over the %d limit; use at most %d\n",
+			batchSize, batchSize*budget, summaryLengthFlag, maxOutputTokensPerRequest, maxOutputTokensPerRequest/budget)
+		os.Exit(1)
+	}
+	if aiRetries < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --ai-retries must be zero or positive, got %d\n", aiRetries)
+		os.Exit(1)
+	}
+	if retryEmpty < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --retry-empty must be zero or positive, got %d\n", retryEmpty)
+		os.Exit(1)
+	}
+	if topRepos < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --top-repos must be zero or positive, got %d\n", topRepos)
+		os.Exit(1)
+	}
+
+	if maxItems < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --max-items must be zero or positive, got %d\n", maxItems)
+		os.Exit(1)
+	}
+
+	warnDeprecatedFlags(warningWriter())
+	if onlyPRs && onlyIssues {
+		fmt.Fprintln(os.Stderr, "Error: --only-prs and --only-issues cannot be used together")
+		os.Exit(1)
+	}
+	if (onlyPRs || onlyIssues) && subcommand != "" && subcommand != "all" {
+		fmt.Fprintf(warningWriter(), "Warning: --only-prs and --only-issues only apply to 'all' and are ignored by '%s'\n", subcommand)
+	}
+
+	warnIgnoredBase(warningWriter(), subcommand)
+	warnIgnoredLinked(warningWriter(), subcommand)
+	warnIgnoredMilestone(warningWriter(), subcommand)
+	if strings.TrimSpace(queryExtra) != "" {
+		fmt.Fprintf(warningWriter(), "Warning: --query-extra %q is added to authored pull request and issue searches as-is and is not validated\n", queryExtra)
+	}
+
+	// Only commands that search an organization need one; anything else,
+	// including an unknown command, is left to the dispatch below
+	switch subcommand {
+	case "pulls", "reviews", "issues", "discussions", "all", "attention", "footprint", "repo-activity", "graph":
+		if err := checkStrictOrg(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	if debug {
-		fmt.Printf("Calling GitHub API with URL: %s\n", searchURL)
+		fmt.Println("Debug mode enabled")
+		fmt.Printf("Arguments: %v\n", subcommandArgs)
+		fmt.Printf("Using AI model: %s\n", getEffectiveModel())
+		if visibilityFlag != "" {
+			fmt.Printf("Filtering by visibility: %s\n", visibilityFlag)
+		}
 	}
 
-	responseItems, err := fetchAllResults(client, searchURL)
+	// Refresh before creating the clients, which read the token once
+	if refreshTokenFlag {
+		refreshAuth(os.Stderr, &GhCliTokenFetcher{})
+	}
+
+	defaultClient, err := NewDefaultGitHubClient()
 	if err != nil {
-		fmt.Println("Error fetching reviews:", err)
-		return
+		fmt.Fprintf(os.Stderr, "Error initializing GitHub client: %v\n", err)
+		os.Exit(1)
 	}
 
-	if len(responseItems) == 0 {
-		fmt.Printf("No reviewed pull requests found for user '%s' in the '%s' organization.\n", login, org)
-		return
+	defaultGQLClient, err := NewDefaultGraphQLClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing GitHub GraphQL client: %v\n", err)
+		os.Exit(1)
 	}
 
-	if bodyOnly {
-		printBodies(responseItems, startOfReview, endOfReview)
-		return
+	limited := newLimitedClient(defaultClient, maxConcurrency)
+	var ghClient GitHubClient = limited
+	var gqlClient GraphQLClient = limited.graphQL(defaultGQLClient)
+	if useGraphQL {
+		ghClient = &graphQLUserClient{GitHubClient: ghClient, gql: gqlClient}
 	}
 
-	printPullRequestsAsCSV(responseItems)
-}
+	if checkClock || debug {
+		checkClockSkew(ghClient, warningWriter())
+	}
 
-func handleDiscussionsCommand(args []string, client GitHubClient, gqlClient GraphQLClient) {
-	login, err := resolveLogin(args, client)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+	if orgReposFilter != "" {
+		repos, err := fetchTopicRepos(ghClient, getEffectiveOrg(), orgReposFilter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(repos) == 0 {
+			fmt.Fprintf(warningWriter(), "Warning: no repositories in '%s' have the topic '%s', so no contributions will match\n", getEffectiveOrg(), orgReposFilter)
+		}
+		topicRepos = repos
 	}
 
-	org := getEffectiveOrg()
+	if strings.HasPrefix(since, sinceIssuePrefix) {
+		resolved, err := resolveSinceIssue(ghClient, strings.TrimPrefix(since, sinceIssuePrefix))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		since = resolved
+	} else if strings.HasPrefix(since, sinceReleasePrefix) {
+		resolved, err := resolveSinceRelease(ghClient, getEffectiveOrg(), strings.TrimPrefix(since, sinceReleasePrefix), releaseAnchor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		since = resolved
+	}
 
-	discussionItems, err := fetchDiscussions(gqlClient, login, org, since)
-	if err != nil {
-		fmt.Println("Error fetching discussions:", err)
-		return
+	defer closeOutputFile()
+
+	tokenFetcher := &GhCliTokenFetcher{}
+	httpClient := &http.Client{}
+	summarizer := NewAzureAISummarizer(httpClient, tokenFetcher)
+	var summaryService Summarizer = summarizer
+	if !noSummaryCache {
+		summaryService = &cachingSummarizer{Summarizer: summarizer, dir: filepath.Join(cacheDir(), "summaries"), model: summarizer.model}
+	}
+	// --raw must hit the API to print the response, and --watch must see
+	// fresh results on every redraw
+	if !noSessionCache && !rawOutput && watchInterval == 0 {
+		searchCacheDir = filepath.Join(cacheDir(), "searches")
+		searchCacheIdentity = currentCacheIdentity()
+		pruneSearchCache()
 	}
 
-	if len(discussionItems) == 0 {
-		fmt.Printf("No discussions found for user '%s' in the '%s' organization.\n", login, org)
+	if len(nonFlagArgs) == 0 {
+		printHelp(ghClient)
 		return
 	}
 
-	if bodyOnly {
-		printBodies(discussionItems, startOfDiscussion, endOfDiscussion)
-		return
+	// Commands that report a failure and then exit, such as repo-activity
+	// with failed logins, still list what they wrote in the manifest
+	exitFunc = func(code int) {
+		closeOutputFile()
+		finishManifest()
+		os.Exit(code)
+	}
+
+	cmd := subcommand
+	switch cmd {
+	case "pulls":
+		handlePullsCommand(subcommandArgs, ghClient)
+	case "reviews":
+		handleReviewsCommand(subcommandArgs, ghClient)
+	case "issues":
+		handleIssuesCommand(subcommandArgs, ghClient)
+	case "discussions":
+		handleDiscussionsCommand(subcommandArgs, ghClient, gqlClient)
+	case "all":
+		handleAllCommand(subcommandArgs, ghClient, gqlClient)
+	case "attention":
+		handleAttentionCommand(subcommandArgs, ghClient)
+	case "footprint":
+		handleFootprintCommand(subcommandArgs, ghClient)
+	case "repo-activity":
+		handleRepoActivityCommand(subcommandArgs, ghClient, gqlClient)
+	case "summarize":
+		handleSummarizeCommand(subcommandArgs, summaryService, promptOnly)
+	case "graph":
+		handleGraphCommand(subcommandArgs, ghClient, gqlClient)
+	case "models":
+		handleModelsCommand(summarizer)
+	case "config":
+		handleConfigCommand(subcommandArgs)
+	default:
+		fmt.Printf("Unknown command: %s\n", cmd)
+		printHelp(ghClient)
+		runFailed.Store(true)
 	}
 
-	printPullRequestsAsCSV(discussionItems)
+	finishManifest()
+
+	if sinceLastRun && runSucceeded() {
+		if err := writeLastRun(stateFile, runStart); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 }
 
-func handleIssuesCommand(args []string, client GitHubClient) {
+func handlePullsCommand(args []string, client GitHubClient) {
 	login, err := resolveLogin(args, client)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -518,7 +1611,7 @@ func handleIssuesCommand(args []string, client GitHubClient) {
 		org = defaultOrg
 	}
 
-	query := buildQuery("is:issue", login)
+	query := buildQuery("is:pr", login)
 	searchURL := fmt.Sprintf("search/issues?q=%s", query)
 
 	if debug {
@@ -527,802 +1620,5258 @@ func handleIssuesCommand(args []string, client GitHubClient) {
 
 	responseItems, err := fetchAllResults(client, searchURL)
 	if err != nil {
-		fmt.Println("Error fetching issues:", err)
+		fmt.Println("Error fetching pull requests:", err)
 		return
 	}
 
+	responseItems = capItems(sortItems(dedupeItems(filterItems(responseItems))))
+
+	if withReactions && !bodyOnly {
+		enrichWithReactions(client, responseItems)
+	}
+
 	if len(responseItems) == 0 {
-		fmt.Printf("No issues found for user '%s' in the '%s' organization.\n", login, org)
+		fmt.Printf("No pull requests found for user '%s' in the '%s' organization.\n", login, org)
 		return
 	}
 
 	if bodyOnly {
-		printBodies(responseItems, startOfIssue, endOfIssue)
+		printBodies(responseItems, markers.prStart, markers.prEnd)
+		return
+	}
+
+	if outputFormat == "json" {
+		printItemsAsJSON(responseItems)
 		return
 	}
 
-	printIssuesAsCSV(responseItems)
+	printItems(responseItems)
 }
 
-func handleAllCommand(args []string, client GitHubClient, gqlClient GraphQLClient) {
+func handleReviewsCommand(args []string, client GitHubClient) {
 	login, err := resolveLogin(args, client)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return
 	}
 
-	org := getEffectiveOrg()
+	org, err := orgConfigFunc()
+	if err != nil {
+		org = defaultOrg
+	}
 
-	results, err := fetchAllContributions(client, gqlClient, login, org, since)
+	query := buildReviewQuery(login)
+	searchURL := fmt.Sprintf("search/issues?q=%s", query)
+
+	if debug {
+		fmt.Printf("Calling GitHub API with URL: %s\n", searchURL)
+	}
+
+	responseItems, err := fetchAllResults(client, searchURL)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Println("Error fetching reviews:", err)
 		return
 	}
 
-	if bodyOnly {
-		printBodies(results.prItems, startOfPR, endOfPR)
-		printBodies(results.reviewItems, startOfReview, endOfReview)
-		printBodies(results.issueItems, startOfIssue, endOfIssue)
-		printBodies(results.discussionItems, startOfDiscussion, endOfDiscussion)
+	responseItems = dedupeItems(filterItems(responseItems))
+
+	if countOnly {
+		fmt.Println(len(responseItems))
 		return
 	}
 
-	writer := csv.NewWriter(os.Stdout)
-	defer writer.Flush()
+	responseItems = capItems(sortItems(responseItems))
 
-	// Write the header row
-	writer.Write([]string{"Type", "URL", "Title", "State"})
+	if withReactions && !bodyOnly {
+		enrichWithReactions(client, responseItems)
+	}
+	if withReviewState {
+		enrichWithReviewStates(client, responseItems, login)
+	}
 
-	// Write pull requests
-	for _, pr := range results.prItems {
-		writer.Write([]string{
-			"Pull Request",
-			pr.HTMLURL + " ",
-			pr.Title,
-			pr.State,
-		})
+	if len(responseItems) == 0 {
+		fmt.Printf("No reviewed pull requests found for user '%s' in the '%s' organization.\n", login, org)
+		return
 	}
 
-	// Write reviews
-	for _, review := range results.reviewItems {
-		writer.Write([]string{
-			"Review",
-			review.HTMLURL + " ",
-			review.Title,
-			review.State,
-		})
+	if bodyOnly {
+		printBodies(responseItems, markers.reviewStart, markers.reviewEnd)
+		return
 	}
 
-	// Write issues
-	for _, issue := range results.issueItems {
-		writer.Write([]string{
-			"Issue",
-			issue.HTMLURL + " ",
-			issue.Title,
-			issue.State,
-		})
+	if outputFormat == "json" {
+		printItemsAsJSON(responseItems)
+		return
 	}
 
-	// Write discussions
-	for _, disc := range results.discussionItems {
-		writer.Write([]string{
-			"Discussion",
-			disc.HTMLURL + " ",
-			disc.Title,
-			disc.State,
-		})
-	}
+	printItems(responseItems)
 }
 
-func handleSummarizeCommand(args []string, summarizer Summarizer, promptOnly bool) {
-	var input string
-	if len(args) > 1 {
-		input = args[1]
-	} else {
-		stdinInput, err := io.ReadAll(os.Stdin)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
-			return
-		}
-		input = string(stdinInput)
+func handleDiscussionsCommand(args []string, client GitHubClient, gqlClient GraphQLClient) {
+	login, err := resolveLogin(args, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
 	}
 
-	entries := strings.Split(input, entryDelimiter)
+	org := getEffectiveOrg()
 
-	for _, entry := range entries {
-		entry = strings.TrimSpace(entry) // Trim any extra whitespace
-		if entry == "" {
-			continue
-		}
+	discussionItems, err := fetchDiscussions(gqlClient, login, org, since)
+	if err != nil {
+		fmt.Println("Error fetching discussions:", err)
+		return
+	}
 
-		if promptOnly {
-			fmt.Println(BuildPrompt(entry))
-			continue
-		}
+	discussionItems = capItems(sortItems(dedupeItems(filterItems(discussionItems))))
 
-		summary, err := summarizer.Summarize(entry)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error summarizing entry: %v\n", err)
-			continue // Continue to the next entry on error
-		}
+	if len(discussionItems) == 0 {
+		fmt.Printf("No discussions found for user '%s' in the '%s' organization.\n", login, org)
+		return
+	}
+
+	if bodyOnly {
+		printBodies(discussionItems, markers.discussionStart, markers.discussionEnd)
+		return
+	}
 
-		fmt.Println(summary)
+	if outputFormat == "json" {
+		printItemsAsJSON(discussionItems)
+		return
 	}
+
+	// Discussions have no pull_request field, so without a label they
+	// would export as issues
+	printLabeledItems(discussionItems, "Discussion")
 }
 
-func handleGraphCommand(args []string, client GitHubClient, gqlClient GraphQLClient) {
+func handleIssuesCommand(args []string, client GitHubClient) {
 	login, err := resolveLogin(args, client)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return
 	}
 
-	org := getEffectiveOrg()
+	org, err := orgConfigFunc()
+	if err != nil {
+		org = defaultOrg
+	}
+
+	query := buildQuery("is:issue", login)
+	searchURL := fmt.Sprintf("search/issues?q=%s", query)
 
 	if debug {
-		fmt.Println("Debug mode enabled")
-		fmt.Printf("Debug: Creating graph for login '%s' in org '%s' since '%s'\n", login, org, since)
+		fmt.Printf("Calling GitHub API with URL: %s\n", searchURL)
 	}
 
-	results, err := fetchAllContributions(client, gqlClient, login, org, since)
+	responseItems, err := fetchAllResults(client, searchURL)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Println("Error fetching issues:", err)
 		return
 	}
 
-	prItems := results.prItems
-	reviewItems := results.reviewItems
-	issueItems := results.issueItems
-	discussionItems := results.discussionItems
+	responseItems, misfiled := splitMisfiledPulls(responseItems)
+	if debug && len(misfiled) > 0 {
+		fmt.Printf("Dropped %d pull requests from the issue results\n", len(misfiled))
+	}
 
-	// Check if there are any results to display
-	if len(prItems) == 0 && len(reviewItems) == 0 && len(issueItems) == 0 && len(discussionItems) == 0 {
-		fmt.Printf("No contributions found for user '%s' in the '%s' organization since %s.\n", login, org, since)
+	responseItems = capItems(sortItems(dedupeItems(filterItems(responseItems))))
+
+	if withReactions && !bodyOnly {
+		enrichWithReactions(client, responseItems)
+	}
+	if includeClosedByPR {
+		enrichWithClosingPRs(client, responseItems)
+	}
+	if withResponseTime {
+		enrichWithFirstResponses(client, responseItems, login)
+	}
+
+	if len(responseItems) == 0 {
+		fmt.Printf("No issues found for user '%s' in the '%s' organization.\n", login, org)
 		return
 	}
 
-	// Output heading only in debug mode
-	if debug {
-		fmt.Printf("Graph visualization for user '%s' in org '%s' since %s:\n\n", login, org, since)
+	if withResponseTime {
+		// On stderr so the summary doesn't corrupt CSV or JSON output
+		fmt.Fprintln(os.Stderr, responseTimeSummary(responseItems))
 	}
-	// Parse the since date and calculate stats
-	sinceDate, _ := time.Parse(dateFormat, since)
-	today := time.Now()
-	daysActive := int(today.Sub(sinceDate).Hours()/24) + 1
 
-	// Combined count of all contributions
-	totalContributions := len(prItems) + len(reviewItems) + len(issueItems) + len(discussionItems)
-	averageContributions := float64(totalContributions) / float64(daysActive)
+	if bodyOnly {
+		printBodies(responseItems, markers.issueStart, markers.issueEnd)
+		return
+	}
 
-	// Group contributions by week
-	weekMap := make(map[string]int)
-	weekStartDates := make(map[string]time.Time) // For sorting later
+	if outputFormat == "json" {
+		printItemsAsJSON(responseItems)
+		return
+	}
 
-	// Initialize all weeks in the range, regardless of whether they have contributions
-	totalWeeks := int(today.Sub(sinceDate).Hours()/(24*7)) + 1
-	for i := 0; i < totalWeeks; i++ {
-		weekStart := sinceDate.AddDate(0, 0, i*7)
-		weekEnd := weekStart.AddDate(0, 0, 6)
-		if weekEnd.After(today) {
-			weekEnd = today
-		}
-		weekKey := fmt.Sprintf("Week %2d (%s - %s)",
-			i+1,
-			weekStart.Format("Jan 02"),
-			weekEnd.Format("Jan 02"))
+	printItems(responseItems)
+}
 
-		// Use a consistent key format to avoid duplicates
-		weekMap[weekKey] = 0
-		weekStartDates[weekKey] = weekStart
+func handleAllCommand(args []string, client GitHubClient, gqlClient GraphQLClient) {
+	login, err := resolveLogin(args, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
 	}
 
-	// Process PRs
-	processItems(prItems, sinceDate, weekMap, weekStartDates)
-	// Process Reviews
-	processItems(reviewItems, sinceDate, weekMap, weekStartDates)
-	// Process Issues
-	processItems(issueItems, sinceDate, weekMap, weekStartDates)
-	// Process Discussions
-	processItems(discussionItems, sinceDate, weekMap, weekStartDates)
+	org := getEffectiveOrg()
 
-	// Sort the weeks chronologically
-	weeks := make([]string, 0, len(weekMap))
-	for week := range weekMap {
-		weeks = append(weeks, week)
+	results, err := fetchAllContributions(client, gqlClient, login, org, since, allCommandKinds())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
 	}
 
-	// Sort weeks by their start date
-	sort.Slice(weeks, func(i, j int) bool {
-		return weekStartDates[weeks[i]].Before(weekStartDates[weeks[j]])
-	})
-
-	// Track contributions by type and state for each week
-	weekContributionMap := make(map[string]map[contributionType]int)
-	for week := range weekMap {
-		weekContributionMap[week] = make(map[contributionType]int)
+	for _, items := range []*[]GitHubItem{&results.prItems, &results.reviewItems, &results.issueItems, &results.discussionItems} {
+		*items = sortItems(dedupeItems(*items))
 	}
+	capContributions(results)
 
-	// Count PRs by state for each week
-	countItemsByWeek(prItems, "pr", sinceDate, weekContributionMap)
-	// Count Reviews by state for each week
-	countItemsByWeek(reviewItems, "review", sinceDate, weekContributionMap)
-	// Count Issues by state for each week
-	countItemsByWeek(issueItems, "issue", sinceDate, weekContributionMap)
-	// Count Discussions by state for each week
-	countItemsByWeek(discussionItems, "discussion", sinceDate, weekContributionMap)
+	if withReactions && !bodyOnly {
+		enrichWithReactions(client, results.prItems)
+		enrichWithReactions(client, results.reviewItems)
+		enrichWithReactions(client, results.issueItems)
+	}
+	if includeClosedByPR {
+		enrichWithClosingPRs(client, results.issueItems)
+	}
+	if withReviewState {
+		enrichWithReviewStates(client, results.reviewItems, login)
+	}
 
-	// Track counts for summary
-	closedPRs := 0
-	openPRs := 0
-	closedReviews := 0
-	openReviews := 0
-	closedIssues := 0
-	openIssues := 0
-	closedDiscussions := 0
-	openDiscussions := 0
-
-	// Print the histogram with different symbols for different contribution types
-	for _, week := range weeks {
-		closedPR := weekContributionMap[week][contributionType{"pr", "closed"}]
-		openPR := weekContributionMap[week][contributionType{"pr", "open"}]
-		closedReview := weekContributionMap[week][contributionType{"review", "closed"}]
-		openReview := weekContributionMap[week][contributionType{"review", "open"}]
-		closedIssue := weekContributionMap[week][contributionType{"issue", "closed"}]
-		openIssue := weekContributionMap[week][contributionType{"issue", "open"}]
-		closedDiscussion := weekContributionMap[week][contributionType{"discussion", "closed"}]
-		openDiscussion := weekContributionMap[week][contributionType{"discussion", "open"}]
+	if bodyOnly {
+		printBodies(results.prItems, markers.prStart, markers.prEnd)
+		printBodies(results.reviewItems, markers.reviewStart, markers.reviewEnd)
+		printBodies(results.issueItems, markers.issueStart, markers.issueEnd)
+		printBodies(results.discussionItems, markers.discussionStart, markers.discussionEnd)
+		return
+	}
 
-		// Update summary counts
-		closedPRs += closedPR
-		openPRs += openPR
-		closedReviews += closedReview
-		openReviews += openReview
-		closedIssues += closedIssue
-		openIssues += openIssue
-		closedDiscussions += closedDiscussion
-		openDiscussions += openDiscussion
+	if outputFormat == "json" {
+		printContributionsAsJSON(results)
+		return
+	}
 
-		fmt.Printf("%s: ", week)
+	if outputFormat == "contributors-json" {
+		printJSON(map[string][]string{login: contributorTypes(results)})
+		return
+	}
 
-		// Print closed PRs with • symbol
-		for i := 0; i < closedPR; i++ {
-			fmt.Print("•")
+	var rows []itemRow
+	for _, section := range results.sections() {
+		for _, item := range section.items {
+			rows = append(rows, itemRow{label: section.label, item: item})
 		}
+	}
+	writeRows(append([]column{typeColumn}, itemColumns()...), rows)
+}
 
-		// Print open PRs with ○ symbol
-		for i := 0; i < openPR; i++ {
-			fmt.Print("○")
-		}
+func handleFootprintCommand(args []string, client GitHubClient) {
+	login, err := resolveLogin(args, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
 
-		// Print closed reviews with ◆ symbol
-		for i := 0; i < closedReview; i++ {
-			fmt.Print("◆")
-		}
+	org := getEffectiveOrg()
 
-		// Print open reviews with ◇ symbol
-		for i := 0; i < openReview; i++ {
-			fmt.Print("◇")
-		}
+	footprint, err := fetchFootprint(client, login)
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if len(footprint) == 0 {
+		fmt.Printf("No contributions found for user '%s' in the '%s' organization since %s.\n", login, org, since)
+		return
+	}
+	footprint = limitFootprint(footprint, topRepos)
 
-		// Print closed issues with ■ symbol
-		for i := 0; i < closedIssue; i++ {
-			fmt.Print("■")
-		}
+	if outputFormat == "json" {
+		printJSON(footprint)
+		return
+	}
 
-		// Print open issues with □ symbol
-		for i := 0; i < openIssue; i++ {
-			fmt.Print("□")
-		}
+	writer := newRowWriter()
+	defer writer.Flush()
 
-		// Print closed discussions with ▲ symbol
-		for i := 0; i < closedDiscussion; i++ {
-			fmt.Print("▲")
-		}
+	// Write the header row
+	writer.Write([]string{"Repository", "PRs", "Issues", "Total"})
 
-		// Print open discussions with △ symbol
-		for i := 0; i < openDiscussion; i++ {
-			fmt.Print("△")
-		}
+	for _, repo := range footprint {
+		writer.Write([]string{
+			repo.Repository,
+			fmt.Sprintf("%d", repo.PRs),
+			fmt.Sprintf("%d", repo.Issues),
+			fmt.Sprintf("%d", repo.Total),
+		})
+	}
+}
 
-		fmt.Print("\n")
+// fetchFootprint fetches the user's pull requests and issues in the window
+// and tallies them per repository.
+func fetchFootprint(client GitHubClient, login string) ([]repoFootprint, error) {
+	prSearchURL := fmt.Sprintf("search/issues?q=%s", buildQuery("is:pr", login))
+	issueSearchURL := fmt.Sprintf("search/issues?q=%s", buildQuery("is:issue", login))
+
+	if debug {
+		fmt.Printf("Calling GitHub API with URLs: %s, %s\n", prSearchURL, issueSearchURL)
 	}
-	fmt.Println()
 
-	// Print legend with only relevant symbols
-	fmt.Println("Legend:")
+	prItems, err := fetchAllResults(client, prSearchURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching pull requests: %w", err)
+	}
+	issueItems, err := fetchAllResults(client, issueSearchURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching issues: %w", err)
+	}
 
-	var legendParts []string
+	prItems = filterItems(prItems)
+	issueItems, misfiled := splitMisfiledPulls(filterItems(issueItems))
+	prItems = append(prItems, deduplicateItems(prItems, misfiled)...)
 
-	// Only include PR symbols in the legend if we have PRs
-	if len(prItems) > 0 {
-		if closedPRs > 0 {
-			legendParts = append(legendParts, "• = Closed PR")
+	return buildFootprint(prItems, issueItems), nil
+}
+
+func handleRepoActivityCommand(args []string, client GitHubClient, gqlClient GraphQLClient) {
+	logins := args[1:]
+	if len(logins) == 0 {
+		login, err := resolveLogin(args, client)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return
 		}
-		if openPRs > 0 {
-			legendParts = append(legendParts, "○ = Open PR")
+		logins = []string{login}
+	}
+
+	org := getEffectiveOrg()
+
+	if orgMembersOnly {
+		logins = filterOrgMembers(client, org, logins)
+		if len(logins) == 0 {
+			fmt.Printf("None of the users are members of the '%s' organization.\n", org)
+			return
 		}
 	}
 
-	// Only include Review symbols in the legend if we have Reviews
-	if len(reviewItems) > 0 {
-		if closedReviews > 0 {
-			legendParts = append(legendParts, "◆ = Closed Review")
+	var weights map[string]float64
+	if scoreFlag {
+		weights = scoreWeights()
+	}
+	scores := make(map[string]float64)
+
+	var fetched, failed []string
+	var footprints [][]repoFootprint
+	for _, login := range logins {
+		var footprint []repoFootprint
+		var err error
+		if scoreFlag {
+			// The score needs reviews, discussions, and states, so fetch
+			// everything and tally the footprint from the same results
+			var results *contributionResults
+			results, err = fetchAllContributions(client, gqlClient, login, org, since, allContributionKinds)
+			if err == nil {
+				footprint = buildFootprint(results.prItems, results.issueItems)
+				scores[login], _ = contributionScore(results, weights)
+			}
+		} else {
+			footprint, err = fetchFootprint(client, login)
 		}
-		if openReviews > 0 {
-			legendParts = append(legendParts, "◇ = Open Review")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v for user '%s'\n", err, login)
+			if failFast {
+				exitFunc(1)
+				return
+			}
+			failed = append(failed, login)
+			continue
 		}
+		fetched = append(fetched, login)
+		footprints = append(footprints, footprint)
+	}
+	if len(failed) > 0 {
+		// Report the rest first, then fail the run so batch jobs notice
+		defer func() {
+			fmt.Fprintf(os.Stderr, "Failed logins (%d of %d): %s\n", len(failed), len(logins), strings.Join(failed, ", "))
+			exitFunc(1)
+		}()
 	}
 
-	// Only include Issue symbols in the legend if we have Issues
-	if len(issueItems) > 0 {
-		if closedIssues > 0 {
-			legendParts = append(legendParts, "■ = Closed Issue")
+	repos, rows := buildActivityMatrix(fetched, footprints, topRepos)
+	if len(repos) == 0 {
+		fmt.Printf("No contributions found for %s in the '%s' organization since %s.\n", strings.Join(logins, ", "), org, since)
+		return
+	}
+
+	if scoreFlag {
+		for i := range rows {
+			if score, ok := scores[rows[i].Login]; ok {
+				rows[i].Score = &score
+			}
 		}
-		if openIssues > 0 {
-			legendParts = append(legendParts, "□ = Open Issue")
+		// --score is a leaderboard, highest first
+		sort.SliceStable(rows, func(i, j int) bool { return *rows[i].Score > *rows[j].Score })
+		// The weights behind the numbers are part of the result, so
+		// --quiet doesn't hide them; stderr keeps CSV and JSON clean
+		fmt.Fprintf(os.Stderr, "Score = %s\n", scoreFormula(weights))
+	}
+
+	if authorEmail && authorCompany {
+		fmt.Fprintln(warningWriter(), "Warning: --author-email is ignored with --author-company, which groups users by company")
+	} else if authorEmail {
+		emails := fetchEmails(client, fetched)
+		for i := range rows {
+			rows[i].Email = emails[rows[i].Login]
 		}
 	}
 
-	// Only include Discussion symbols in the legend if we have Discussions
-	if len(discussionItems) > 0 {
-		if closedDiscussions > 0 {
-			legendParts = append(legendParts, "▲ = Closed Discussion")
+	if authorCompany {
+		companyRows := groupActivityByCompany(rows, fetchCompanies(client, fetched))
+		if outputFormat == "json" {
+			printJSON(companyRows)
+			return
 		}
-		if openDiscussions > 0 {
-			legendParts = append(legendParts, "△ = Open Discussion")
+		writer := newRowWriter()
+		defer writer.Flush()
+		header := append(append([]string{"Company", "Users"}, repos...), "Total")
+		if scoreFlag {
+			header = append(header, "Score")
+		}
+		writer.Write(header)
+		for _, row := range companyRows {
+			fields := []string{row.Company, strings.Join(row.Logins, " ")}
+			for _, repo := range repos {
+				fields = append(fields, fmt.Sprintf("%d", row.Repos[repo]))
+			}
+			fields = append(fields, fmt.Sprintf("%d", row.Total))
+			if row.Score != nil {
+				fields = append(fields, strconv.FormatFloat(*row.Score, 'f', -1, 64))
+			}
+			writer.Write(fields)
 		}
+		return
 	}
 
-	fmt.Println(strings.Join(legendParts, "  "))
-	fmt.Println()
-
-	// Print summary with date information
-	fmt.Printf("Total Contributions: %d over %d days (avg: %.2f per day)\n",
-		totalContributions,
-		daysActive,
-		averageContributions)
+	if outputFormat == "json" {
+		printJSON(rows)
+		return
+	}
 
-	fmt.Printf("PRs: %d total (%d closed, %d open)\n",
-		len(prItems), closedPRs, openPRs)
+	writer := newRowWriter()
+	defer writer.Flush()
 
-	fmt.Printf("Reviews: %d total (%d closed, %d open)\n",
-		len(reviewItems), closedReviews, openReviews)
+	header := []string{"User"}
+	if authorEmail {
+		header = append(header, "Email")
+	}
+	header = append(append(header, repos...), "Total")
+	if scoreFlag {
+		header = append(header, "Score")
+	}
+	writer.Write(header)
+	for _, row := range rows {
+		fields := []string{row.Login}
+		if authorEmail {
+			fields = append(fields, row.Email)
+		}
+		for _, repo := range repos {
+			fields = append(fields, fmt.Sprintf("%d", row.Repos[repo]))
+		}
+		fields = append(fields, fmt.Sprintf("%d", row.Total))
+		if row.Score != nil {
+			fields = append(fields, strconv.FormatFloat(*row.Score, 'f', -1, 64))
+		}
+		writer.Write(fields)
+	}
+}
 
-	fmt.Printf("Issues: %d total (%d closed, %d open)\n",
-		len(issueItems), closedIssues, openIssues)
-
-	fmt.Printf("Discussions: %d total (%d closed, %d open)\n",
-		len(discussionItems), closedDiscussions, openDiscussions)
-
-	// Display web URL for the GitHub search
-	webURL := buildWebURL("", login)
-	fmt.Printf("\nView in GitHub: %s\n", webURL)
-}
+func handleAttentionCommand(args []string, client GitHubClient) {
+	login, err := resolveLogin(args, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
 
-var orgConfigFunc = getOrgFromConfig // Default to the actual implementation
-var timeNowFunc = time.Now         // Default to the actual time.Now implementation
+	org := getEffectiveOrg()
 
-// Function to read the organization from the GitHub CLI config file
-func getOrgFromConfig() (string, error) {
-	usr, err := user.Current()
+	items, err := fetchAttentionItems(client, login)
 	if err != nil {
-		return "", fmt.Errorf("error getting current user: %w", err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
 	}
 
-	configPath := filepath.Join(usr.HomeDir, ".config", "gh", "config.yml")
-	configData, err := os.ReadFile(configPath)
-	if err != nil {
-		return "", fmt.Errorf("error reading config file: %w", err)
+	if limit := maxItemsLimit(len(items)); limit < len(items) {
+		items = items[:limit]
 	}
 
-	var config struct {
-		Extensions map[string]struct {
-			Org string `yaml:"org"`
-		} `yaml:"extensions"`
+	if len(items) == 0 {
+		fmt.Printf("Nothing needs attention for user '%s' in the '%s' organization.\n", login, org)
+		return
 	}
 
-	err = yaml.Unmarshal(configData, &config)
-	if err != nil {
-		return "", fmt.Errorf("error parsing config file: %w", err)
+	if bodyOnly {
+		for _, item := range items {
+			if item.itemType == "Pull Request" {
+				printBodies([]GitHubItem{item.item}, markers.prStart, markers.prEnd)
+			} else {
+				printBodies([]GitHubItem{item.item}, markers.issueStart, markers.issueEnd)
+			}
+		}
+		return
 	}
 
-	// Assuming the extension name is "gh-contrib"
-	if extConfig, ok := config.Extensions["gh-contrib"]; ok {
-		return extConfig.Org, nil
+	rows := make([]itemRow, len(items))
+	for i, item := range items {
+		rows[i] = itemRow{label: item.itemType, reason: item.reason, item: item.item}
 	}
+	writeRows([]column{typeColumn, urlColumn, titleColumn, reasonColumn}, rows)
+}
 
-	return "", fmt.Errorf("organization not found in config file under extensions")
+// sinceSource describes where the effective --since came from, for config
+// resolve.
+var sinceSource = "default (30 days ago)"
+
+// resolvedSetting is a setting's effective value and where it came from.
+type resolvedSetting struct {
+	Setting string `json:"setting"`
+	Value   string `json:"value"`
+	Source  string `json:"source"`
 }
 
-// resolveLogin returns the login from args[1] if provided, otherwise fetches the authenticated user.
-func resolveLogin(args []string, client GitHubClient) (string, error) {
-	if len(args) >= 2 {
-		return args[1], nil
+// resolveSettings reports the effective org, model, since, and endpoint
+// with their sources, following the same precedence as getEffectiveOrg and
+// getEffectiveModel.
+func resolveSettings() []resolvedSetting {
+	org := resolvedSetting{Setting: "org", Value: defaultOrg, Source: "default"}
+	if orgFlag != "" {
+		org.Value, org.Source = orgFlag, "flag (--org)"
+	} else if configured, err := orgConfigFunc(); err == nil {
+		org.Value, org.Source = configured, "config"
 	}
-	response := struct{ Login string }{}
-	if err := client.Get("user", &response); err != nil {
-		return "", fmt.Errorf("error fetching logged-in user: %w", err)
+
+	model := resolvedSetting{Setting: "model", Value: getEffectiveModel(), Source: "default"}
+	switch {
+	case aiModelFlag != "":
+		model.Source = "flag (--ai-model)"
+	case modelFlag != "":
+		model.Source = "flag (--model)"
+	case model.Value != defaultModel:
+		// The config lookup falls back to the default itself, so a configured
+		// value equal to the default reads as the default
+		model.Source = "config"
+	}
+
+	return []resolvedSetting{
+		org,
+		model,
+		{Setting: "since", Value: since, Source: sinceSource},
+		{Setting: "endpoint", Value: aiEndpoint, Source: "default"},
 	}
-	return response.Login, nil
 }
 
-func getEffectiveOrg() string {
-	if orgFlag != "" {
-		return orgFlag // Use the --org flag if provided
+// handleConfigCommand handles config subcommands. Only resolve exists: it
+// prints each setting's effective value and its source.
+func handleConfigCommand(args []string) {
+	if len(args) < 2 || args[1] != "resolve" {
+		fmt.Fprintln(os.Stderr, "Usage: gh contrib config resolve")
+		return
 	}
 
-	org, err := orgConfigFunc()
-	if err != nil {
-		return defaultOrg // Default to 'github' if not found
+	settings := resolveSettings()
+	if outputFormat == "json" {
+		printJSON(settings)
+		return
 	}
 
-	return org
+	writer := newRowWriter()
+	defer writer.Flush()
+	writer.Write([]string{"Setting", "Value", "Source"})
+	for _, setting := range settings {
+		writer.Write([]string{setting.Setting, setting.Value, setting.Source})
+	}
 }
 
-func getEffectiveModel() string {
-	if modelFlag != "" {
-		return modelFlag // Use the --model flag if provided
+// handleModelsCommand prints the model IDs the AI endpoint serves, falling
+// back to the current model when the endpoint can't list them.
+func handleModelsCommand(lister ModelLister) {
+	models, err := lister.ListModels()
+	if errors.Is(err, errModelListingUnsupported) {
+		fmt.Println("This endpoint doesn't support listing models.")
+		fmt.Printf("Current model: %s\n", getEffectiveModel())
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing models: %v\n", err)
+		return
+	}
+
+	current := getEffectiveModel()
+	for _, id := range models {
+		if id == current {
+			fmt.Printf("%s (current)\n", id)
+		} else {
+			fmt.Println(id)
+		}
 	}
-	return modelConfigFunc() // Use the configured or default model
 }
 
-// visibilityFilter returns the search qualifier for the current visibility flag.
-func visibilityFilter() string {
-	if visibilityFlag != "" {
-		return fmt.Sprintf(" is:%s", visibilityFlag)
+func handleSummarizeCommand(args []string, summarizer Summarizer, promptOnly bool) {
+	var input string
+	if len(args) > 1 {
+		input = args[1]
+	} else {
+		stdinInput, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+			return
+		}
+		input = string(stdinInput)
+	}
+
+	var entries []string
+	for _, entry := range strings.Split(input, markers.entry) {
+		entry = strings.TrimSpace(entry) // Trim any extra whitespace
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	if estimateFlag {
+		printSummaryEstimate(estimateSummarize(entries))
+		return
+	}
+
+	// With --edit, collect the summaries and print them after the user edits them
+	var out io.Writer = os.Stdout
+	if editor := summaryEditor(); editor != "" && !promptOnly {
+		var collected bytes.Buffer
+		out = &collected
+		defer func() { fmt.Print(editText(editor, collected.String())) }()
+	}
+	// The footer only describes summaries that were written; it runs
+	// before the editor opens
+	summarized := false
+	if summaryFooter != "" && !promptOnly && !jsonStream {
+		defer func() {
+			if summarized {
+				writeSummaryFooter(out, summaryFooter)
+			}
+		}()
+	}
+
+	if combineFlag {
+		if promptOnly {
+			fmt.Println(BuildCombinedPrompt(strings.Join(entries, combineSeparator)))
+			return
+		}
+		summary, err := summarizeCombined(summarizer, entries)
+		if err == nil && preserveLinksFlag {
+			summary = preserveLinks(summary, strings.Join(entries, "\n"))
+		}
+		summary = redact(summary)
+		if jsonStream {
+			writeSummaryLine(0, summary, err)
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error summarizing entries: %v\n", err)
+			return
+		}
+		fmt.Fprintln(out, summary)
+		summarized = true
+		return
+	}
+
+	// Each batch is one request; with the default --batch-size 1, one per entry
+	batches := batchEntries(entries, batchSize, combineCharBudget)
+	if len(batches) > (len(entries)+batchSize-1)/batchSize {
+		fmt.Fprintf(warningWriter(), "Warning: some batches hold fewer than %d entries to stay within the input budget of about %d characters\n", batchSize, combineCharBudget)
+	}
+	if promptOnly {
+		for _, batch := range batches {
+			fmt.Println(BuildPrompt(strings.Join(batch, combineSeparator)))
+		}
+		return
+	}
+
+	// Summarize up to --parallel batches at once (capped by the shared
+	// --max-concurrency), but report them in input order: each result is
+	// printed once it and all earlier ones are done
+	type batchResult struct {
+		summaries []string
+		err       error
+	}
+	results := make([]batchResult, len(batches))
+	done := make([]chan struct{}, len(batches))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	go runBounded(len(batches), min(summarizeParallel, maxConcurrency), func(i int) {
+		defer close(done[i])
+		summaries, err := summarizeBatch(summarizer, batches[i])
+		results[i] = batchResult{summaries, err}
+	})
+
+	// With --summarize-format json, each request answers with its own array;
+	// they are merged so stdout is a single JSON document
+	var jsonSummaries []string
+	first := 0 // Index of the batch's first entry
+	for i := range batches {
+		<-done[i]
+		summaries, err := results[i].summaries, results[i].err
+		if jsonStream {
+			if err != nil {
+				writeSummaryLine(first, "", err)
+			}
+			for j, summary := range summaries {
+				writeSummaryLine(first+j, summary, nil)
+			}
+			first += len(batches[i])
+			continue
+		}
+		first += len(batches[i])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error summarizing entry: %v\n", err)
+			continue // Continue to the next entry on error
+		}
+
+		if summarizeFormat == "json" {
+			jsonSummaries = append(jsonSummaries, summaries...)
+			continue
+		}
+		for _, summary := range summaries {
+			fmt.Fprintln(out, summary)
+		}
+		summarized = true
+	}
+
+	if len(jsonSummaries) > 0 {
+		merged, err := mergeJSONSummaries(jsonSummaries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging JSON summaries: %v\n", err)
+			return
+		}
+		fmt.Fprintln(out, merged)
+		summarized = true
 	}
-	return ""
 }
 
-func buildQuery(itemType, login string) string {
-	org := getEffectiveOrg() // Use the effective organization
-	query := fmt.Sprintf("%s org:%s author:%s sort:created-desc", itemType, org, login)
-	query += visibilityFilter()
-	if since != "" {
-		query += fmt.Sprintf(" created:>%s", since)
-		query = url.QueryEscape(query)
+// mergeJSONSummaries merges the --summarize-format json answers of several
+// requests into one indented array. An answer that is an array contributes
+// its elements; any other value, such as a lone object, is added as is.
+func mergeJSONSummaries(summaries []string) (string, error) {
+	merged := []json.RawMessage{}
+	for _, summary := range summaries {
+		var elements []json.RawMessage
+		if err := json.Unmarshal([]byte(summary), &elements); err == nil {
+			merged = append(merged, elements...)
+			continue
+		}
+		if !json.Valid([]byte(summary)) {
+			return "", errInvalidJSONSummary
+		}
+		merged = append(merged, json.RawMessage(summary))
 	}
-	return query
+	data, err := json.MarshalIndent(merged, "", "  ")
+	return string(data), err
 }
 
-func buildReviewQuery(login string) string {
-	org := getEffectiveOrg()
-	query := fmt.Sprintf("is:pr org:%s reviewed-by:%s sort:created-desc", org, login)
-	query += visibilityFilter()
-	if since != "" {
-		query += fmt.Sprintf(" created:>%s", since)
-		query = url.QueryEscape(query)
+// summarizeBatch summarizes the entries of one --batch-size batch in a single
+// request and returns one summary per entry, each with --preserve-links,
+// --title-template, and --redact applied. When an answer can't be split on
+// batchSummaryDelimiter into one section per entry, the whole answer is
+// returned as the batch's only summary. --summarize-format json answers
+// are never split, since the array already holds one object per entry.
+func summarizeBatch(summarizer Summarizer, batch []string) ([]string, error) {
+	text := strings.Join(batch, combineSeparator)
+	summary, err := summarizer.Summarize(text)
+	if err != nil {
+		return nil, err
 	}
-	return query
+
+	summaries, sources := []string{summary}, []string{text}
+	numbers := [][]string{entryNumbers(batch)}
+	if batchSize > 1 && summarizeFormat != "json" {
+		sections := splitBatchSummary(summary)
+		if len(sections) == len(batch) {
+			summaries, sources, numbers = sections, batch, nil
+			for _, number := range entryNumbers(batch) {
+				numbers = append(numbers, []string{number})
+			}
+		} else {
+			if debug {
+				fmt.Printf("Debug: expected %d summaries in the batch answer, got %d; printing it as one section\n", len(batch), len(sections))
+			}
+			summaries[0] = strings.Join(sections, "\n\n")
+		}
+	}
+
+	for i := range summaries {
+		if preserveLinksFlag {
+			summaries[i] = preserveLinks(summaries[i], sources[i])
+		}
+		if titleTemplate != "" {
+			summaries[i] = applyTitleTemplate(summaries[i], numbers[i])
+		}
+		summaries[i] = redact(summaries[i])
+	}
+	return summaries, nil
 }
 
-// buildWebURL constructs a GitHub web URL for the given query
-func buildWebURL(itemType, login string) string {
-	org := getEffectiveOrg()
-	var query string
-	if itemType != "" {
-		query = fmt.Sprintf("%s org:%s author:%s sort:updated-desc", itemType, org, login)
-	} else {
-		query = fmt.Sprintf("org:%s author:%s sort:updated-desc", org, login)
+// splitBatchSummary splits a batch answer on batchSummaryDelimiter, dropping
+// empty sections such as the one after the final delimiter.
+func splitBatchSummary(summary string) []string {
+	var sections []string
+	for _, section := range strings.Split(summary, batchSummaryDelimiter) {
+		if section = strings.TrimSpace(section); section != "" {
+			sections = append(sections, section)
+		}
 	}
-	query += visibilityFilter()
-	if since != "" {
-		// Use date range format: created:start..end where end is today
-		today := timeNowFunc().Format(dateFormat)
-		query += fmt.Sprintf(" created:%s..%s", since, today)
+	return sections
+}
+
+// urlPattern matches http(s) URLs in summarize input. It stops at
+// whitespace, quotes, angle brackets, and the brackets of Markdown links;
+// trailing sentence punctuation is trimmed by extractURLs.
+var urlPattern = regexp.MustCompile(`https?://[^\s<>()\[\]"'\x60]+`)
+
+// extractURLs returns the distinct URLs in text, in order of appearance.
+func extractURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		link := strings.TrimRight(match, ".,;:!?*_")
+		if !seen[link] {
+			seen[link] = true
+			urls = append(urls, link)
+		}
 	}
-	// URL encode the query for the web interface
-	encodedQuery := url.QueryEscape(query)
-	return fmt.Sprintf("https://github.com/issues?q=%s", encodedQuery)
+	return urls
 }
 
-// deduplicateItems removes items from candidates that already appear in existing (by HTMLURL).
-func deduplicateItems(existing, candidates []GitHubItem) []GitHubItem {
-	seen := make(map[string]bool, len(existing))
-	for _, item := range existing {
-		seen[item.HTMLURL] = true
+// linksHeading is the section preserveLinks lists missing URLs under.
+const linksHeading = "### Links"
+
+// preserveLinks lists every URL from source that the model dropped or
+// rewrote under a "### Links" section of summary, so each summary stays
+// traceable to its artifacts. URLs are compared exactly, so a summary that
+// only links .../pull/23 still gets .../pull/2. They are added to the end of
+// the summary's own Links section when it has one, and to a new one
+// otherwise. The summary is returned unchanged when it already contains
+// them all.
+func preserveLinks(summary, source string) string {
+	present := make(map[string]bool)
+	for _, link := range extractURLs(summary) {
+		present[link] = true
 	}
-	var result []GitHubItem
-	for _, item := range candidates {
-		if !seen[item.HTMLURL] {
-			result = append(result, item)
+	var missing strings.Builder
+	for _, link := range extractURLs(source) {
+		if !present[link] {
+			missing.WriteString("- " + link + "\n")
 		}
 	}
-	return result
+	if missing.Len() == 0 {
+		return summary
+	}
+
+	lines := strings.Split(strings.TrimRight(summary, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != linksHeading {
+			continue
+		}
+		// Add after the section's last list item, before the next heading or
+		// anything that follows the list, such as the template's <br />
+		end, indent := i+1, ""
+		for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "#"); j++ {
+			if item := strings.TrimSpace(lines[j]); strings.HasPrefix(item, "- ") {
+				end, indent = j+1, strings.TrimSuffix(lines[j], strings.TrimLeft(lines[j], " \t"))
+			}
+		}
+		added := strings.Split(strings.TrimRight(missing.String(), "\n"), "\n")
+		for k := range added {
+			added[k] = indent + added[k]
+		}
+		return strings.Join(slices.Concat(lines[:end], added, lines[end:]), "\n")
+	}
+	return strings.Join(lines, "\n") + "\n\n" + linksHeading + "\n" + strings.TrimRight(missing.String(), "\n")
 }
 
-// DiscussionSearchResponse represents the GraphQL response for discussion search.
-type DiscussionSearchResponse struct {
-	Search struct {
-		Nodes []struct {
-			Title     string `json:"title"`
-			URL       string `json:"url"`
-			Body      string `json:"body"`
-			Number    int    `json:"number"`
-			CreatedAt string `json:"createdAt"`
-			ClosedAt  string `json:"closedAt"`
-			Closed    bool   `json:"closed"`
-		} `json:"nodes"`
-		PageInfo struct {
-			HasNextPage bool   `json:"hasNextPage"`
-			EndCursor   string `json:"endCursor"`
-		} `json:"pageInfo"`
-	} `json:"search"`
+// summaryTitlePatterns match the generated title of each summary, per
+// --summarize-format, with the title text as the first group.
+var summaryTitlePatterns = map[string]*regexp.Regexp{
+	"markdown": regexp.MustCompile(`(?m)^## (.+)$`),
+	"bullets":  regexp.MustCompile(`(?m)^- \*\*(.+?)\*\*`),
 }
 
-func fetchDiscussions(gqlClient GraphQLClient, login, org, sinceDate string) ([]GitHubItem, error) {
-	query := fmt.Sprintf("author:%s org:%s sort:created-desc", login, org)
-	query += visibilityFilter()
-	if sinceDate != "" {
-		query += fmt.Sprintf(" created:>%s", sinceDate)
+// entryNumbers returns the item number of each entry, from the "Title #N"
+// line that follows a --body-only start marker, or "" for an entry without
+// one.
+func entryNumbers(entries []string) []string {
+	numbers := make([]string, len(entries))
+	for i, entry := range entries {
+		lines := strings.SplitN(entry, "\n", 3)
+		if len(lines) < 2 {
+			continue
+		}
+		switch strings.TrimSpace(lines[0]) {
+		case markers.prStart, markers.reviewStart, markers.issueStart, markers.discussionStart:
+			if m := entryNumberPattern.FindStringSubmatch(strings.TrimSpace(lines[1])); m != nil {
+				numbers[i] = m[1]
+			}
+		}
 	}
+	return numbers
+}
 
-	const graphqlQuery = `
-query($query: String!, $first: Int!, $after: String) {
-  search(query: $query, type: DISCUSSION, first: $first, after: $after) {
-    nodes {
-      ... on Discussion {
-        title
-        url
-        body
-        number
-        createdAt
-        closedAt
-        closed
-      }
-    }
-    pageInfo {
-      hasNextPage
-      endCursor
-    }
-  }
-}`
+// entryNumberPattern matches the "#N" that ends a --body-only title line.
+var entryNumberPattern = regexp.MustCompile(` #(\d+)$`)
+
+// applyTitleTemplate rewrites the generated titles in summary with
+// --title-template, filling {number} from numbers and {title} from the
+// model. Titles are matched to entries in order, so a batch is only
+// rewritten when it has one title per entry; titles whose entry has no
+// number keep the model's text.
+func applyTitleTemplate(summary string, numbers []string) string {
+	pattern := summaryTitlePatterns[summarizeFormat]
+	if pattern == nil || len(pattern.FindAllStringIndex(summary, -1)) != len(numbers) {
+		return summary
+	}
+	k := 0
+	return pattern.ReplaceAllStringFunc(summary, func(match string) string {
+		number := numbers[k]
+		k++
+		if number == "" {
+			return match
+		}
+		m := pattern.FindStringSubmatchIndex(match)
+		title := strings.NewReplacer("{number}", number, "{title}", match[m[2]:m[3]]).Replace(titleTemplate)
+		return match[:m[2]] + title + match[m[3]:]
+	})
+}
 
-	var allItems []GitHubItem
-	var cursor *string
+// emailPattern matches email addresses for --redact.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@((?:[A-Za-z0-9-]+\.)+[A-Za-z]{2,})`)
 
-	for {
-		variables := map[string]interface{}{
-			"query": query,
-			"first": 100,
+// redact replaces URLs and email addresses in text with placeholders for
+// --redact, keeping those on a --redact-allow domain. It returns text
+// unchanged when --redact is off.
+func redact(text string) string {
+	if !redactFlag {
+		return text
+	}
+	text = urlPattern.ReplaceAllStringFunc(text, func(match string) string {
+		link := strings.TrimRight(match, ".,;:!?*_")
+		if parsed, err := url.Parse(link); err == nil && redactAllowed(parsed.Hostname()) {
+			return match
 		}
-		if cursor != nil {
-			variables["after"] = *cursor
+		return "[redacted link]" + match[len(link):]
+	})
+	return emailPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if redactAllowed(emailPattern.FindStringSubmatch(match)[1]) {
+			return match
 		}
+		return "[redacted email]"
+	})
+}
 
-		var resp DiscussionSearchResponse
-		if err := gqlClient.Do(graphqlQuery, variables, &resp); err != nil {
-			return nil, fmt.Errorf("error querying discussions: %w", err)
+// redactAllowed reports whether host is a --redact-allow domain or one of
+// its subdomains.
+func redactAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range redactAllow {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSummaryFooter writes the scope of a summary of login's work: the org
+// and date range it covers and links to the full activity on GitHub.
+func writeSummaryFooter(w io.Writer, login string) {
+	end := until
+	if end == "" {
+		end = timeNowFunc().In(location).Format(dateFormat)
+	}
+	fmt.Fprintln(w, "\n---")
+	fmt.Fprintf(w, "Scope: %s in %s, %s to %s\n", login, getEffectiveOrg(), since, end)
+	fmt.Fprintf(w, "Pull requests: %s\n", buildWebURL("is:pr", login))
+	fmt.Fprintf(w, "Issues: %s\n", buildWebURL("is:issue", login))
+	fmt.Fprintf(w, "All activity: %s\n", buildWebURL("", login))
+}
+
+// summaryEditor returns the $EDITOR command to open summaries in with
+// --edit, or "" to print them directly: when --edit is off, stdout isn't a
+// terminal, or no editor is configured.
+func summaryEditor() string {
+	if !editFlag || jsonStream || !stdoutIsTerminalFunc() {
+		return ""
+	}
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		fmt.Fprintln(warningWriter(), "Warning: --edit needs $EDITOR to be set; printing summaries instead")
+	}
+	return editor
+}
+
+var (
+	stdoutIsTerminalFunc = func() bool { return term.FromEnv().IsTerminalOutput() } // Overridable for tests
+	runEditorFunc        = runEditor                                                // Overridable for tests
+)
+
+// runEditor opens path in editor, attached to the terminal, and waits for it
+// to exit. Like git, it runs editor through the shell so it may include
+// arguments (e.g. "code --wait"). Summarize input usually arrives on a pipe,
+// so the editor reads from /dev/tty when there is one.
+func runEditor(editor, path string) error {
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+	}
+	return cmd.Run()
+}
+
+// editText writes text to a temporary file, opens it in editor, and returns
+// the saved content. If anything fails, it warns and returns text unchanged.
+func editText(editor, text string) string {
+	f, err := os.CreateTemp("", "gh-contrib-summary-*.md")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating temp file for --edit: %v\n", err)
+		return text
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text)
+	f.Close()
+	if err == nil {
+		err = runEditorFunc(editor, f.Name())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error editing summaries: %v\n", err)
+		return text
+	}
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading edited summaries: %v\n", err)
+		return text
+	}
+	return string(edited)
+}
+
+// summaryEstimate is the --estimate report for a summarize run. Token counts
+// use the rough ~4 characters per token heuristic and are computed locally.
+type summaryEstimate struct {
+	entryTokens  []int // Tokens in each entry on its own
+	systemTokens int   // Tokens in the system prompt, sent with every request
+	requests     int
+	inputTokens  int // Everything sent: system and user prompts for every request
+	outputTokens int // Upper bound: the --length budget for every request
+}
+
+// estimateTokens approximates the token count of s.
+func estimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// estimateSummarize works out the requests summarize would make for entries,
+// including the extra combine step when --combine input is over budget.
+func estimateSummarize(entries []string) summaryEstimate {
+	est := summaryEstimate{systemTokens: estimateTokens(buildSystemPrompt())}
+	for _, entry := range entries {
+		est.entryTokens = append(est.entryTokens, estimateTokens(entry))
+	}
+
+	budget := summaryLengths[summaryLengthFlag].maxTokens
+	switch {
+	case len(entries) == 0:
+		return est
+	case !combineFlag:
+		batches := batchEntries(entries, batchSize, combineCharBudget)
+		for _, batch := range batches {
+			est.inputTokens += est.systemTokens + estimateTokens(buildUserPrompt(strings.Join(batch, combineSeparator)))
+		}
+		est.requests = len(batches)
+		budget *= batchSize // Summarize scales the output budget with the batch
+	default:
+		chunks := chunkEntries(entries, combineCharBudget)
+		for _, chunk := range chunks {
+			est.inputTokens += est.systemTokens + estimateTokens(buildCombinedUserPrompt(chunk))
+		}
+		est.requests = len(chunks)
+		if len(chunks) > 1 {
+			// One more request combines the partial summaries, each at most budget tokens
+			est.inputTokens += est.systemTokens + estimateTokens(buildCombinedUserPrompt("")) + len(chunks)*budget
+			est.requests++
+		}
+	}
+	est.outputTokens = est.requests * budget
+	return est
+}
+
+func printSummaryEstimate(est summaryEstimate) {
+	for i, tokens := range est.entryTokens {
+		fmt.Printf("Entry %d: ~%d tokens\n", i+1, tokens)
+	}
+	fmt.Printf("System prompt: ~%d tokens per request\n", est.systemTokens)
+	fmt.Printf("Requests: %d\n", est.requests)
+	fmt.Printf("Estimated input tokens: ~%d\n", est.inputTokens)
+	fmt.Printf("Estimated output tokens: up to %d (--length %s)\n", est.outputTokens, summaryLengthFlag)
+	fmt.Println("Nothing was sent to the AI endpoint; cached summaries would reduce the actual cost.")
+}
+
+// summaryLine is one line of --json-stream output.
+type summaryLine struct {
+	Index   int    `json:"index"`
+	Summary string `json:"summary,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// writeSummaryLine writes the result for entry index as a single JSON line
+// and syncs stdout so a consumer sees it as soon as it is ready.
+func writeSummaryLine(index int, summary string, err error) {
+	line := summaryLine{Index: index, Summary: summary}
+	if err != nil {
+		line.Error = err.Error()
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(line); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+		return
+	}
+	os.Stdout.Sync() // Best effort; fails harmlessly on pipes
+}
+
+// summarizeCombined requests a single summary across entries. When the joined
+// text exceeds combineCharBudget, it summarizes budget-sized chunks first and
+// then combines those partial summaries (map-reduce).
+func summarizeCombined(summarizer Summarizer, entries []string) (string, error) {
+	chunks := chunkEntries(entries, combineCharBudget)
+	if len(chunks) == 1 {
+		return summarizer.SummarizeCombined(chunks[0])
+	}
+
+	if debug {
+		fmt.Printf("Debug: Combined input exceeds budget; summarizing %d chunks first\n", len(chunks))
+	}
+	partials := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		partial, err := summarizer.SummarizeCombined(chunk)
+		if err != nil {
+			return "", err
+		}
+		partials = append(partials, partial)
+	}
+	return summarizer.SummarizeCombined(strings.Join(partials, combineSeparator))
+}
+
+// batchEntries groups entries into batches of up to size entries each,
+// starting a batch early when adding an entry would take the joined text over
+// budget characters. An entry longer than budget gets a batch of its own.
+func batchEntries(entries []string, size, budget int) [][]string {
+	var batches [][]string
+	var current []string
+	length := 0
+	for _, entry := range entries {
+		if len(current) > 0 && (len(current) == size || length+len(combineSeparator)+len(entry) > budget) {
+			batches = append(batches, current)
+			current, length = nil, 0
+		}
+		if len(current) > 0 {
+			length += len(combineSeparator)
+		}
+		current = append(current, entry)
+		length += len(entry)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// chunkEntries joins entries with combineSeparator into chunks of at most
+// budget characters. An entry longer than budget gets a chunk of its own.
+func chunkEntries(entries []string, budget int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, entry := range entries {
+		if current.Len() > 0 && current.Len()+len(combineSeparator)+len(entry) > budget {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(combineSeparator)
+		}
+		current.WriteString(entry)
+	}
+	if current.Len() > 0 || len(chunks) == 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+func handleGraphCommand(args []string, client GitHubClient, gqlClient GraphQLClient) {
+	login, err := resolveLogin(args, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+
+	if len(compareWindows) > 0 {
+		printWindowComparison(login, client, gqlClient)
+		return
+	}
+
+	if watchInterval > 0 {
+		if stdoutIsTerminalFunc() {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			// The first Ctrl-C ends the watch after the current redraw;
+			// restoring the default handling lets a second one stop a slow
+			// or rate-limited fetch right away
+			context.AfterFunc(ctx, stop)
+			watchGraph(ctx, watchInterval, func() { drawGraph(login, client, gqlClient) })
+			return
+		}
+		fmt.Fprintln(warningWriter(), "Warning: --watch is ignored when output is piped; drawing the graph once")
+	}
+	drawGraph(login, client, gqlClient)
+}
+
+// parseWindows parses a --windows value such as "7d,30d,90d" into day
+// counts, in the order given.
+func parseWindows(value string) ([]int, error) {
+	var days []int
+	for _, window := range strings.Split(value, ",") {
+		window = strings.TrimSpace(window)
+		n, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+		if !strings.HasSuffix(window, "d") || err != nil || n < 1 {
+			return nil, fmt.Errorf("--windows must be a comma-separated list of day counts like 7d,30d,90d, got '%s'", window)
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+// searchFieldDate returns the item's date for the --date-field the search
+// filtered on, so results can be narrowed to a shorter window client-side.
+func searchFieldDate(item GitHubItem) (time.Time, bool) {
+	value := item.CreatedAt
+	switch dateField {
+	case "updated":
+		value = item.UpdatedAt
+	case "closed":
+		value = item.ClosedAt
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
+
+// printWindowComparison prints the graph summary numbers for each --windows
+// window side by side, one row per metric. --since is set to the widest
+// window, which is fetched once; the shorter ones are narrowed from it. Each
+// window of N days covers today and the N-1 days before it.
+func printWindowComparison(login string, client GitHubClient, gqlClient GraphQLClient) {
+	now := timeNowFunc().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	results, err := fetchAllContributions(client, gqlClient, login, getEffectiveOrg(), since, allContributionKinds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+
+	header := []string{"Metric"}
+	var windowStats []contributionStats
+	for _, days := range compareWindows {
+		header = append(header, fmt.Sprintf("%dd", days))
+		start := today.AddDate(0, 0, -(days - 1))
+		inWindow := func(items []GitHubItem) []GitHubItem {
+			var kept []GitHubItem
+			for _, item := range items {
+				if date, ok := searchFieldDate(item); ok && !date.Before(start) {
+					kept = append(kept, item)
+				}
+			}
+			return kept
+		}
+		window := &contributionResults{
+			prItems:         inWindow(results.prItems),
+			reviewItems:     inWindow(results.reviewItems),
+			issueItems:      inWindow(results.issueItems),
+			discussionItems: inWindow(results.discussionItems),
+		}
+		windowStats = append(windowStats, computeContributionStats(window, start, now))
+	}
+
+	writer := newRowWriter()
+	defer writer.Flush()
+	writer.Write(header)
+	for _, metric := range []struct {
+		name  string
+		value func(contributionStats) string
+	}{
+		{"Total", func(s contributionStats) string { return strconv.Itoa(s.total) }},
+		{"Pull Requests", func(s contributionStats) string { return strconv.Itoa(s.prs.total) }},
+		{"Merged PRs", func(s contributionStats) string { return strconv.Itoa(s.mergedPRs) }},
+		{"Reviews", func(s contributionStats) string { return strconv.Itoa(s.reviews.total) }},
+		{"Issues", func(s contributionStats) string { return strconv.Itoa(s.issues.total) }},
+		{"Discussions", func(s contributionStats) string { return strconv.Itoa(s.discussions.total) }},
+		{"Avg per Day", func(s contributionStats) string { return fmt.Sprintf("%.2f", s.avgPerDay) }},
+	} {
+		row := []string{metric.name}
+		for _, stats := range windowStats {
+			row = append(row, metric.value(stats))
+		}
+		writer.Write(row)
+	}
+}
+
+// clearScreen moves the cursor home and clears the terminal for --watch.
+const clearScreen = "\033[H\033[2J"
+
+// minWatchInterval is the shortest --watch interval. Each redraw runs the
+// full set of searches, and the search API allows 30 requests a minute.
+const minWatchInterval = time.Minute
+
+// watchGraph redraws the graph every interval for --watch, clearing the
+// screen first and stamping each frame with the time it was fetched, until
+// ctx is cancelled (Ctrl-C).
+func watchGraph(ctx context.Context, interval time.Duration, draw func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fmt.Print(clearScreen)
+		draw()
+		fmt.Printf("\nLast updated: %s (refreshing every %s, Ctrl-C to stop)\n", timeNowFunc().In(location).Format("2006-01-02 15:04:05 MST"), interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drawGraph fetches the user's contributions and prints the graph once.
+func drawGraph(login string, client GitHubClient, gqlClient GraphQLClient) {
+	org := getEffectiveOrg()
+
+	if debug {
+		fmt.Println("Debug mode enabled")
+		fmt.Printf("Debug: Creating graph for login '%s' in org '%s' since '%s'\n", login, org, since)
+	}
+
+	sinceDate, _ := time.ParseInLocation(dateFormat, since, location)
+	today := windowEnd()
+
+	var results, previous *contributionResults
+	var err error
+	previousStart := previousWindowStart(sinceDate, today)
+	if comparePrevious {
+		results, previous, err = fetchWithPreviousWindow(client, gqlClient, login, org, sinceDate, previousStart)
+	} else {
+		results, err = fetchAllContributions(client, gqlClient, login, org, since, allContributionKinds)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+
+	// Discussions have no REST reactions endpoint, so they weigh zero
+	if graphWeight == "reactions" {
+		for _, r := range []*contributionResults{results, previous} {
+			if r != nil {
+				enrichWithReactions(client, r.prItems)
+				enrichWithReactions(client, r.reviewItems)
+				enrichWithReactions(client, r.issueItems)
+			}
+		}
+	}
+
+	prItems := results.prItems
+	reviewItems := results.reviewItems
+	issueItems := results.issueItems
+	discussionItems := results.discussionItems
+
+	stats := computeContributionStats(results, sinceDate, today)
+
+	if githubOutput {
+		if err := writeGitHubOutput(stats); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing GitHub Actions output: %v\n", err)
+		}
+		return
+	}
+	if prometheusFlag {
+		recordOutputType("prometheus")
+		if err := writePrometheusMetrics(outputDest(), stats, login, org); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing Prometheus metrics: %v\n", err)
+		}
+		return
+	}
+
+	// Check if there are any results to display. The SVG and pivot CSV
+	// still write an empty chart or zero rows, so their output stays
+	// parseable, with the notice on stderr
+	if len(prItems) == 0 && len(reviewItems) == 0 && len(issueItems) == 0 && len(discussionItems) == 0 {
+		if outputFormat != "svg" && !pivotFlag {
+			fmt.Printf("No contributions found for user '%s' in the '%s' organization since %s.\n", login, org, since)
+			return
+		}
+		fmt.Fprintf(warningWriter(), "No contributions found for user '%s' in the '%s' organization since %s.\n", login, org, since)
+	}
+
+	// Output heading only in debug mode
+	if debug {
+		fmt.Printf("Graph visualization for user '%s' in org '%s' since %s:\n\n", login, org, since)
+	}
+
+	var allItems []GitHubItem
+	for _, section := range results.sections() {
+		allItems = append(allItems, section.items...)
+	}
+
+	if calendarFlag {
+		printCalendar(allItems, sinceDate, today)
+		if whenFlag {
+			printWhenAnalysis(allItems)
+		}
+		printGraphSummary(stats, login)
+		if scoreFlag {
+			printContributionScore(results)
+		}
+		return
+	}
+
+	// Group contributions by week
+	weekMap := make(map[string]int)
+	weekStartDates := make(map[string]time.Time) // For sorting later
+
+	// Initialize all weeks (or --granularity buckets) in the range, regardless
+	// of whether they have contributions
+	totalWeeks := int(today.Sub(sinceDate).Hours()/(24*7*float64(weeksPerBucket))) + 1
+	for i := 0; i < totalWeeks; i++ {
+		weekKey, weekStart := graphBucket(i, sinceDate)
+
+		// Use a consistent key format to avoid duplicates
+		weekMap[weekKey] = 0
+		weekStartDates[weekKey] = weekStart
+	}
+
+	// Process PRs
+	processItems(prItems, sinceDate, weekMap, weekStartDates)
+	// Process Reviews
+	processItems(reviewItems, sinceDate, weekMap, weekStartDates)
+	// Process Issues
+	processItems(issueItems, sinceDate, weekMap, weekStartDates)
+	// Process Discussions
+	processItems(discussionItems, sinceDate, weekMap, weekStartDates)
+
+	// Sort the weeks chronologically
+	weeks := make([]string, 0, len(weekMap))
+	for week := range weekMap {
+		weeks = append(weeks, week)
+	}
+
+	// Sort weeks by their start date
+	sort.Slice(weeks, func(i, j int) bool {
+		return weekStartDates[weeks[i]].Before(weekStartDates[weeks[j]])
+	})
+
+	// Track contributions by type and state for each week
+	weekContributionMap := make(map[string]map[contributionType]int)
+	for week := range weekMap {
+		weekContributionMap[week] = make(map[contributionType]int)
+	}
+
+	// Count PRs by state for each week
+	countItemsByWeek(prItems, "pr", sinceDate, weekContributionMap)
+	// Count Reviews by state for each week
+	countItemsByWeek(reviewItems, "review", sinceDate, weekContributionMap)
+	// Count Issues by state for each week
+	countItemsByWeek(issueItems, "issue", sinceDate, weekContributionMap)
+	// Count Discussions by state for each week
+	countItemsByWeek(discussionItems, "discussion", sinceDate, weekContributionMap)
+
+	if outputFormat == "svg" {
+		recordOutputType("svg")
+		writeGraphSVG(outputDest(), weeks, weekStartDates, weekContributionMap, svgWidth, svgHeight)
+		return
+	}
+	if pivotFlag {
+		writeGraphPivot(weeks, weekStartDates, weekContributionMap)
+		return
+	}
+
+	// With --detailed, list each week's items under its bar
+	var weekItems map[string][]string
+	if detailedFlag && graphType != "line" {
+		weekItems = make(map[string][]string)
+		for _, group := range []struct {
+			itemType string
+			items    []GitHubItem
+		}{{"pr", prItems}, {"review", reviewItems}, {"issue", issueItems}, {"discussion", discussionItems}} {
+			for _, item := range group.items {
+				week := itemWeekKey(item, sinceDate)
+				line := fmt.Sprintf("    %s %s %s", graphSymbols[contributionType{group.itemType, item.State}], item.Title, item.HTMLURL)
+				weekItems[week] = append(weekItems[week], line)
+			}
+		}
+	}
+
+	if graphType == "line" {
+		printWeekLine(weeks, weekMap)
+	} else {
+		// Print one row per week: the histogram with different symbols for
+		// different contribution types, or a scaled dot with --graph-type dot
+		var previousCounts []int
+		if previous != nil {
+			previousCounts = previousBucketCounts(previous, previousStart, len(weeks))
+		}
+		maxCount := 0
+		for i, week := range weeks {
+			maxCount = max(maxCount, weekMap[week])
+			if previousCounts != nil {
+				maxCount = max(maxCount, previousCounts[i])
+			}
+		}
+		hiddenWeeks := 0
+		for i, week := range weeks {
+			if weekMap[week] < minWeekCount {
+				hiddenWeeks++
+				continue
+			}
+			fmt.Printf("%s: ", week)
+			if graphType == "dot" {
+				fmt.Print(weekDot(weekMap[week], maxCount))
+			} else {
+				for _, kind := range graphSymbolOrder {
+					fmt.Print(strings.Repeat(graphSymbols[kind], weightedBarWidth(weekContributionMap[week][kind], maxCount)))
+				}
+				if graphWeight == "reactions" {
+					fmt.Printf(" %d", weekMap[week])
+				}
+			}
+			fmt.Print("\n")
+			if previousCounts != nil {
+				fmt.Printf("%*s: ", len(week), "previous")
+				if graphType == "dot" {
+					fmt.Print(weekDot(previousCounts[i], maxCount))
+				} else {
+					fmt.Print(strings.Repeat(previousWindowSymbol, weightedBarWidth(previousCounts[i], maxCount)))
+					if graphWeight == "reactions" {
+						fmt.Printf(" %d", previousCounts[i])
+					}
+				}
+				fmt.Print("\n")
+			}
+
+			for _, line := range weekItems[week] {
+				fmt.Println(line)
+			}
+		}
+		if hiddenWeeks > 0 {
+			fmt.Printf("(%d of %d %s with fewer than %d contributions hidden; totals include them)\n", hiddenWeeks, len(weeks), bucketNoun(), minWeekCount)
+		}
+	}
+	fmt.Println()
+	if graphWeight == "reactions" {
+		fmt.Printf("Weighted by reactions: the number after each bar is its total reactions, not its item count; bars are scaled to at most %d symbols.\n", maxWeightedBarWidth)
+		fmt.Println()
+	}
+
+	// The legend explains the bar symbols, which --detailed also uses
+	if graphType == "bar" || weekItems != nil {
+		printGraphLegend(results, stats)
+	}
+
+	if whenFlag {
+		printWhenAnalysis(allItems)
+	}
+
+	if previous != nil {
+		printPreviousWindowChange(previous, previousStart, sinceDate, stats.total)
+	}
+	printGraphSummary(stats, login)
+	if scoreFlag {
+		printContributionScore(results)
+	}
+}
+
+// maxWeightedBarWidth caps the --weight reactions bars, whose totals can run
+// far wider than a terminal; longer bars are scaled down to fit.
+const maxWeightedBarWidth = 50
+
+// weightedBarWidth returns how many symbols to draw for count in a graph
+// whose busiest bar is maxCount. Item counts are drawn as-is; reaction totals
+// are scaled to maxWeightedBarWidth, keeping at least one symbol for any
+// nonzero total.
+func weightedBarWidth(count, maxCount int) int {
+	if graphWeight != "reactions" || maxCount <= maxWeightedBarWidth || count == 0 {
+		return count
+	}
+	return max(1, count*maxWeightedBarWidth/maxCount)
+}
+
+// previousWindowSymbol draws the --compare-previous bars, lighter than the
+// contribution symbols so the current window stands out.
+const previousWindowSymbol = "░"
+
+// previousWindowStart returns the start of the window of the same number of
+// days that ends just before sinceDate, for --compare-previous.
+func previousWindowStart(sinceDate, end time.Time) time.Time {
+	days := int(end.Sub(sinceDate).Hours()/24) + 1
+	return sinceDate.AddDate(0, 0, -days)
+}
+
+// fetchWithPreviousWindow runs one set of searches from previousStart and
+// splits the results on sinceDate by the --date-field date into the current
+// window and the previous one. The searches read the global since, so it is
+// moved back for the fetch and restored afterwards.
+func fetchWithPreviousWindow(client GitHubClient, gqlClient GraphQLClient, login, org string, sinceDate, previousStart time.Time) (current, previous *contributionResults, err error) {
+	saved := since
+	since = previousStart.Format(dateFormat)
+	defer func() { since = saved }()
+
+	results, err := fetchAllContributions(client, gqlClient, login, org, since, allContributionKinds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	split := func(items []GitHubItem) (inCurrent, inPrevious []GitHubItem) {
+		for _, item := range items {
+			if date, ok := searchFieldDate(item); ok && date.Before(sinceDate) {
+				inPrevious = append(inPrevious, item)
+			} else {
+				inCurrent = append(inCurrent, item)
+			}
+		}
+		return inCurrent, inPrevious
+	}
+	current, previous = &contributionResults{}, &contributionResults{}
+	current.prItems, previous.prItems = split(results.prItems)
+	current.reviewItems, previous.reviewItems = split(results.reviewItems)
+	current.issueItems, previous.issueItems = split(results.issueItems)
+	current.discussionItems, previous.discussionItems = split(results.discussionItems)
+	return current, previous, nil
+}
+
+// previousBucketCounts totals the previous window's items into n buckets
+// counted from previousStart, so bucket i lines up with the current window's
+// bucket i. Items are placed by the --date-field date the windows were split
+// on.
+func previousBucketCounts(previous *contributionResults, previousStart time.Time, n int) []int {
+	counts := make([]int, n)
+	for _, section := range previous.sections() {
+		for _, item := range section.items {
+			date, ok := searchFieldDate(item)
+			if !ok {
+				continue
+			}
+			index := int(date.Sub(previousStart).Hours() / (24 * 7 * float64(weeksPerBucket)))
+			counts[min(max(index, 0), n-1)] += itemWeight(item)
+		}
+	}
+	return counts
+}
+
+// printPreviousWindowChange prints the previous window's dates and total
+// and the percentage change to the current total, for --compare-previous.
+func printPreviousWindowChange(previous *contributionResults, previousStart, sinceDate time.Time, currentTotal int) {
+	previousTotal := 0
+	for _, section := range previous.sections() {
+		previousTotal += len(section.items)
+	}
+
+	change := "up from none"
+	if previousTotal > 0 {
+		change = fmt.Sprintf("%+.1f%%", float64(currentTotal-previousTotal)*100/float64(previousTotal))
+	}
+	fmt.Printf("%s = previous window (%s - %s): %d contributions, now %d (%s)\n\n",
+		previousWindowSymbol, previousStart.Format("Jan 02"), sinceDate.AddDate(0, 0, -1).Format("Jan 02"), previousTotal, currentTotal, change)
+}
+
+// printGraphLegend prints the bar symbols for the contribution types and
+// states present in the results.
+func printGraphLegend(results *contributionResults, stats contributionStats) {
+	prItems := results.prItems
+	reviewItems := results.reviewItems
+	issueItems := results.issueItems
+	discussionItems := results.discussionItems
+
+	// Print legend with only relevant symbols
+	fmt.Println("Legend:")
+
+	var legendParts []string
+
+	// Only include PR symbols in the legend if we have PRs
+	if len(prItems) > 0 {
+		if stats.prs.closed > 0 {
+			legendParts = append(legendParts, "• = Closed PR")
+		}
+		if stats.prs.open > 0 {
+			legendParts = append(legendParts, "○ = Open PR")
+		}
+	}
+
+	// Only include Review symbols in the legend if we have Reviews
+	if len(reviewItems) > 0 {
+		if stats.reviews.closed > 0 {
+			legendParts = append(legendParts, "◆ = Closed Review")
+		}
+		if stats.reviews.open > 0 {
+			legendParts = append(legendParts, "◇ = Open Review")
+		}
+	}
+
+	// Only include Issue symbols in the legend if we have Issues
+	if len(issueItems) > 0 {
+		if stats.issues.closed > 0 {
+			legendParts = append(legendParts, "■ = Closed Issue")
+		}
+		if stats.issues.open > 0 {
+			legendParts = append(legendParts, "□ = Open Issue")
+		}
+	}
+
+	// Only include Discussion symbols in the legend if we have Discussions
+	if len(discussionItems) > 0 {
+		if stats.discussions.closed > 0 {
+			legendParts = append(legendParts, "▲ = Closed Discussion")
+		}
+		if stats.discussions.open > 0 {
+			legendParts = append(legendParts, "△ = Open Discussion")
+		}
+	}
+
+	fmt.Println(strings.Join(legendParts, "  "))
+	fmt.Println()
+}
+
+// svgSeries are the stacked bar segments of the --format svg graph, bottom
+// to top, with their legend labels and fill colors.
+var svgSeries = []struct {
+	kind  contributionType
+	label string
+	color string
+}{
+	{contributionType{"pr", "closed"}, "Closed PR", "#8250df"},
+	{contributionType{"pr", "open"}, "Open PR", "#c297ff"},
+	{contributionType{"review", "closed"}, "Closed Review", "#0969da"},
+	{contributionType{"review", "open"}, "Open Review", "#80b3ff"},
+	{contributionType{"issue", "closed"}, "Closed Issue", "#1a7f37"},
+	{contributionType{"issue", "open"}, "Open Issue", "#6fdd8b"},
+	{contributionType{"discussion", "closed"}, "Closed Discussion", "#bc4c00"},
+	{contributionType{"discussion", "open"}, "Open Discussion", "#ffa657"},
+}
+
+// writeGraphSVG writes the weekly graph as a self-contained SVG image of
+// stacked bars, with the week start dates along the x axis, counts up the y
+// axis, and a legend of the series present.
+func writeGraphSVG(w io.Writer, weeks []string, weekStartDates map[string]time.Time, counts map[string]map[contributionType]int, width, height int) {
+	const marginLeft, marginRight, marginTop, marginBottom = 40.0, 20.0, 40.0, 40.0
+	plotWidth := float64(width) - marginLeft - marginRight
+	plotHeight := float64(height) - marginTop - marginBottom
+	bottom := marginTop + plotHeight
+
+	maxTotal := 1
+	present := make(map[contributionType]bool)
+	for _, week := range weeks {
+		total := 0
+		for kind, count := range counts[week] {
+			total += count
+			present[kind] = present[kind] || count > 0
+		}
+		maxTotal = max(maxTotal, total)
+	}
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n", width, height, width, height)
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", width, height)
+
+	// Legend across the top
+	x := marginLeft
+	for _, series := range svgSeries {
+		if !present[series.kind] {
+			continue
+		}
+		fmt.Fprintf(w, `<rect x="%.1f" y="12" width="10" height="10" fill="%s"/><text x="%.1f" y="21">%s</text>`+"\n", x, series.color, x+14, series.label)
+		x += 14 + float64(len(series.label))*6.5 + 12
+	}
+
+	if graphWeight == "reactions" {
+		fmt.Fprintf(w, `<text x="%.1f" y="21" text-anchor="end">Weighted by reactions</text>`+"\n", float64(width)-marginRight)
+	}
+
+	// Axes, with y ticks at zero, half, and the busiest week
+	fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#57606a"/>`+"\n", marginLeft, marginTop, marginLeft, bottom)
+	fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#57606a"/>`+"\n", marginLeft, bottom, marginLeft+plotWidth, bottom)
+	for _, tick := range []int{0, maxTotal / 2, maxTotal} {
+		y := bottom - float64(tick)/float64(maxTotal)*plotHeight
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="end">%d</text>`+"\n", marginLeft-6, y+4, tick)
+	}
+
+	if len(weeks) == 0 {
+		fmt.Fprintln(w, "</svg>")
+		return
+	}
+	slot := plotWidth / float64(len(weeks))
+	barWidth := slot * 0.7
+	for i, week := range weeks {
+		barX := marginLeft + float64(i)*slot + (slot-barWidth)/2
+		y := bottom
+		for _, series := range svgSeries {
+			count := counts[week][series.kind]
+			if count == 0 {
+				continue
+			}
+			barHeight := float64(count) / float64(maxTotal) * plotHeight
+			y -= barHeight
+			fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %d %s</title></rect>`+"\n",
+				barX, y, barWidth, barHeight, series.color, week, count, series.label)
+		}
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", barX+barWidth/2, bottom+16, weekStartDates[week].Format("Jan 02"))
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+// weekDots are the --graph-type dot glyphs, from the quietest to the busiest
+// week.
+var weekDots = []string{"·", "•", "●", "⬤"}
+
+// weekDot returns a dot sized by count relative to maxCount, followed by the
+// count. Empty weeks get no dot.
+func weekDot(count, maxCount int) string {
+	if count == 0 {
+		return "0"
+	}
+	level := (count*len(weekDots) - 1) / maxCount
+	return fmt.Sprintf("%s %d", weekDots[level], count)
+}
+
+// maxLineChartHeight caps the rows of the --graph-type line chart; taller
+// ranges are scaled down.
+const maxLineChartHeight = 10
+
+// printWeekLine draws weekly totals as an ASCII line chart: one column per
+// week, numbered along the bottom, with a '*' at each week's total and the
+// rise or fall to the next week filled in with '|'.
+func printWeekLine(weeks []string, weekMap map[string]int) {
+	maxCount := 0
+	for _, week := range weeks {
+		maxCount = max(maxCount, weekMap[week])
+	}
+	height := min(max(maxCount, 1), maxLineChartHeight)
+	levels := make([]int, len(weeks))
+	for i, week := range weeks {
+		levels[i] = (weekMap[week]*height + max(maxCount, 1)/2) / max(maxCount, 1)
+	}
+
+	labelWidth := len(strconv.Itoa(maxCount))
+	const columnWidth = 3
+	for row := height; row >= 1; row-- {
+		label := ""
+		if row == height || row == 1 || row*2 == height {
+			label = strconv.Itoa((row*maxCount + height/2) / height)
+		}
+		var line strings.Builder
+		for i, level := range levels {
+			cell := " "
+			switch {
+			case level == row:
+				cell = "*"
+			case i > 0 && row > min(levels[i-1], level) && row < max(levels[i-1], level):
+				cell = "|"
+			}
+			line.WriteString(text.PadRight(columnWidth, cell))
+		}
+		fmt.Printf("%*s | %s\n", labelWidth, label, strings.TrimRight(line.String(), " "))
+	}
+
+	var axis, numbers strings.Builder
+	for i := range weeks {
+		axis.WriteString(strings.Repeat("-", columnWidth))
+		numbers.WriteString(text.PadRight(columnWidth, strconv.Itoa(i+1)))
+	}
+	fmt.Printf("%*s +-%s\n", labelWidth, "0", axis.String())
+	fmt.Printf("%*s   %s(%s)\n", labelWidth, "", numbers.String(), strings.TrimSuffix(bucketNoun(), "s"))
+}
+
+// graphSymbols are the bar symbols for each contribution type and state,
+// printed in graphSymbolOrder.
+var (
+	graphSymbols = map[contributionType]string{
+		{"pr", "closed"}:         "•",
+		{"pr", "open"}:           "○",
+		{"review", "closed"}:     "◆",
+		{"review", "open"}:       "◇",
+		{"issue", "closed"}:      "■",
+		{"issue", "open"}:        "□",
+		{"discussion", "closed"}: "▲",
+		{"discussion", "open"}:   "△",
+	}
+	graphSymbolOrder = []contributionType{
+		{"pr", "closed"}, {"pr", "open"},
+		{"review", "closed"}, {"review", "open"},
+		{"issue", "closed"}, {"issue", "open"},
+		{"discussion", "closed"}, {"discussion", "open"},
+	}
+)
+
+// pivotColumn returns the --pivot CSV header for a contribution type and
+// state, e.g. "ClosedPR" or "OpenReview".
+func pivotColumn(kind contributionType) string {
+	names := map[string]string{"pr": "PR", "review": "Review", "issue": "Issue", "discussion": "Discussion"}
+	return strings.ToUpper(kind.state[:1]) + kind.state[1:] + names[kind.itemType]
+}
+
+// writeGraphPivot writes the weekly buckets as CSV for --pivot: one row per
+// week (or --granularity bucket) keyed by its start date, one column per
+// contribution type and state in graphSymbolOrder, and a Total, ready for a
+// stacked bar chart in a spreadsheet.
+func writeGraphPivot(weeks []string, weekStartDates map[string]time.Time, weekContributionMap map[string]map[contributionType]int) {
+	writer := newCSVWriter()
+	defer writer.Flush()
+
+	// Reaction totals are labeled as such, so a weighted export isn't
+	// mistaken for item counts
+	suffix := ""
+	if graphWeight == "reactions" {
+		suffix = " Reactions"
+	}
+	header := []string{"Week"}
+	for _, kind := range graphSymbolOrder {
+		header = append(header, pivotColumn(kind)+suffix)
+	}
+	writer.Write(append(header, "Total"+suffix))
+
+	for _, week := range weeks {
+		row := []string{weekStartDates[week].Format(dateFormat)}
+		total := 0
+		for _, kind := range graphSymbolOrder {
+			count := weekContributionMap[week][kind]
+			total += count
+			row = append(row, strconv.Itoa(count))
+		}
+		writer.Write(append(row, strconv.Itoa(total)))
+	}
+}
+
+// scoreCategories are the kinds of contribution --score weighs, in the order
+// the formula lists them.
+var scoreCategories = []string{"merged_pr", "closed_pr", "review", "closed_issue", "discussion", "open_item"}
+
+// defaultScoreWeights are the --score points per contribution, overridable
+// per category with extensions.gh-contrib.score_weights in the gh config.
+var defaultScoreWeights = map[string]float64{
+	"merged_pr":    3,
+	"closed_pr":    0, // Closed without merging
+	"review":       2,
+	"closed_issue": 1,
+	"discussion":   1,
+	"open_item":    0.5, // Open pull requests and issues
+}
+
+var scoreWeightsConfigFunc = getScoreWeightsFromConfig // Default to the actual implementation
+
+// scoreWeights returns the effective --score weights: the defaults with any
+// configured overrides applied. Unknown categories in the config are
+// reported and ignored.
+func scoreWeights() map[string]float64 {
+	weights := make(map[string]float64, len(defaultScoreWeights))
+	for category, weight := range defaultScoreWeights {
+		weights[category] = weight
+	}
+	for category, weight := range scoreWeightsConfigFunc() {
+		if _, ok := weights[category]; !ok {
+			fmt.Fprintf(warningWriter(), "Warning: ignoring unknown score_weights category '%s' (expected one of %s)\n", category, strings.Join(scoreCategories, ", "))
+			continue
+		}
+		weights[category] = weight
+	}
+	return weights
+}
+
+// scoreCategory returns the --score category of an item from the given
+// results section.
+func scoreCategory(label string, item GitHubItem) string {
+	switch {
+	case label == "Review":
+		return "review"
+	case label == "Discussion":
+		return "discussion"
+	case item.State == "open":
+		return "open_item"
+	case label == "Pull Request" && item.isMerged():
+		return "merged_pr"
+	case label == "Pull Request":
+		return "closed_pr"
+	}
+	return "closed_issue"
+}
+
+// contributionScore counts results by score category and returns the
+// weighted total with the counts.
+func contributionScore(results *contributionResults, weights map[string]float64) (float64, map[string]int) {
+	counts := make(map[string]int)
+	for _, section := range results.sections() {
+		for _, item := range section.items {
+			counts[scoreCategory(section.label, item)]++
+		}
+	}
+	score := 0.0
+	for category, count := range counts {
+		score += weights[category] * float64(count)
+	}
+	return score, counts
+}
+
+// scoreFormula renders the weights as "3 × merged_pr + 0 × closed_pr + ...",
+// so every score is printed with the definition behind it.
+func scoreFormula(weights map[string]float64) string {
+	terms := make([]string, len(scoreCategories))
+	for i, category := range scoreCategories {
+		terms[i] = fmt.Sprintf("%s × %s", strconv.FormatFloat(weights[category], 'f', -1, 64), category)
+	}
+	return strings.Join(terms, " + ")
+}
+
+// printContributionScore prints the graph's --score line, the counts behind
+// it, and the formula.
+func printContributionScore(results *contributionResults) {
+	weights := scoreWeights()
+	score, counts := contributionScore(results, weights)
+	var parts []string
+	for _, category := range scoreCategories {
+		if counts[category] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[category], category))
+		}
+	}
+	fmt.Printf("\nContribution Score: %s", strconv.FormatFloat(score, 'f', -1, 64))
+	if len(parts) > 0 {
+		fmt.Printf(" (%s)", strings.Join(parts, ", "))
+	}
+	fmt.Printf("\nScore = %s\n", scoreFormula(weights))
+}
+
+// printGraphSummary prints the totals under the graph and a link to the
+// matching GitHub search.
+func printGraphSummary(stats contributionStats, login string) {
+	// Print summary with date information
+	fmt.Printf("Total Contributions: %d over %d days (avg: %.2f per day)\n",
+		stats.total,
+		stats.daysActive,
+		stats.avgPerDay)
+
+	fmt.Printf("PRs: %d total (%d closed, %d open)\n",
+		stats.prs.total, stats.prs.closed, stats.prs.open)
+
+	fmt.Printf("Reviews: %d total (%d closed, %d open)\n",
+		stats.reviews.total, stats.reviews.closed, stats.reviews.open)
+
+	fmt.Printf("Issues: %d total (%d closed, %d open)\n",
+		stats.issues.total, stats.issues.closed, stats.issues.open)
+
+	fmt.Printf("Discussions: %d total (%d closed, %d open)\n",
+		stats.discussions.total, stats.discussions.closed, stats.discussions.open)
+
+	// Balance between the user's own work and reviewing others'
+	if withReviews {
+		authored := stats.authored()
+		fmt.Printf("Authored: %d (%.0f%%)\n", authored, percentOf(authored, stats.total))
+		fmt.Printf("Reviewed: %d (%.0f%%)\n", stats.reviews.total, percentOf(stats.reviews.total, stats.total))
+	}
+
+	// Display web URL for the GitHub search
+	webURL := buildWebURL("", login)
+	fmt.Printf("\nView in GitHub: %s\n", webURL)
+}
+
+// Calendar intensity symbols from no activity to the busiest day.
+var (
+	calendarLevels      = []string{"·", "░", "▒", "▓", "█"}
+	asciiCalendarLevels = []string{".", "-", "+", "*", "#"}
+)
+
+// contributionDate returns the date an item counts toward: when it was
+// closed, or else when it was created. With --date-field updated, the last
+// update comes first. It reports false if none parses.
+func contributionDate(item GitHubItem) (time.Time, bool) {
+	values := []string{item.ClosedAt, item.CreatedAt}
+	if dateField == "updated" {
+		values = append([]string{item.UpdatedAt}, values...)
+	}
+	for _, value := range values {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t.In(location), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// printCalendar renders a GitHub-style contributions grid for the window from
+// sinceDate through today: one row per weekday, one column per week starting
+// on Sunday, each day shaded by its count relative to the busiest day.
+func printCalendar(items []GitHubItem, sinceDate, today time.Time) {
+	levels := calendarLevels
+	if asciiFlag {
+		levels = asciiCalendarLevels
+	}
+
+	daily := make(map[string]int)
+	maxCount := 0
+	for _, item := range items {
+		date, ok := contributionDate(item)
+		if !ok {
+			continue
+		}
+		key := date.Format(dateFormat)
+		daily[key]++
+		if daily[key] > maxCount {
+			maxCount = daily[key]
+		}
+	}
+
+	gridStart := sinceDate.AddDate(0, 0, -int(sinceDate.Weekday()))
+	weeks := int(today.Sub(gridStart).Hours()/(24*7)) + 1
+	firstDay := sinceDate.Format(dateFormat)
+	lastDay := today.Format(dateFormat)
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		cells := make([]string, weeks)
+		for week := 0; week < weeks; week++ {
+			key := gridStart.AddDate(0, 0, week*7+int(weekday)).Format(dateFormat)
+			if key < firstDay || key > lastDay {
+				cells[week] = " "
+				continue
+			}
+			cells[week] = levels[calendarLevel(daily[key], maxCount, len(levels)-1)]
+		}
+		fmt.Printf("%s %s\n", weekday.String()[:3], strings.TrimRight(strings.Join(cells, " "), " "))
+	}
+	fmt.Println()
+	fmt.Printf("Less %s More\n\n", strings.Join(levels, " "))
+}
+
+// completionTime returns when an item was finished in the configured time
+// zone: merged_at for merged pull requests, otherwise closed_at. It reports
+// false for items that are still open.
+func completionTime(item GitHubItem) (time.Time, bool) {
+	value := item.ClosedAt
+	if item.isMerged() {
+		value = item.PullRequest.MergedAt
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.In(location), true
+}
+
+const whenBarWidth = 30 // Width of the longest --when histogram bar
+
+// printWhenAnalysis prints histograms of when merged or closed items were
+// finished, by day of week and by hour of day. Hours without activity are
+// left out to keep the output short.
+func printWhenAnalysis(items []GitHubItem) {
+	var byDay [7]int
+	var byHour [24]int
+	for _, item := range items {
+		t, ok := completionTime(item)
+		if !ok {
+			continue
+		}
+		byDay[t.Weekday()]++
+		byHour[t.Hour()]++
+	}
+
+	fmt.Println("Busiest days:")
+	printWhenHistogram(byDay[:], func(i int) string { return time.Weekday(i).String()[:3] }, false)
+	fmt.Println("Busiest hours:")
+	printWhenHistogram(byHour[:], func(i int) string { return fmt.Sprintf("%02d", i) }, true)
+	fmt.Println()
+}
+
+// printWhenHistogram prints one bar per bucket, scaled so the largest count
+// spans whenBarWidth. With skipEmpty, buckets with no items are omitted.
+func printWhenHistogram(counts []int, label func(int) string, skipEmpty bool) {
+	maxCount := 0
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+	if maxCount == 0 {
+		fmt.Println("  (no merged or closed items)")
+		return
+	}
+	for i, count := range counts {
+		if skipEmpty && count == 0 {
+			continue
+		}
+		bar := strings.Repeat("█", (count*whenBarWidth+maxCount-1)/maxCount)
+		if bar != "" {
+			bar += " "
+		}
+		fmt.Printf("  %s %s%d\n", label(i), bar, count)
+	}
+}
+
+// calendarLevel maps a daily count to an intensity level from 0 (none) to
+// top, scaled so the busiest day gets the top level.
+func calendarLevel(count, maxCount, top int) int {
+	if count <= 0 || maxCount <= 0 {
+		return 0
+	}
+	level := (count*top + maxCount - 1) / maxCount // Round up so any activity shows
+	return min(max(level, 1), top)
+}
+
+// stateCounts tallies items of one contribution type by state.
+type stateCounts struct {
+	total  int
+	open   int
+	closed int
+}
+
+// contributionStats holds the aggregate numbers shown in the graph summary.
+type contributionStats struct {
+	total       int
+	daysActive  int
+	avgPerDay   float64
+	prs         stateCounts
+	mergedPRs   int
+	reviews     stateCounts
+	issues      stateCounts
+	discussions stateCounts
+}
+
+// authored returns the number of contributions the user authored (PRs,
+// issues, and discussions), as opposed to reviewed.
+func (s contributionStats) authored() int {
+	return s.prs.total + s.issues.total + s.discussions.total
+}
+
+// percentOf returns n as a percentage of total, or 0 when total is 0.
+func percentOf(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+func countStates(items []GitHubItem) stateCounts {
+	counts := stateCounts{total: len(items)}
+	for _, item := range items {
+		switch item.State {
+		case "open":
+			counts.open++
+		case "closed":
+			counts.closed++
+		}
+	}
+	return counts
+}
+
+// computeContributionStats derives the graph summary numbers for the window
+// from sinceDate through today.
+func computeContributionStats(results *contributionResults, sinceDate, today time.Time) contributionStats {
+	stats := contributionStats{
+		daysActive:  int(today.Sub(sinceDate).Hours()/24) + 1,
+		prs:         countStates(results.prItems),
+		reviews:     countStates(results.reviewItems),
+		issues:      countStates(results.issueItems),
+		discussions: countStates(results.discussionItems),
+	}
+	for _, pr := range results.prItems {
+		if pr.isMerged() {
+			stats.mergedPRs++
+		}
+	}
+	stats.total = stats.prs.total + stats.reviews.total + stats.issues.total + stats.discussions.total
+	stats.avgPerDay = float64(stats.total) / float64(stats.daysActive)
+	return stats
+}
+
+// writeGitHubOutput writes the summary stats as name=value lines to the file
+// named by $GITHUB_OUTPUT, so they can be used as GitHub Actions step
+// outputs. When the variable is unset the lines are written to stdout.
+func writeGitHubOutput(stats contributionStats) error {
+	var w io.Writer = os.Stdout
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("error opening %s: %w", path, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	_, err := fmt.Fprintf(w, "total_contributions=%d\nprs_total=%d\nprs_merged=%d\nissues_total=%d\navg_per_day=%.2f\n",
+		stats.total, stats.prs.total, stats.mergedPRs, stats.issues.total, stats.avgPerDay)
+	return err
+}
+
+// prometheusLabelEscaper escapes a label value for the Prometheus text format.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheusMetrics writes the summary stats in the Prometheus text
+// exposition format for --prometheus, e.g. for a node_exporter textfile
+// collector. Every series carries the login and org labels; the per-kind
+// series add a state label: open, merged, or closed (unmerged) for pull
+// requests, and open or closed for the rest. The values cover the --since
+// window, so they are gauges rather than counters.
+func writePrometheusMetrics(w io.Writer, stats contributionStats, login, org string) error {
+	labels := fmt.Sprintf(`login="%s",org="%s"`, prometheusLabelEscaper.Replace(login), prometheusLabelEscaper.Replace(org))
+	var b strings.Builder
+	metric := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	sample := func(name, state string, value interface{}) {
+		if state == "" {
+			fmt.Fprintf(&b, "%s{%s} %v\n", name, labels, value)
+			return
+		}
+		fmt.Fprintf(&b, "%s{%s,state=\"%s\"} %v\n", name, labels, state, value)
+	}
+
+	metric("gh_contrib_contributions_total", "Contributions of every kind in the window.")
+	sample("gh_contrib_contributions_total", "", stats.total)
+	metric("gh_contrib_prs_total", "Pull requests authored in the window, by state.")
+	sample("gh_contrib_prs_total", "open", stats.prs.open)
+	sample("gh_contrib_prs_total", "merged", stats.mergedPRs)
+	sample("gh_contrib_prs_total", "closed", stats.prs.closed-stats.mergedPRs)
+	for _, kind := range []struct {
+		name   string
+		help   string
+		counts stateCounts
+	}{
+		{"gh_contrib_reviews_total", "Pull requests reviewed in the window, by the pull request's state.", stats.reviews},
+		{"gh_contrib_issues_total", "Issues authored in the window, by state.", stats.issues},
+		{"gh_contrib_discussions_total", "Discussions authored in the window, by state.", stats.discussions},
+	} {
+		metric(kind.name, kind.help)
+		sample(kind.name, "open", kind.counts.open)
+		sample(kind.name, "closed", kind.counts.closed)
+	}
+	metric("gh_contrib_window_days", "Days in the window.")
+	sample("gh_contrib_window_days", "", stats.daysActive)
+	metric("gh_contrib_contributions_per_day", "Average contributions per day in the window.")
+	sample("gh_contrib_contributions_per_day", "", strconv.FormatFloat(stats.avgPerDay, 'f', 2, 64))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var orgConfigFunc = getOrgFromConfig // Default to the actual implementation
+var compareWindows []int             // Day counts from --windows, nil to draw the graph
+var weeksPerBucket = 1               // Weeks per graph bucket, set from --granularity
+var location = time.UTC              // Time zone for date boundaries, set from --timezone
+var timeNowFunc = time.Now           // Default to the actual time.Now implementation
+
+// Function to read the organization from the GitHub CLI config file
+func getOrgFromConfig() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("error getting current user: %w", err)
+	}
+
+	configPath := filepath.Join(usr.HomeDir, ".config", "gh", "config.yml")
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var config struct {
+		Extensions map[string]struct {
+			Org string `yaml:"org"`
+		} `yaml:"extensions"`
+	}
+
+	err = yaml.Unmarshal(configData, &config)
+	if err != nil {
+		return "", fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	// Assuming the extension name is "gh-contrib"
+	if extConfig, ok := config.Extensions["gh-contrib"]; ok {
+		return extConfig.Org, nil
+	}
+
+	return "", fmt.Errorf("organization not found in config file under extensions")
+}
+
+// resolveLogin returns the login from args[1] if provided, otherwise fetches the authenticated user.
+func resolveLogin(args []string, client GitHubClient) (string, error) {
+	if len(args) >= 2 {
+		return args[1], nil
+	}
+	response := struct{ Login string }{}
+	if err := client.Get("user", &response); err != nil {
+		runFailed.Store(true)
+		return "", fmt.Errorf("error fetching logged-in user: %w", err)
+	}
+	return response.Login, nil
+}
+
+func getEffectiveOrg() string {
+	if orgFlag != "" {
+		return orgFlag // Use the --org flag if provided
+	}
+
+	org, err := orgConfigFunc()
+	if err != nil {
+		return defaultOrg // Default to 'github' if not found
+	}
+
+	return org
+}
+
+var strictOrgConfigFunc = getStrictOrgFromConfig // Default to the actual implementation
+
+// checkStrictOrg returns an error, with guidance on setting one, when
+// --strict-org or the strict_org config key is on and neither --org nor the
+// config names an organization, so nothing silently searches defaultOrg.
+func checkStrictOrg() error {
+	if !strictOrg && !strictOrgConfigFunc() {
+		return nil
+	}
+	if orgFlag != "" {
+		return nil
+	}
+	if org, err := orgConfigFunc(); err == nil && org != "" {
+		return nil
+	}
+	return fmt.Errorf("no organization is set and strict org mode is on, so '%s' will not be used; pass --org ORG or set org under extensions.gh-contrib in ~/.config/gh/config.yml", defaultOrg)
+}
+
+func getEffectiveModel() string {
+	if aiModelFlag != "" {
+		return aiModelFlag // Use the --ai-model flag if provided
+	}
+	if modelFlag != "" {
+		return modelFlag // Fall back to the deprecated --model alias
+	}
+	return modelConfigFunc() // Use the configured or default model
+}
+
+// getEffectiveIgnoreSections returns the --ignore-section values if given,
+// otherwise the configured list, otherwise the defaults.
+func getEffectiveIgnoreSections() []string {
+	if len(ignoreSections) > 0 {
+		return ignoreSections
+	}
+	if sections := ignoreSectionsConfigFunc(); len(sections) > 0 {
+		return sections
+	}
+	return defaultIgnoreSections
+}
+
+// warningWriter returns where routine warnings go: stderr, or nowhere under
+// --quiet or --suppress-warnings.
+func warningWriter() io.Writer {
+	if quiet || suppressWarnings {
+		return io.Discard
+	}
+	return os.Stderr
+}
+
+// dataWarningWriter returns where warnings about incomplete or truncated
+// results go. --quiet doesn't silence these, so incomplete data is never
+// trusted by accident; only --suppress-warnings does.
+func dataWarningWriter() io.Writer {
+	if suppressWarnings {
+		return io.Discard
+	}
+	return os.Stderr
+}
+
+// warnDeprecatedFlags writes a warning to w for each deprecated flag in use.
+func warnDeprecatedFlags(w io.Writer) {
+	if modelFlag != "" {
+		fmt.Fprintln(w, "Warning: --model is deprecated and will be removed in a future release; use --ai-model instead")
+	}
+}
+
+// pullQueryCommands are the commands that run an authored pull request search,
+// the only query --base applies to.
+var pullQueryCommands = map[string]bool{"pulls": true, "all": true, "graph": true, "footprint": true, "repo-activity": true}
+
+// issueQueryCommands are the commands that run an authored issue search.
+var issueQueryCommands = map[string]bool{"issues": true, "all": true, "graph": true, "footprint": true, "repo-activity": true}
+
+// warnIgnoredBase writes a warning to w when --base is set for a command
+// that has no pull request query to apply it to.
+func warnIgnoredBase(w io.Writer, subcommand string) {
+	if baseFlag != "" && subcommand != "" && !pullQueryCommands[subcommand] {
+		fmt.Fprintf(w, "Warning: --base only applies to pull request queries and is ignored by '%s'\n", subcommand)
+	}
+}
+
+// linkedTargets maps each --linked value to the item type whose query the
+// linked: qualifier applies to: issues can be linked to a PR, and PRs to an
+// issue.
+var linkedTargets = map[string]string{"pr": "is:issue", "issue": "is:pr"}
+
+// warnIgnoredMilestone writes a warning to w when --milestone is set for a
+// command without an authored pull request or issue query.
+func warnIgnoredMilestone(w io.Writer, subcommand string) {
+	if milestoneFlag != "" && subcommand != "" && !pullQueryCommands[subcommand] && !issueQueryCommands[subcommand] {
+		fmt.Fprintf(w, "Warning: --milestone only applies to pull request and issue queries and is ignored by '%s'\n", subcommand)
+	}
+}
+
+// warnIgnoredLinked writes a warning to w when --linked is set for a command
+// without a query of the type it applies to.
+func warnIgnoredLinked(w io.Writer, subcommand string) {
+	if linkedFlag == "" || subcommand == "" {
+		return
+	}
+	if linkedTargets[linkedFlag] == "is:pr" && !pullQueryCommands[subcommand] {
+		fmt.Fprintf(w, "Warning: --linked issue only applies to pull request queries and is ignored by '%s'\n", subcommand)
+	}
+	if linkedTargets[linkedFlag] == "is:issue" && !issueQueryCommands[subcommand] {
+		fmt.Fprintf(w, "Warning: --linked pr only applies to issue queries and is ignored by '%s'\n", subcommand)
+	}
+}
+
+// linkedFilter returns the linked: search qualifier for queries of
+// itemType when --linked applies to them.
+func linkedFilter(itemType string) string {
+	if linkedFlag != "" && linkedTargets[linkedFlag] == itemType {
+		return fmt.Sprintf(" linked:%s", linkedFlag)
+	}
+	return ""
+}
+
+// baseFilter returns the base: search qualifier for PR queries when --base is set.
+func baseFilter(itemType string) string {
+	if baseFlag != "" && itemType == "is:pr" {
+		return fmt.Sprintf(" base:%s", baseFlag)
+	}
+	return ""
+}
+
+// milestoneFilter returns the milestone: search qualifier when --milestone is
+// set. It applies to pull request and issue queries, and to the combined web
+// search (empty itemType). Names with spaces are quoted.
+func milestoneFilter(itemType string) string {
+	if milestoneFlag == "" || (itemType != "is:pr" && itemType != "is:issue" && itemType != "") {
+		return ""
+	}
+	if strings.ContainsAny(milestoneFlag, " \t") {
+		return fmt.Sprintf(` milestone:"%s"`, milestoneFlag)
+	}
+	return " milestone:" + milestoneFlag
+}
+
+// extraQualifiers returns the raw --query-extra qualifiers, unvalidated.
+func extraQualifiers() string {
+	if extra := strings.TrimSpace(queryExtra); extra != "" {
+		return " " + extra
+	}
+	return ""
+}
+
+// visibilityFilter returns the search qualifier for the current visibility flag.
+func visibilityFilter() string {
+	if visibilityFlag != "" {
+		return fmt.Sprintf(" is:%s", visibilityFlag)
+	}
+	return ""
+}
+
+// dateQualifier returns the search qualifier for the window from sinceDate
+// through --until, on the field chosen by --date-field (created:, updated:,
+// or closed:). Either bound may be empty.
+func dateQualifier(sinceDate string) string {
+	switch {
+	case sinceDate != "" && until != "":
+		return fmt.Sprintf(" %s:%s..%s", dateField, qualifierDate(sinceDate), qualifierEndDate(until, untilInclusive))
+	case sinceDate != "":
+		return fmt.Sprintf(" %s:>%s", dateField, qualifierDate(sinceDate))
+	case until != "":
+		return fmt.Sprintf(" %s:<=%s", dateField, qualifierEndDate(until, untilInclusive))
+	}
+	return ""
+}
+
+// qualifierDate formats a YYYY-MM-DD date for a search qualifier. GitHub
+// interprets bare dates in UTC, so when --timezone is set the date is sent
+// as midnight in that zone with an explicit offset instead.
+func qualifierDate(date string) string {
+	if timezoneFlag == "" {
+		return date
+	}
+	t, err := time.ParseInLocation(dateFormat, date, location)
+	if err != nil {
+		return date
+	}
+	return t.Format(time.RFC3339)
+}
+
+// qualifierEndDate formats the last moment of a search range ending on date:
+// the end of that day when inclusive (the --until default), otherwise the end
+// of the day before. Like qualifierDate, it's a bare UTC date unless
+// --timezone is set, when it's 23:59:59 in that zone with an explicit offset.
+func qualifierEndDate(date string, inclusive bool) string {
+	t, err := time.ParseInLocation(dateFormat, date, location)
+	if err != nil {
+		return date
+	}
+	if inclusive {
+		t = t.AddDate(0, 0, 1)
+	}
+	t = t.Add(-time.Second)
+	if timezoneFlag == "" {
+		return t.Format(dateFormat)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// defaultSinceDays is the length of the default --since window.
+const defaultSinceDays = 30
+
+// defaultSince returns the default --since date: defaultSinceDays before now
+// in the configured time zone.
+func defaultSince() string {
+	return timeNowFunc().In(location).AddDate(0, 0, -defaultSinceDays).Format(dateFormat)
+}
+
+// windowEnd returns the end of the reporting window: the end of the --until
+// day when set (or its start with --until-inclusive=false), otherwise now in
+// the configured time zone.
+func windowEnd() time.Time {
+	if until != "" {
+		if end, err := time.ParseInLocation(dateFormat, until, location); err == nil {
+			if untilInclusive {
+				end = end.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return end
+		}
+	}
+	return timeNowFunc().In(location)
+}
+
+const maxClockSkew = 5 * time.Minute
+
+// checkClockSkew compares the local clock with the Date header of a GitHub
+// response and warns on w when they differ by more than maxClockSkew, since
+// the default --since window is computed from the local clock.
+func checkClockSkew(client GitHubClient, w io.Writer) {
+	resp, err := client.GetWithResponse("rate_limit") // Doesn't count against the rate limit
+	if err != nil {
+		fmt.Fprintf(w, "Warning: could not check clock skew: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		fmt.Fprintln(w, "Warning: could not check clock skew: response has no valid Date header")
+		return
+	}
+
+	skew := timeNowFunc().Sub(serverTime)
+	if skew.Abs() > maxClockSkew {
+		fmt.Fprintf(w, "Warning: local clock differs from GitHub's by %s; date windows such as the default --since may be off\n", skew.Round(time.Second))
+	} else if debug {
+		fmt.Fprintf(w, "Clock skew vs GitHub: %s\n", skew.Round(time.Second))
+	}
+}
+
+// runFailed records that a fetch failed, so --since-last-run doesn't advance
+// the state file past results that were never shown.
+var runFailed atomic.Bool
+
+// searchSucceeded records that at least one search returned results, so
+// --since-last-run only advances after a command actually fetched the
+// window; config resolve, summarize, and models search nothing.
+var searchSucceeded atomic.Bool
+
+// runSucceeded reports whether this run fetched its window without errors,
+// the condition for --since-last-run to record it.
+func runSucceeded() bool {
+	return searchSucceeded.Load() && !runFailed.Load()
+}
+
+// cacheDir returns the extension's cache directory, ~/.cache/gh-contrib.
+func cacheDir() string {
+	usr, err := user.Current()
+	if err != nil {
+		return filepath.Join(".cache", "gh-contrib")
+	}
+	return filepath.Join(usr.HomeDir, ".cache", "gh-contrib")
+}
+
+// defaultStateFile returns the --since-last-run state file path,
+// ~/.cache/gh-contrib/lastrun.
+func defaultStateFile() string {
+	return filepath.Join(cacheDir(), "lastrun")
+}
+
+// readLastRun returns the time of the previous successful run recorded in
+// path. It reports false, with no error, when there is no previous run.
+func readLastRun(path string) (time.Time, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("error reading state file: %w", err)
+	}
+	lastRun, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid timestamp in state file %s: %w", path, err)
+	}
+	return lastRun, true, nil
+}
+
+// writeLastRun records t as the time of the latest successful run in path,
+// creating its directory if needed.
+func writeLastRun(path string, t time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(t.Format(time.RFC3339)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	return nil
+}
+
+// sinceForLastRun returns the --since date for a previous run at lastRun.
+// The created qualifier excludes the since date itself, so this is the day
+// before the last run: items from that day are shown again rather than
+// missed.
+func sinceForLastRun(lastRun time.Time) string {
+	return lastRun.In(location).AddDate(0, 0, -1).Format(dateFormat)
+}
+
+// isoWeekPattern matches an ISO week such as 2025-W16, accepted by --since.
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// looksLikeISOWeek reports whether value is meant as an ISO week, so that
+// malformed weeks such as 2025-W7 are rejected rather than sent to search.
+func looksLikeISOWeek(value string) bool {
+	return len(value) > 5 && value[4] == '-' && (value[5] == 'W' || value[5] == 'w')
+}
+
+// resolveISOWeek returns the Monday starting the ISO week value (YYYY-Www)
+// as a YYYY-MM-DD date.
+func resolveISOWeek(value string) (string, error) {
+	match := isoWeekPattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", fmt.Errorf("invalid ISO week '%s': expected YYYY-Www, e.g. 2025-W16", value)
+	}
+	year, _ := strconv.Atoi(match[1])
+	week, _ := strconv.Atoi(match[2])
+
+	// January 4th is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	week1Monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+	monday := week1Monday.AddDate(0, 0, (week-1)*7)
+
+	if _, w := monday.ISOWeek(); week < 1 || week > 53 || w != week {
+		return "", fmt.Errorf("invalid ISO week '%s': %d has no week %02d", value, year, week)
+	}
+	return monday.Format(dateFormat), nil
+}
+
+const sinceIssuePrefix = "issue:"
+
+// resolveSinceIssue returns the creation date of the issue referenced as
+// owner/repo#number, for --since issue:owner/repo#number.
+func resolveSinceIssue(client GitHubClient, ref string) (string, error) {
+	repo, numberStr, found := strings.Cut(ref, "#")
+	owner, name, slash := strings.Cut(repo, "/")
+	number, err := strconv.Atoi(numberStr)
+	if !found || !slash || owner == "" || name == "" || err != nil || number <= 0 {
+		return "", fmt.Errorf("invalid --since issue reference '%s': expected issue:owner/repo#123", ref)
+	}
+
+	var issue struct {
+		CreatedAt string `json:"created_at"`
+	}
+	if err := client.Get(fmt.Sprintf("repos/%s/%s/issues/%d", owner, name, number), &issue); err != nil {
+		return "", fmt.Errorf("error fetching issue %s for --since: %w", ref, err)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, issue.CreatedAt)
+	if err != nil {
+		return "", fmt.Errorf("error parsing creation date of issue %s: %w", ref, err)
+	}
+	if debug {
+		fmt.Printf("Resolved --since from issue %s: %s\n", ref, issue.CreatedAt)
+	}
+	return createdAt.In(location).Format(dateFormat), nil
+}
+
+const sinceReleasePrefix = "release:"
+
+// latestRelease is the subset of a release used for --since release:.
+type latestRelease struct {
+	TagName     string `json:"tag_name"`
+	PublishedAt string `json:"published_at"`
+}
+
+// resolveSinceRelease returns the publish date of the latest release of each
+// repository in the comma-separated repos list (names in org, or
+// owner/repo), for --since release:repo-a,repo-b: the most recent of those
+// dates, or the oldest with --release-anchor earliest. The repositories must
+// be listed so large orgs aren't scanned release by release. It prints which
+// release anchored the date, and skips repositories without releases; any
+// other API error is returned.
+func resolveSinceRelease(client GitHubClient, org, repos, anchor string) (string, error) {
+	var anchorRepo string
+	var anchorRelease latestRelease
+	var anchorDate time.Time
+	for _, repo := range strings.Split(repos, ",") {
+		repo = strings.TrimSpace(repo)
+		if repo == "" {
+			continue
+		}
+		if !strings.Contains(repo, "/") {
+			repo = org + "/" + repo
+		}
+		var release latestRelease
+		if err := client.Get(fmt.Sprintf("repos/%s/releases/latest", repo), &release); err != nil {
+			// 404 means no releases; anything else (auth, rate limits, a
+			// typo'd org the token can't see) must not quietly move the date
+			if !isNotFound(err) {
+				return "", fmt.Errorf("error fetching the latest release of %s: %w", repo, err)
+			}
+			if debug {
+				fmt.Printf("No latest release for %s: %v\n", repo, err)
+			}
+			continue
+		}
+		published, err := time.Parse(time.RFC3339, release.PublishedAt)
+		if err != nil {
+			continue
+		}
+		if anchorRepo == "" || (anchor == "latest" && published.After(anchorDate)) || (anchor == "earliest" && published.Before(anchorDate)) {
+			anchorRepo, anchorRelease, anchorDate = repo, release, published
+		}
+	}
+	if anchorRepo == "" {
+		return "", fmt.Errorf("no releases found for --since release:%s", repos)
+	}
+
+	date := anchorDate.In(location).Format(dateFormat)
+	fmt.Fprintf(os.Stderr, "Using --since %s from the %s release of %s (%s, published %s)\n", date, anchor, anchorRepo, anchorRelease.TagName, anchorRelease.PublishedAt)
+	return date, nil
+}
+
+// topicRepos holds the lowercased full names of the org repositories tagged
+// with the --org-repos-filter topic, looked up once per run; nil when unset.
+var topicRepos map[string]bool
+
+// matchesTopicRepos reports whether item is in one of the topicRepos, or true
+// when --org-repos-filter is unset.
+func matchesTopicRepos(item GitHubItem) bool {
+	return topicRepos == nil || topicRepos[strings.ToLower(repoFullName(item))]
+}
+
+// fetchTopicRepos returns the lowercased full names of the repositories in
+// org tagged with topic, using the repository search. It costs one request
+// per 100 repositories, and like all searches it stops at searchResultCap.
+func fetchTopicRepos(client GitHubClient, org, topic string) (map[string]bool, error) {
+	query := url.QueryEscape(fmt.Sprintf("org:%s topic:%s", org, topic))
+	repos := make(map[string]bool)
+	for page := 1; page <= searchResultCap/100; page++ {
+		var response struct {
+			Items []struct {
+				FullName string `json:"full_name"`
+			} `json:"items"`
+		}
+		if err := client.Get(fmt.Sprintf("search/repositories?q=%s&per_page=100&page=%d", query, page), &response); err != nil {
+			return nil, fmt.Errorf("error searching repositories with topic '%s': %w", topic, err)
+		}
+		for _, repo := range response.Items {
+			repos[strings.ToLower(repo.FullName)] = true
+		}
+		if len(response.Items) < 100 {
+			break
+		}
+	}
+	if debug {
+		fmt.Printf("Found %d repositories with topic '%s' in %s\n", len(repos), topic, org)
+	}
+	return repos, nil
+}
+
+// resolvePeriod returns the first and last day of a named reporting period
+// relative to now. Periods that are still in progress end at now.
+func resolvePeriod(name string, now time.Time) (start, end time.Time, err error) {
+	year, month, _ := now.Date()
+	loc := now.Location()
+	quarterStart := time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, loc)
+	monthStart := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	yearStart := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+
+	switch name {
+	case "this-month":
+		return monthStart, now, nil
+	case "last-month":
+		return monthStart.AddDate(0, -1, 0), monthStart.AddDate(0, 0, -1), nil
+	case "this-quarter":
+		return quarterStart, now, nil
+	case "last-quarter":
+		return quarterStart.AddDate(0, -3, 0), quarterStart.AddDate(0, 0, -1), nil
+	case "this-year":
+		return yearStart, now, nil
+	case "last-year":
+		return yearStart.AddDate(-1, 0, 0), yearStart.AddDate(0, 0, -1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period '%s' (valid: %s)", name, strings.Join(periodNames, ", "))
+}
+
+// periodNames lists the presets accepted by --period.
+var periodNames = []string{"this-month", "last-month", "this-quarter", "last-quarter", "this-year", "last-year"}
+
+// printPeriods prints each --period preset with the dates it resolves to
+// at now, one aligned "name  start .. end" line per preset, for the periods
+// command. The name comes first so shell completion can take the first
+// field.
+func printPeriods(now time.Time) {
+	width := 0
+	for _, name := range periodNames {
+		width = max(width, len(name))
+	}
+	for _, name := range periodNames {
+		start, end, _ := resolvePeriod(name, now)
+		fmt.Printf("%-*s  %s .. %s\n", width, name, start.Format(dateFormat), end.Format(dateFormat))
+	}
+}
+
+func buildQuery(itemType, login string) string {
+	org := getEffectiveOrg() // Use the effective organization
+	query := fmt.Sprintf("%s org:%s author:%s sort:created-desc", itemType, org, login)
+	query += baseFilter(itemType)
+	query += milestoneFilter(itemType)
+	query += linkedFilter(itemType)
+	query += visibilityFilter()
+	query += dateQualifier(since)
+	query += extraQualifiers()
+	return url.QueryEscape(query)
+}
+
+func buildReviewQuery(login string) string {
+	org := getEffectiveOrg()
+	query := fmt.Sprintf("is:pr org:%s reviewed-by:%s sort:created-desc", org, login)
+	query += visibilityFilter()
+	query += dateQualifier(since)
+	return url.QueryEscape(query)
+}
+
+// buildAttentionQuery builds a search query for open items in the effective org.
+// The since window is deliberately ignored: anything still open needs attention
+// no matter how old it is.
+func buildAttentionQuery(qualifiers string) string {
+	org := getEffectiveOrg()
+	query := fmt.Sprintf("%s is:open org:%s sort:updated-desc", qualifiers, org)
+	query += visibilityFilter()
+	return url.QueryEscape(query)
+}
+
+// maxWebURLLength is the longest web search link printed as-is; longer
+// links risk being cut off by browsers or rejected by GitHub.
+const maxWebURLLength = 2000
+
+// buildWebURL constructs a GitHub web URL for the given query. If the link
+// would exceed maxWebURLLength, it warns and drops the least essential
+// qualifiers (sort, then base, milestone, linked, visibility, and
+// --query-extra) until it fits, so the link keeps working; the type, org,
+// author, and dates are always kept.
+func buildWebURL(itemType, login string) string {
+	org := getEffectiveOrg()
+	var parts []string
+	if itemType != "" {
+		parts = append(parts, itemType)
+	}
+	parts = append(parts, "org:"+org, "author:"+login)
+
+	optional := []string{
+		"sort:updated-desc",
+		strings.TrimSpace(baseFilter(itemType)),
+		strings.TrimSpace(milestoneFilter(itemType)),
+		strings.TrimSpace(linkedFilter(itemType)),
+		strings.TrimSpace(visibilityFilter()),
+		strings.TrimSpace(extraQualifiers()),
+	}
+	for _, qualifier := range optional {
+		if qualifier != "" {
+			parts = append(parts, qualifier)
+		}
+	}
+
+	if since != "" {
+		// Use date range format: created:start..end where end is --until or today
+		end, inclusive := until, untilInclusive
+		if end == "" {
+			end, inclusive = timeNowFunc().In(location).Format(dateFormat), true
+		}
+		parts = append(parts, fmt.Sprintf("%s:%s..%s", dateField, qualifierDate(since), qualifierEndDate(end, inclusive)))
+	} else if until != "" {
+		parts = append(parts, fmt.Sprintf("%s:<=%s", dateField, qualifierEndDate(until, untilInclusive)))
+	}
+
+	link := webSearchLink(parts)
+	if len(link) <= maxWebURLLength {
+		return link
+	}
+
+	fmt.Fprintf(warningWriter(), "Warning: the GitHub search link is %d characters, over the %d limit; dropping less essential qualifiers\n", len(link), maxWebURLLength)
+	for _, qualifier := range optional {
+		if qualifier == "" {
+			continue
+		}
+		parts = removeString(parts, qualifier)
+		if link = webSearchLink(parts); len(link) <= maxWebURLLength {
+			break
+		}
+	}
+	return link
+}
+
+// webSearchLink returns the github.com issue search URL for the qualifiers.
+func webSearchLink(qualifiers []string) string {
+	// URL encode the query for the web interface
+	return fmt.Sprintf("https://github.com/issues?q=%s", url.QueryEscape(strings.Join(qualifiers, " ")))
+}
+
+// removeString returns values without the first occurrence of value.
+func removeString(values []string, value string) []string {
+	for i, v := range values {
+		if v == value {
+			return append(values[:i:i], values[i+1:]...)
+		}
+	}
+	return values
+}
+
+// deduplicateItems removes items from candidates that already appear in existing (by HTMLURL).
+func deduplicateItems(existing, candidates []GitHubItem) []GitHubItem {
+	seen := make(map[string]bool, len(existing))
+	for _, item := range existing {
+		seen[item.HTMLURL] = true
+	}
+	var result []GitHubItem
+	for _, item := range candidates {
+		if !seen[item.HTMLURL] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// authorAssociations lists the values GitHub uses for author_association.
+var authorAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR", "CONTRIBUTOR", "FIRST_TIME_CONTRIBUTOR", "FIRST_TIMER", "MANNEQUIN", "NONE"}
+
+// validateAssociations checks a comma-separated --association value.
+func validateAssociations(value string) error {
+	for _, assoc := range strings.Split(value, ",") {
+		assoc = strings.ToUpper(strings.TrimSpace(assoc))
+		valid := false
+		for _, known := range authorAssociations {
+			if assoc == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown author association '%s' (valid: %s)", assoc, strings.Join(authorAssociations, ", "))
+		}
+	}
+	return nil
+}
+
+// matchesAssociation reports whether the item's author association is one of
+// the values in --association. Comparison is case-insensitive. Items always
+// match when it is unset.
+func matchesAssociation(item GitHubItem) bool {
+	if associationFlag == "" {
+		return true
+	}
+	for _, assoc := range strings.Split(associationFlag, ",") {
+		if strings.EqualFold(strings.TrimSpace(assoc), item.AuthorAssociation) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPattern is the compiled --filter regular expression, nil when unset.
+var filterPattern *regexp.Regexp
+
+// matchesFilter reports whether the item's title (or body, with
+// --filter-body) matches --filter. Items always match when it is unset.
+func matchesFilter(item GitHubItem) bool {
+	if filterPattern == nil {
+		return true
+	}
+	if filterBody {
+		return filterPattern.MatchString(item.Body)
+	}
+	return filterPattern.MatchString(item.Title)
+}
+
+// filterItems applies the client-side filters to items, keeping order.
+func filterItems(items []GitHubItem) []GitHubItem {
+	if associationFlag == "" && filterPattern == nil && topicRepos == nil {
+		return items
+	}
+	var kept []GitHubItem
+	for _, item := range items {
+		if matchesAssociation(item) && matchesFilter(item) && matchesTopicRepos(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// dedupeStripPattern is the compiled --dedupe-strip regular expression, nil
+// when unset.
+var dedupeStripPattern *regexp.Regexp
+
+// normalizeTitle returns the key --dedupe-by-title compares: the title with
+// --dedupe-strip matches removed, whitespace collapsed, and lowercased.
+func normalizeTitle(title string) string {
+	if dedupeStripPattern != nil {
+		title = dedupeStripPattern.ReplaceAllString(title, "")
+	}
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// dedupeItems collapses items with the same normalized title under
+// --dedupe-by-title. Each group keeps the most recently created item, in the
+// position of the group's first item, with the rest counted in Duplicates.
+func dedupeItems(items []GitHubItem) []GitHubItem {
+	if !dedupeByTitle {
+		return items
+	}
+	var kept []GitHubItem
+	index := make(map[string]int)
+	for _, item := range items {
+		key := normalizeTitle(item.Title)
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(kept)
+			kept = append(kept, item)
+			continue
+		}
+		duplicates := kept[i].Duplicates + 1
+		if item.CreatedAt > kept[i].CreatedAt {
+			kept[i] = item
+		}
+		kept[i].Duplicates = duplicates
+	}
+	return kept
+}
+
+// maxItemsLimit returns how many of total items may be rendered under
+// --max-items, printing a note to stderr when output is capped so truncated
+// results aren't mistaken for the full set.
+func maxItemsLimit(total int) int {
+	if maxItems <= 0 || total <= maxItems {
+		return total
+	}
+	fmt.Fprintf(dataWarningWriter(), "Note: showing %d of %d items (capped by --max-items)\n", maxItems, total)
+	return maxItems
+}
+
+// sortItems orders items for rendering according to --sort, in place. The
+// default keeps the search order, newest first.
+func sortItems(items []GitHubItem) []GitHubItem {
+	if sortFlag == "comments" {
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Comments > items[j].Comments
+		})
+	}
+	return items
+}
+
+// capItems truncates items to the --max-items limit.
+func capItems(items []GitHubItem) []GitHubItem {
+	return items[:maxItemsLimit(len(items))]
+}
+
+// capContributions applies the --max-items limit across all contribution
+// types combined, keeping items in render order (PRs, reviews, issues,
+// discussions).
+func capContributions(results *contributionResults) {
+	total := len(results.prItems) + len(results.reviewItems) + len(results.issueItems) + len(results.discussionItems)
+	remaining := maxItemsLimit(total)
+	for _, items := range []*[]GitHubItem{&results.prItems, &results.reviewItems, &results.issueItems, &results.discussionItems} {
+		if len(*items) > remaining {
+			*items = (*items)[:remaining]
+		}
+		remaining -= len(*items)
+	}
+}
+
+// splitMisfiledPulls separates pull requests out of a list of issues. The
+// is:pr and is:issue queries are disjoint by design, but transferred or
+// converted items can occasionally show up on the wrong side.
+func splitMisfiledPulls(issues []GitHubItem) (remaining, pulls []GitHubItem) {
+	for _, item := range issues {
+		if strings.Contains(item.HTMLURL, "/pull/") {
+			pulls = append(pulls, item)
+		} else {
+			remaining = append(remaining, item)
+		}
+	}
+	return remaining, pulls
+}
+
+// discussionNode is a single discussion in a GraphQL search response.
+type discussionNode struct {
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	Body              string `json:"body"`
+	Number            int    `json:"number"`
+	CreatedAt         string `json:"createdAt"`
+	ClosedAt          string `json:"closedAt"`
+	UpdatedAt         string `json:"updatedAt"`
+	Closed            bool   `json:"closed"`
+	AuthorAssociation string `json:"authorAssociation"`
+}
+
+// DiscussionSearchResponse represents the GraphQL response for discussion search.
+type DiscussionSearchResponse struct {
+	Search struct {
+		Nodes    []discussionNode `json:"nodes"`
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+	} `json:"search"`
+}
+
+func fetchDiscussions(gqlClient GraphQLClient, login, org, sinceDate string) ([]GitHubItem, error) {
+	query := fmt.Sprintf("author:%s org:%s sort:created-desc", login, org)
+	query += visibilityFilter()
+	query += dateQualifier(sinceDate)
+
+	const graphqlQuery = `
+query($query: String!, $first: Int!, $after: String) {
+  search(query: $query, type: DISCUSSION, first: $first, after: $after) {
+    nodes {
+      ... on Discussion {
+        title
+        url
+        body
+        number
+        createdAt
+        closedAt
+        updatedAt
+        closed
+        authorAssociation
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+	var allItems []GitHubItem
+	var cursor *string
+
+	for {
+		variables := map[string]interface{}{
+			"query": query,
+			"first": 100,
+		}
+		if cursor != nil {
+			variables["after"] = *cursor
+		}
+
+		var resp DiscussionSearchResponse
+		if err := gqlClient.Do(graphqlQuery, variables, &resp); err != nil {
+			runFailed.Store(true)
+			return nil, fmt.Errorf("error querying discussions: %w", err)
+		}
+
+		for _, node := range resp.Search.Nodes {
+			state := "open"
+			if node.Closed {
+				state = "closed"
+			}
+			allItems = append(allItems, GitHubItem{
+				Number:            node.Number,
+				Title:             node.Title,
+				HTMLURL:           node.URL,
+				Body:              node.Body,
+				State:             state,
+				CreatedAt:         node.CreatedAt,
+				ClosedAt:          node.ClosedAt,
+				UpdatedAt:         node.UpdatedAt,
+				AuthorAssociation: node.AuthorAssociation,
+			})
+		}
+
+		if !resp.Search.PageInfo.HasNextPage {
+			break
+		}
+		cursor = &resp.Search.PageInfo.EndCursor
+	}
+
+	if debug {
+		fmt.Printf("Fetched %d discussions for %s in %s\n", len(allItems), login, org)
+	}
+
+	searchSucceeded.Store(true)
+	return allItems, nil
+}
+
+// contributionResults holds the results of fetching all contribution types concurrently.
+type contributionResults struct {
+	prItems         []GitHubItem
+	reviewItems     []GitHubItem
+	issueItems      []GitHubItem
+	discussionItems []GitHubItem
+}
+
+// contributionSection pairs a contribution type label with its items.
+type contributionSection struct {
+	label string
+	items []GitHubItem
+}
+
+// sections returns the results grouped by type, in render order.
+func (r *contributionResults) sections() []contributionSection {
+	return []contributionSection{
+		{"Pull Request", r.prItems},
+		{"Review", r.reviewItems},
+		{"Issue", r.issueItems},
+		{"Discussion", r.discussionItems},
+	}
+}
+
+// contributionKinds selects which contribution types fetchAllContributions
+// fetches; the searches for the others are skipped entirely.
+type contributionKinds struct {
+	prs, reviews, issues, discussions bool
+}
+
+var allContributionKinds = contributionKinds{prs: true, reviews: true, issues: true, discussions: true}
+
+// allCommandKinds returns the contribution types the all command fetches,
+// narrowed by --only-prs or --only-issues.
+func allCommandKinds() contributionKinds {
+	switch {
+	case onlyPRs:
+		return contributionKinds{prs: true}
+	case onlyIssues:
+		return contributionKinds{issues: true}
+	}
+	return allContributionKinds
+}
+
+// fetchAllContributions fetches the selected kinds of PRs, reviews, issues,
+// and discussions concurrently.
+func fetchAllContributions(client GitHubClient, gqlClient GraphQLClient, login, org, sinceDate string, kinds contributionKinds) (*contributionResults, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results contributionResults
+		errs    []error
+	)
+
+	prQuery := buildQuery("is:pr", login)
+	prSearchURL := fmt.Sprintf("search/issues?q=%s", prQuery)
+
+	reviewQuery := buildReviewQuery(login)
+	reviewSearchURL := fmt.Sprintf("search/issues?q=%s", reviewQuery)
+
+	issueQuery := buildQuery("is:issue", login)
+	issueSearchURL := fmt.Sprintf("search/issues?q=%s", issueQuery)
+
+	if debug {
+		fmt.Printf("Fetching PRs, reviews, issues, and discussions concurrently for %s\n", login)
+	}
+
+	fetch := func(label string, dst *[]GitHubItem, get func() ([]GitHubItem, error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items, err := get()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error fetching %s: %w", label, err))
+				return
+			}
+			*dst = items
+		}()
+	}
+
+	if kinds.prs {
+		fetch("pull requests", &results.prItems, func() ([]GitHubItem, error) { return fetchAllResults(client, prSearchURL) })
+	}
+	if kinds.reviews {
+		fetch("reviews", &results.reviewItems, func() ([]GitHubItem, error) { return fetchAllResults(client, reviewSearchURL) })
+	}
+	if kinds.issues {
+		fetch("issues", &results.issueItems, func() ([]GitHubItem, error) { return fetchAllResults(client, issueSearchURL) })
+	}
+	if kinds.discussions {
+		fetch("discussions", &results.discussionItems, func() ([]GitHubItem, error) { return fetchDiscussions(gqlClient, login, org, sinceDate) })
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	results.prItems = filterItems(results.prItems)
+	results.reviewItems = filterItems(results.reviewItems)
+	results.issueItems = filterItems(results.issueItems)
+	results.discussionItems = filterItems(results.discussionItems)
+
+	// PRs are issues in GitHub's data model, so move any PR that leaked into
+	// the issue results over to the PR list
+	issues, misfiled := splitMisfiledPulls(results.issueItems)
+	if len(misfiled) > 0 {
+		results.issueItems = issues
+		if kinds.prs {
+			results.prItems = append(results.prItems, deduplicateItems(results.prItems, misfiled)...)
+		}
+		if debug {
+			fmt.Printf("Moved %d pull requests from the issue results to the PR results\n", len(misfiled))
+		}
+	}
+
+	// Deduplicate: remove reviews that the user also authored
+	results.reviewItems = deduplicateItems(results.prItems, results.reviewItems)
+
+	return &results, nil
+}
+
+// attentionItem is an open item paired with the reason it needs action.
+type attentionItem struct {
+	itemType string // "Pull Request" or "Issue"
+	reason   string
+	item     GitHubItem
+}
+
+// fetchAttentionItems finds open PRs authored by login that have requested
+// changes or comments, and open issues assigned to login. A PR that matches
+// more than one query is reported once, with the first matching reason.
+func fetchAttentionItems(client GitHubClient, login string) ([]attentionItem, error) {
+	queries := []struct {
+		itemType   string
+		reason     string
+		qualifiers string
+	}{
+		{"Pull Request", "changes requested", fmt.Sprintf("is:pr author:%s review:changes_requested", login)},
+		{"Pull Request", "has comments", fmt.Sprintf("is:pr author:%s comments:>0", login)},
+		{"Issue", "assigned", fmt.Sprintf("is:issue assignee:%s", login)},
+	}
+
+	var items []attentionItem
+	seen := make(map[string]bool)
+
+	for _, q := range queries {
+		searchURL := fmt.Sprintf("search/issues?q=%s", buildAttentionQuery(q.qualifiers))
+
+		if debug {
+			fmt.Printf("Calling GitHub API with URL: %s\n", searchURL)
+		}
+
+		results, err := fetchAllResults(client, searchURL)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching items needing attention: %w", err)
+		}
+
+		for _, result := range results {
+			if seen[result.HTMLURL] {
+				continue
+			}
+			seen[result.HTMLURL] = true
+			items = append(items, attentionItem{itemType: q.itemType, reason: q.reason, item: result})
+		}
+	}
+
+	return items, nil
+}
+
+// repoFootprint counts a user's contributions to a single repository.
+type repoFootprint struct {
+	Repository string `json:"repository"`
+	PRs        int    `json:"prs"`
+	Issues     int    `json:"issues"`
+	Total      int    `json:"total"`
+}
+
+// limitFootprint keeps the n most active repositories of a sorted footprint
+// and collapses the rest into a single "others (M repos)" row. n <= 0 keeps
+// every repository.
+func limitFootprint(footprint []repoFootprint, n int) []repoFootprint {
+	if n <= 0 || len(footprint) <= n {
+		return footprint
+	}
+	rest := footprint[n:]
+	others := repoFootprint{Repository: fmt.Sprintf("others (%d repos)", len(rest))}
+	for _, repo := range rest {
+		others.PRs += repo.PRs
+		others.Issues += repo.Issues
+		others.Total += repo.Total
+	}
+	return append(footprint[:n:n], others)
+}
+
+// activityRow is one user's contribution counts per repository for
+// repo-activity.
+type activityRow struct {
+	Login string         `json:"login"`
+	Email string         `json:"email,omitempty"` // Public profile email, with --author-email
+	Score *float64       `json:"score,omitempty"` // Weighted contribution score, with --score
+	Repos map[string]int `json:"repos"`
+	Total int            `json:"total"`
+}
+
+// buildActivityMatrix combines per-user footprints into a users ×
+// repositories matrix. Repositories are ordered by combined total, most
+// active first; with n > 0 only the n most active get their own column and
+// the rest are summed into an "others (M repos)" column.
+func buildActivityMatrix(logins []string, footprints [][]repoFootprint, n int) ([]string, []activityRow) {
+	totals := make(map[string]int)
+	rows := make([]activityRow, len(logins))
+	for i, login := range logins {
+		rows[i] = activityRow{Login: login, Repos: make(map[string]int)}
+		for _, repo := range footprints[i] {
+			rows[i].Repos[repo.Repository] = repo.Total
+			rows[i].Total += repo.Total
+			totals[repo.Repository] += repo.Total
+		}
+	}
+
+	repos := make([]string, 0, len(totals))
+	for repo := range totals {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if totals[repos[i]] != totals[repos[j]] {
+			return totals[repos[i]] > totals[repos[j]]
+		}
+		return repos[i] < repos[j]
+	})
+	if n <= 0 || len(repos) <= n {
+		return repos, rows
+	}
+
+	rest := repos[n:]
+	others := fmt.Sprintf("others (%d repos)", len(rest))
+	for _, row := range rows {
+		for _, repo := range rest {
+			if count, ok := row.Repos[repo]; ok {
+				row.Repos[others] += count
+				delete(row.Repos, repo)
+			}
+		}
+	}
+	return append(repos[:n:n], others), rows
+}
+
+// Company labels for users without a usable profile company.
+const (
+	independentCompany = "Independent" // No company on the profile
+	unknownCompany     = "Unknown"     // The profile couldn't be fetched
+)
+
+// userProfile holds the public profile fields used to enrich repo-activity.
+type userProfile struct {
+	Company string `json:"company"`
+	Email   string `json:"email"` // Empty when the user keeps their email private
+}
+
+// fetchCompanies returns the company from each distinct login's profile.
+// The value is normalized by normalizeCompany; failed lookups map to
+// unknownCompany.
+func fetchCompanies(client GitHubClient, logins []string) map[string]string {
+	companies := make(map[string]string)
+	for login, profile := range fetchProfiles(client, logins) {
+		if profile == nil {
+			companies[login] = unknownCompany
+			continue
+		}
+		companies[login] = normalizeCompany(profile.Company)
+	}
+	return companies
+}
+
+// fetchEmails returns the public email from each distinct login's profile,
+// or "" when it's hidden or the lookup failed.
+func fetchEmails(client GitHubClient, logins []string) map[string]string {
+	emails := make(map[string]string)
+	for login, profile := range fetchProfiles(client, logins) {
+		if profile != nil {
+			emails[login] = strings.TrimSpace(profile.Email)
+		}
+	}
+	return emails
+}
+
+// orgMembersCache holds each org's member set, lowercased, so it is fetched
+// at most once per run.
+var orgMembersCache = make(map[string]map[string]bool)
+
+// fetchOrgMembers returns the lowercased logins of org's members, fetching
+// them on first use. GitHub only lists members with private membership to
+// other members of the org; otherwise the list holds public members only.
+func fetchOrgMembers(client GitHubClient, org string) (map[string]bool, error) {
+	if members, ok := orgMembersCache[strings.ToLower(org)]; ok {
+		return members, nil
+	}
+	members := make(map[string]bool)
+	for page := 1; ; page++ {
+		var response []struct {
+			Login string `json:"login"`
+		}
+		if err := client.Get(fmt.Sprintf("orgs/%s/members?per_page=100&page=%d", org, page), &response); err != nil {
+			return nil, fmt.Errorf("error listing members of '%s': %w", org, err)
+		}
+		for _, member := range response {
+			members[strings.ToLower(member.Login)] = true
+		}
+		if len(response) < 100 {
+			break
+		}
+	}
+	orgMembersCache[strings.ToLower(org)] = members
+	return members, nil
+}
+
+// filterOrgMembers returns the logins that are members of org, for
+// --org-members-only, and reports the rest on stderr. If the member list
+// can't be read, every login is kept with a warning; if the token can't see
+// private membership, the check falls back to public members with a
+// warning.
+func filterOrgMembers(client GitHubClient, org string, logins []string) []string {
+	members, err := fetchOrgMembers(client, org)
+	if err != nil {
+		fmt.Fprintf(warningWriter(), "Warning: --org-members-only is ignored: %v\n", err)
+		return logins
+	}
+	var membership struct {
+		State string `json:"state"`
+	}
+	if err := client.Get("user/memberships/orgs/"+org, &membership); err != nil || membership.State != "active" {
+		fmt.Fprintf(warningWriter(), "Warning: can't read private membership of '%s' (not a member, or the token lacks read:org); only public members are kept\n", org)
+	}
+
+	var kept, skipped []string
+	for _, login := range logins {
+		if members[strings.ToLower(login)] {
+			kept = append(kept, login)
+		} else {
+			skipped = append(skipped, login)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(warningWriter(), "Skipping non-members of '%s': %s\n", org, strings.Join(skipped, ", "))
+	}
+	return kept
+}
+
+// fetchProfiles fetches each distinct login's profile with one users/{login}
+// call, looking each login up once. Failed lookups warn and map to nil.
+func fetchProfiles(client GitHubClient, logins []string) map[string]*userProfile {
+	var distinct []string
+	seen := make(map[string]bool)
+	for _, login := range logins {
+		if !seen[login] {
+			seen[login] = true
+			distinct = append(distinct, login)
+		}
+	}
+
+	profiles := make([]*userProfile, len(distinct))
+	runBounded(len(distinct), enrichmentConcurrency, func(i int) {
+		var profile userProfile
+		if err := client.Get("users/"+distinct[i], &profile); err != nil {
+			fmt.Fprintf(dataWarningWriter(), "Warning: error fetching profile for '%s': %v\n", distinct[i], err)
+			return
+		}
+		profiles[i] = &profile
+	})
+
+	byLogin := make(map[string]*userProfile, len(distinct))
+	for i, login := range distinct {
+		byLogin[login] = profiles[i]
+	}
+	return byLogin
+}
+
+// normalizeCompany tidies a free-form profile company such as " @github "
+// into "github", or independentCompany when it's empty.
+func normalizeCompany(company string) string {
+	company = strings.TrimPrefix(strings.TrimSpace(company), "@")
+	if company == "" {
+		return independentCompany
+	}
+	return company
+}
+
+// companyActivityRow is one company's row of repo-activity --author-company.
+type companyActivityRow struct {
+	Company string         `json:"company"`
+	Logins  []string       `json:"logins"`
+	Score   *float64       `json:"score,omitempty"` // Sum of the users' scores, with --score
+	Repos   map[string]int `json:"repos"`
+	Total   int            `json:"total"`
+}
+
+// groupActivityByCompany sums the per-user rows by company. Companies that
+// differ only in case are merged under the first spelling seen. Rows are
+// ordered by total, most active first.
+func groupActivityByCompany(rows []activityRow, companies map[string]string) []companyActivityRow {
+	var grouped []companyActivityRow
+	index := make(map[string]int)
+	for _, row := range rows {
+		company := companies[row.Login]
+		if company == "" {
+			company = unknownCompany
+		}
+		key := strings.ToLower(company)
+		i, ok := index[key]
+		if !ok {
+			i = len(grouped)
+			index[key] = i
+			grouped = append(grouped, companyActivityRow{Company: company, Repos: make(map[string]int)})
+		}
+		grouped[i].Logins = append(grouped[i].Logins, row.Login)
+		for repo, count := range row.Repos {
+			grouped[i].Repos[repo] += count
+		}
+		grouped[i].Total += row.Total
+		if row.Score != nil {
+			score := *row.Score
+			if grouped[i].Score != nil {
+				score += *grouped[i].Score
+			}
+			grouped[i].Score = &score
+		}
+	}
+	sort.SliceStable(grouped, func(i, j int) bool {
+		if grouped[i].Score != nil && grouped[j].Score != nil {
+			return *grouped[i].Score > *grouped[j].Score // Ranked like the users with --score
+		}
+		return grouped[i].Total > grouped[j].Total
+	})
+	return grouped
+}
+
+// repoFullName returns "owner/repo" for an issue or PR, or "unknown" when the
+// URL can't be parsed.
+func repoFullName(item GitHubItem) string {
+	owner, repo, _, ok := parseItemURL(item.HTMLURL)
+	if !ok {
+		// parseItemURL only accepts issue and pull URLs; discussions share the layout
+		u, err := url.Parse(item.HTMLURL)
+		if err != nil {
+			return "unknown"
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) != 4 || parts[2] != "discussions" {
+			return "unknown"
+		}
+		owner, repo = parts[0], parts[1]
+	}
+	return owner + "/" + repo
+}
+
+// buildFootprint tallies PRs and issues per repository, sorted by total
+// descending and then by name.
+func buildFootprint(prItems, issueItems []GitHubItem) []repoFootprint {
+	byRepo := make(map[string]*repoFootprint)
+	tally := func(item GitHubItem) *repoFootprint {
+		name := repoFullName(item)
+		if byRepo[name] == nil {
+			byRepo[name] = &repoFootprint{Repository: name}
+		}
+		byRepo[name].Total++
+		return byRepo[name]
+	}
+	for _, item := range prItems {
+		tally(item).PRs++
+	}
+	for _, item := range issueItems {
+		tally(item).Issues++
+	}
+
+	footprint := make([]repoFootprint, 0, len(byRepo))
+	for _, repo := range byRepo {
+		footprint = append(footprint, *repo)
+	}
+	sort.Slice(footprint, func(i, j int) bool {
+		if footprint[i].Total != footprint[j].Total {
+			return footprint[i].Total > footprint[j].Total
+		}
+		return footprint[i].Repository < footprint[j].Repository
+	})
+	return footprint
+}
+
+// runBounded calls fn for each index in [0, n), with at most limit calls in
+// flight at once.
+func runBounded(n, limit int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// parseItemURL extracts the owner, repo, and number from an issue or pull
+// request HTML URL such as https://github.com/owner/repo/pull/123.
+func parseItemURL(htmlURL string) (owner, repo string, number int, ok bool) {
+	u, err := url.Parse(htmlURL)
+	if err != nil {
+		return "", "", 0, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 4 || (parts[2] != "pull" && parts[2] != "issues") {
+		return "", "", 0, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "%d", &number); err != nil {
+		return "", "", 0, false
+	}
+	return parts[0], parts[1], number, true
+}
+
+// fetchReactionCount returns the total number of reactions on an issue or PR.
+func fetchReactionCount(client GitHubClient, owner, repo string, number int) (int, error) {
+	total := 0
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("repos/%s/%s/issues/%d/reactions?per_page=100&page=%d", owner, repo, number, page)
+		var reactions []struct {
+			Content string `json:"content"`
+		}
+		if err := client.Get(path, &reactions); err != nil {
+			return 0, fmt.Errorf("error fetching reactions for %s/%s#%d: %w", owner, repo, number, err)
+		}
+		total += len(reactions)
+		if len(reactions) < 100 {
+			return total, nil
+		}
+	}
+}
+
+// enrichWithReactions sets TotalReactions on each issue or PR in items. This
+// costs at least one API call per item, so requests are bounded by
+// enrichmentConcurrency. Items whose URL can't be parsed (e.g. discussions)
+// are left at zero.
+func enrichWithReactions(client GitHubClient, items []GitHubItem) {
+	if debug {
+		fmt.Printf("Fetching reactions for %d items\n", len(items))
+	}
+	runBounded(len(items), enrichmentConcurrency, func(i int) {
+		owner, repo, number, ok := parseItemURL(items[i].HTMLURL)
+		if !ok {
+			return
+		}
+		count, err := fetchReactionCount(client, owner, repo, number)
+		if err != nil {
+			fmt.Fprintf(dataWarningWriter(), "Warning: %v\n", err)
+			return
+		}
+		items[i].TotalReactions = count
+	})
+}
+
+// pullReview is the subset of a pull request review used for review states.
+type pullReview struct {
+	State string `json:"state"`
+	User  struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// fetchReviewState returns login's review decision on a pull request,
+// lowercased: the state of their latest approved, changes_requested, or
+// dismissed review, or commented if they only commented, or "" if they left
+// none. A comment after an approval doesn't undo it, just as on GitHub.
+// Reviews are listed oldest first.
+func fetchReviewState(client GitHubClient, owner, repo string, number int, login string) (string, error) {
+	decision, commented := "", false
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("repos/%s/%s/pulls/%d/reviews?per_page=100&page=%d", owner, repo, number, page)
+		var reviews []pullReview
+		if err := client.Get(path, &reviews); err != nil {
+			return "", fmt.Errorf("error fetching reviews for %s/%s#%d: %w", owner, repo, number, err)
+		}
+		for _, review := range reviews {
+			if !strings.EqualFold(review.User.Login, login) {
+				continue
+			}
+			switch review.State {
+			case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+				decision = strings.ToLower(review.State)
+			case "COMMENTED":
+				commented = true
+			}
+		}
+		if len(reviews) < 100 {
+			break
+		}
+	}
+	if decision == "" && commented {
+		return "commented", nil
+	}
+	return decision, nil
+}
+
+// enrichWithReviewStates sets each pull request's ReviewState to login's
+// latest review decision, fetching reviews with bounded concurrency.
+// Failures are reported as warnings and leave the state empty.
+func enrichWithReviewStates(client GitHubClient, items []GitHubItem, login string) {
+	if debug {
+		fmt.Printf("Fetching review states for %d pull requests\n", len(items))
+	}
+	runBounded(len(items), enrichmentConcurrency, func(i int) {
+		owner, repo, number, ok := parseItemURL(items[i].HTMLURL)
+		if !ok {
+			return
+		}
+		state, err := fetchReviewState(client, owner, repo, number, login)
+		if err != nil {
+			fmt.Fprintf(dataWarningWriter(), "Warning: %v\n", err)
+			return
+		}
+		items[i].ReviewState = state
+	})
+}
+
+// timelineEvent is the subset of an issue timeline event used to find the
+// pull request that closed an issue.
+type timelineEvent struct {
+	Event     string `json:"event"`
+	CommitID  string `json:"commit_id"`
+	CommitURL string `json:"commit_url"` // API URL of the closing commit, which may be in another repository
+}
+
+// commitPull is the subset of a pull request associated with a commit.
+type commitPull struct {
+	HTMLURL        string `json:"html_url"`
+	MergedAt       string `json:"merged_at"`
+	MergeCommitSHA string `json:"merge_commit_sha"`
+}
+
+// fetchClosingPR returns the URL of the merged pull request that closed an
+// issue, or "" if it was closed some other way. The issue timeline records a
+// closed event with a commit when a commit closes it; the pull requests that
+// introduced that commit are then looked up, so an issue closed by a direct
+// push isn't credited to a merged PR that merely mentioned it.
+func fetchClosingPR(client GitHubClient, owner, repo string, number int) (string, error) {
+	var closing timelineEvent
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("repos/%s/%s/issues/%d/timeline?per_page=100&page=%d", owner, repo, number, page)
+		var pageEvents []timelineEvent
+		if err := client.Get(path, &pageEvents); err != nil {
+			return "", fmt.Errorf("error fetching timeline for %s/%s#%d: %w", owner, repo, number, err)
+		}
+		for _, event := range pageEvents {
+			if event.Event == "closed" {
+				closing = event // Only the final close counts
+			}
+		}
+		if len(pageEvents) < 100 {
+			break
+		}
+	}
+	if closing.CommitID == "" {
+		return "", nil
+	}
+
+	commitRepo := owner + "/" + repo
+	if _, rest, ok := strings.Cut(closing.CommitURL, "/repos/"); ok {
+		if name, _, ok := strings.Cut(rest, "/commits/"); ok {
+			commitRepo = name
+		}
+	}
+	var pulls []commitPull
+	if err := client.Get(fmt.Sprintf("repos/%s/commits/%s/pulls", commitRepo, closing.CommitID), &pulls); err != nil {
+		return "", fmt.Errorf("error fetching pull requests for commit %s in %s: %w", closing.CommitID, commitRepo, err)
+	}
+	var merged string
+	for _, pull := range pulls {
+		if pull.MergedAt == "" {
+			continue
+		}
+		if pull.MergeCommitSHA == closing.CommitID {
+			return pull.HTMLURL, nil
+		}
+		if merged == "" {
+			merged = pull.HTMLURL // A rebase merge lands the PR's own commits
+		}
+	}
+	return merged, nil
+}
+
+// enrichWithClosingPRs sets ClosedByPR on each closed issue in items, using
+// one or more timeline requests per issue, plus one for the closing commit's
+// pull requests, bounded by enrichmentConcurrency.
+func enrichWithClosingPRs(client GitHubClient, items []GitHubItem) {
+	if debug {
+		fmt.Printf("Looking up closing pull requests for %d items\n", len(items))
+	}
+	runBounded(len(items), enrichmentConcurrency, func(i int) {
+		if items[i].State != "closed" {
+			return
+		}
+		owner, repo, number, ok := parseItemURL(items[i].HTMLURL)
+		if !ok {
+			return
+		}
+		prURL, err := fetchClosingPR(client, owner, repo, number)
+		if err != nil {
+			fmt.Fprintf(dataWarningWriter(), "Warning: %v\n", err)
+			return
+		}
+		items[i].ClosedByPR = prURL
+	})
+}
+
+// issueComment is the subset of an issue comment used for first-response
+// times.
+type issueComment struct {
+	CreatedAt string `json:"created_at"`
+	User      struct {
+		Login string `json:"login"`
+		Type  string `json:"type"`
+	} `json:"user"`
+}
+
+// fetchFirstResponse returns when an issue first got a comment from someone
+// other than its author, or "" if it hasn't. Bot comments (auto-responders,
+// triage bots) don't count as a response. Comments are listed oldest first.
+func fetchFirstResponse(client GitHubClient, owner, repo string, number int, author string) (string, error) {
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("repos/%s/%s/issues/%d/comments?per_page=100&page=%d", owner, repo, number, page)
+		var comments []issueComment
+		if err := client.Get(path, &comments); err != nil {
+			return "", fmt.Errorf("error fetching comments for %s/%s#%d: %w", owner, repo, number, err)
+		}
+		for _, comment := range comments {
+			if !strings.EqualFold(comment.User.Login, author) && comment.User.Type != "Bot" {
+				return comment.CreatedAt, nil
+			}
+		}
+		if len(comments) < 100 {
+			return "", nil
+		}
+	}
+}
+
+// enrichWithFirstResponses sets FirstResponseAt on each issue in items,
+// fetching comments with bounded concurrency. Issues with no comments are
+// skipped without a request.
+func enrichWithFirstResponses(client GitHubClient, items []GitHubItem, author string) {
+	if debug {
+		fmt.Printf("Fetching first responses for %d issues\n", len(items))
+	}
+	runBounded(len(items), enrichmentConcurrency, func(i int) {
+		if items[i].Comments == 0 {
+			return
+		}
+		owner, repo, number, ok := parseItemURL(items[i].HTMLURL)
+		if !ok {
+			return
+		}
+		respondedAt, err := fetchFirstResponse(client, owner, repo, number, author)
+		if err != nil {
+			fmt.Fprintf(dataWarningWriter(), "Warning: %v\n", err)
+			return
+		}
+		items[i].FirstResponseAt = respondedAt
+	})
+}
+
+// responseTimeSummary reports the median and average time from creation to
+// first response across the issues that got one, for --with-response-time.
+func responseTimeSummary(items []GitHubItem) string {
+	var durations []time.Duration
+	for _, item := range items {
+		created, err := time.Parse(time.RFC3339, item.CreatedAt)
+		if err != nil || item.FirstResponseAt == "" {
+			continue
+		}
+		responded, err := time.Parse(time.RFC3339, item.FirstResponseAt)
+		if err != nil {
+			continue
+		}
+		durations = append(durations, responded.Sub(created))
+	}
+	if len(durations) == 0 {
+		return fmt.Sprintf("First response: none of the %d issues has a response yet", len(items))
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	median := durations[len(durations)/2]
+	if len(durations)%2 == 0 {
+		median = (durations[len(durations)/2-1] + median) / 2
+	}
+	summary := fmt.Sprintf("First response: median %s, average %s across %d of %d issues",
+		formatResponseTime(median), formatResponseTime(total/time.Duration(len(durations))), len(durations), len(items))
+	if waiting := len(items) - len(durations); waiting > 0 {
+		summary += fmt.Sprintf(" (%d with no response yet)", waiting)
+	}
+	return summary
+}
+
+// formatResponseTime renders d in its two largest units, e.g. "2d 4h",
+// "3h 15m", or "12m".
+func formatResponseTime(d time.Duration) string {
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return "<1m"
+}
+
+// searchCacheDir holds search results shared between runs for the session
+// cache, or is empty when the cache is off (--no-session-cache, and tests).
+var searchCacheDir string
+
+// searchCacheIdentity names the GitHub host and account the session cache
+// belongs to. It is part of every cache key so switching accounts or
+// GH_HOST never serves another identity's results.
+var searchCacheIdentity string
+
+// searchCacheTTL is how long cached search results are reused: long enough
+// to cover running pulls, issues, and graph back to back, short enough that
+// a later run sees new activity.
+const searchCacheTTL = 10 * time.Minute
+
+// searchCachePath returns the session cache file for a search. The key is
+// the identity plus the full search URL, which carries the query with every
+// qualifier and date bound, so only the same logical search by the same
+// account is reused.
+func searchCachePath(searchURL string) string {
+	sum := sha256.Sum256([]byte(searchCacheIdentity + "\n" + searchURL))
+	return filepath.Join(searchCacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// currentCacheIdentity returns the host gh talks to and the account it is
+// logged in as there. A token from the environment (GH_TOKEN and friends)
+// isn't tied to the configured user, so it is identified by a fingerprint.
+func currentCacheIdentity() string {
+	host, _ := auth.DefaultHost()
+	identity := host
+	if cfg, err := config.Read(nil); err == nil {
+		if user, err := cfg.Get([]string{"hosts", host, "user"}); err == nil {
+			identity += "\n" + user
+		}
+	}
+	if token, source := auth.TokenFromEnvOrConfig(host); token != "" && source != "oauth_token" {
+		sum := sha256.Sum256([]byte(token))
+		identity += "\n" + hex.EncodeToString(sum[:8])
+	}
+	return identity
+}
+
+// readSearchCache returns the cached results for searchURL if the session
+// cache is on and holds an entry younger than searchCacheTTL.
+func readSearchCache(searchURL string) (GitHubResponse, bool) {
+	if searchCacheDir == "" {
+		return GitHubResponse{}, false
+	}
+	path := searchCachePath(searchURL)
+	info, err := os.Stat(path)
+	if err != nil || timeNowFunc().Sub(info.ModTime()) > searchCacheTTL {
+		return GitHubResponse{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GitHubResponse{}, false
+	}
+	var cached GitHubResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return GitHubResponse{}, false
+	}
+	if debug {
+		fmt.Printf("Session cache hit: %s\n", path)
+	}
+	return cached, true
+}
+
+// writeSearchCache stores results for searchURL, with the search's total
+// count so a cache hit can repeat the incomplete-results warning. Like the
+// summary cache, errors are ignored.
+func writeSearchCache(searchURL string, results GitHubResponse) {
+	if searchCacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return
+	}
+	writePrivateFile(searchCacheDir, searchCachePath(searchURL), data)
+}
+
+// writePrivateFile writes data to path in dir, readable only by the user:
+// the caches can hold private repositories' results. Permissions are
+// tightened on a directory or file left by an older version.
+func writePrivateFile(dir, path string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0o600)
+}
+
+// pruneSearchCache removes expired session cache entries so the directory
+// doesn't grow without bound.
+func pruneSearchCache() {
+	entries, err := os.ReadDir(searchCacheDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && timeNowFunc().Sub(info.ModTime()) > searchCacheTTL {
+			os.Remove(filepath.Join(searchCacheDir, entry.Name()))
+		}
+	}
+}
+
+func fetchAllResults(client GitHubClient, searchURL string) ([]GitHubItem, error) {
+	if cached, ok := readSearchCache(searchURL); ok {
+		warnIncompleteSearch(cached.TotalCount)
+		searchSucceeded.Store(true)
+		return cached.Items, nil
+	}
+	items, total, err := fetchSearchPages(client, searchURL)
+	for attempt := 1; err == nil && len(items) == 0 && attempt <= retryEmpty; attempt++ {
+		if debug {
+			fmt.Printf("No results; retrying search in %s (attempt %d of %d)\n", retryEmptyDelay, attempt, retryEmpty)
+		}
+		sleepFunc(retryEmptyDelay)
+		items, total, err = fetchSearchPages(client, searchURL)
+	}
+	if err != nil {
+		runFailed.Store(true)
+		return items, err
+	}
+	searchSucceeded.Store(true)
+	if len(items) > 0 {
+		// Empty results aren't cached: the search index may still catch up
+		writeSearchCache(searchURL, GitHubResponse{TotalCount: total, Items: items})
+	}
+	return items, nil
+}
+
+// retryEmptyDelay is how long --retry-empty waits between attempts, giving
+// the search index time to catch up with freshly created items.
+const retryEmptyDelay = 3 * time.Second
+
+var sleepFunc = time.Sleep // Overridable for tests
+
+// fetchSearchPages pages through searchURL until a short page or maxPages.
+// It also returns the search's total count, which can exceed the items
+// returned.
+func fetchSearchPages(client GitHubClient, searchURL string) ([]GitHubItem, int, error) {
+	var allItems []GitHubItem
+	var totalCount int
+	page := 1
+	const maxPages = 10 // Safety break to prevent infinite loops in case of API issues
+
+	for page <= maxPages {
+		separator := "&"
+		if !strings.Contains(searchURL, "?") {
+			separator = "?"
+		}
+		paginatedURL := fmt.Sprintf("%s%spage=%d&per_page=100", searchURL, separator, page)
+
+		if debug {
+			fmt.Printf("Fetching page %d: %s\n", page, paginatedURL)
+		}
+
+		if rawOutput {
+			return nil, 0, printRawResponse(client, paginatedURL)
+		}
+
+		response := GitHubResponse{}
+
+		err := client.Get(paginatedURL, &response)
+		if isUnprocessable(err) {
+			return nil, 0, fmt.Errorf("GitHub rejected the search query: %s; check the user, org, and qualifiers, and if the query is long, try fewer qualifiers or a narrower window: %w", searchRejection(err), err)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("error fetching page %d from %s: %w", page, paginatedURL, err)
+		}
+
+		if debug {
+			fmt.Printf("Page %d: Found %d items (TotalCount: %d)\n", page, len(response.Items), response.TotalCount)
+		}
+
+		if page == 1 {
+			totalCount = response.TotalCount
+			warnIncompleteSearch(totalCount)
+		}
+
+		allItems = append(allItems, response.Items...)
+
+		if len(response.Items) < 100 {
+			break
+		}
+
+		page++
+	}
+
+	if page > maxPages {
+		fmt.Fprintf(warningWriter(), "Warning: Reached maximum page limit (%d) for URL: %s\n", maxPages, searchURL)
+	}
+
+	return allItems, totalCount, nil
+}
+
+// warnIncompleteSearch warns when a search matched more items than GitHub
+// returns, both on a fresh search and on a session cache hit.
+func warnIncompleteSearch(totalCount int) {
+	if totalCount > searchResultCap {
+		fmt.Fprintf(dataWarningWriter(), "Warning: the search matched %d items but GitHub only returns the first %d; results are incomplete. Narrow the window with --since/--until or --period.\n",
+			totalCount, searchResultCap)
+	}
+}
+
+// isUnprocessable reports whether err is an HTTP 422 from the API, which the
+// search endpoint returns for queries it can't run: over its length or
+// complexity limits, naming a user that doesn't exist, or with an invalid
+// qualifier.
+func isUnprocessable(err error) bool {
+	var httpErr *api.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnprocessableEntity
+}
+
+// isNotFound reports whether err is an HTTP 404 from the API.
+func isNotFound(err error) bool {
+	var httpErr *api.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
+
+// searchRejection returns the API's explanation for a 422: the messages of
+// its error items, or its top-level message when there are none.
+func searchRejection(err error) string {
+	var httpErr *api.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err.Error()
+	}
+	var messages []string
+	for _, item := range httpErr.Errors {
+		if item.Message != "" {
+			messages = append(messages, item.Message)
+		}
+	}
+	if len(messages) == 0 {
+		return httpErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+var exitFunc = os.Exit // Overridable for tests
+var rawOnce sync.Once
+
+// printRawResponse writes the verbatim body of the response for path to
+// stdout and exits, before any struct parsing. Only the first caller prints;
+// concurrent fetches block until the process exits.
+func printRawResponse(client GitHubClient, path string) error {
+	var err error
+	rawOnce.Do(func() {
+		var resp *http.Response
+		resp, err = client.GetWithResponse(path)
+		if err != nil {
+			err = fmt.Errorf("error fetching %s: %w", path, err)
+			return
+		}
+		defer resp.Body.Close()
+		if _, err = io.Copy(os.Stdout, resp.Body); err != nil {
+			err = fmt.Errorf("error reading response body: %w", err)
+			return
+		}
+		fmt.Println()
+		exitFunc(0)
+	})
+	return err
+}
+
+func printUserInfo(client GitHubClient) {
+	response := struct{ Login string }{}
+	err := client.Get("user", &response)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching user info: %v\n", err)
+		return
+	}
+	fmt.Printf("running as %s\n", response.Login)
+}
+
+func printHelp(client GitHubClient) {
+	fmt.Println("gh-contrib: A tool to better understand GitHub Issues and Pull Requests.")
+	printUserInfo(client)
+	printUsage()
+}
+
+// printUsage prints the command list and all flags.
+func printUsage() {
+	fmt.Println("\nAvailable commands:")
+	fmt.Println("  pulls <username>   - Get Pull Requests authored by <username> in the 'github' (or specified) org.")
+	fmt.Println("  reviews <username> - Get Pull Requests reviewed by <username> in the 'github' (or specified) org.")
+	fmt.Println("  issues <username>  - Get Issues authored by <username> in the 'github' (or specified) org.")
+	fmt.Println("  discussions <username> - Get Discussions authored by <username> in the 'github' (or specified) org.")
+	fmt.Println("  all <username>     - Get all Pull Requests, Reviews, Issues, and Discussions by <username> in the 'github' (or specified) org.")
+	fmt.Println("  footprint <username> - Count Pull Requests and Issues by <username> per repository, most active first.")
+	fmt.Println("  repo-activity <username>... - Matrix of contribution counts per repository for several users.")
+	fmt.Println("  attention <username> - Get open Pull Requests with requested changes or comments and open Issues assigned to <username>.")
+	fmt.Println("  summarize          - Summarize PR/Issue bodies from stdin or argument. Use --prompt-only to output the raw prompt.")
+	fmt.Println("  graph <username>   - Graph visualization for contributions by <username>.")
+	fmt.Println("  config resolve     - Show the effective org, model, since, and endpoint and where each comes from.")
+	fmt.Println("  models             - List the AI models available at the endpoint, for use with --ai-model.")
+	fmt.Println("  examples           - Show example invocations for common tasks.")
+	fmt.Println("  periods            - Show the dates each --period preset resolves to today.")
+	fmt.Println("\nFlags:")
+	flag.PrintDefaults()
+	fmt.Println("\nRun 'gh contrib <command> --help' for the flags that apply to a command.")
+}
+
+// commandHelp describes a subcommand for its --help output.
+type commandHelp struct {
+	usage       string
+	description string
+	flags       []string // Names of the flags that apply to the command
+	examples    []string
+}
+
+var (
+	windowFlags     = []string{"since", "release-anchor", "until", "until-inclusive", "date-field", "closed-since", "since-last-run", "state-file", "period", "timezone", "org", "strict-org", "visibility", "org-repos-filter"}
+	listOutputFlags = []string{"filter", "filter-body", "dedupe-by-title", "dedupe-strip", "sort", "include-comment-count", "format", "output", "append", "split-by-repo", "output-dir", "manifest", "bom", "csv-delimiter", "normalize-urls", "export", "emoji", "normalize-state", "max-items", "association", "body-only", "redact", "redact-allow", "entry-delimiter"}
+	runtimeFlags    = []string{"refresh-token", "no-session-cache", "graphql", "raw", "retry-empty", "max-concurrency", "check-clock", "quiet", "suppress-warnings", "debug"}
+)
+
+// flagList concatenates groups of flag names.
+func flagList(groups ...[]string) []string {
+	var names []string
+	for _, group := range groups {
+		names = append(names, group...)
+	}
+	return names
+}
+
+// commandHelps is the per-command usage registry used by --help.
+var commandHelps = map[string]commandHelp{
+	"pulls": {
+		usage:       "gh contrib pulls [username] [flags]",
+		description: "List pull requests authored by the user in the organization.",
+		flags:       flagList(windowFlags, listOutputFlags, []string{"base", "milestone", "query-extra", "linked", "with-reactions", "pr-start", "pr-end"}, runtimeFlags),
+		examples:    []string{"gh contrib pulls octocat", "gh contrib --base main --period last-month pulls octocat"},
+	},
+	"reviews": {
+		usage:       "gh contrib reviews [username] [flags]",
+		description: "List pull requests reviewed by the user in the organization.",
+		flags:       flagList(windowFlags, listOutputFlags, []string{"count", "with-reactions", "with-review-state", "review-start", "review-end"}, runtimeFlags),
+		examples:    []string{"gh contrib reviews octocat", "gh contrib --format table reviews", "gh contrib --count --since 2025-01-01 reviews"},
+	},
+	"issues": {
+		usage:       "gh contrib issues [username] [flags]",
+		description: "List issues authored by the user in the organization.",
+		flags:       flagList(windowFlags, listOutputFlags, []string{"milestone", "query-extra", "linked", "with-reactions", "include-closed-by-pr", "with-response-time", "issue-start", "issue-end"}, runtimeFlags),
+		examples:    []string{"gh contrib issues octocat", "gh contrib --association NONE,CONTRIBUTOR issues octocat"},
+	},
+	"discussions": {
+		usage:       "gh contrib discussions [username] [flags]",
+		description: "List discussions authored by the user in the organization.",
+		flags:       flagList(windowFlags, listOutputFlags, []string{"discussion-start", "discussion-end"}, runtimeFlags),
+		examples:    []string{"gh contrib discussions octocat"},
+	},
+	"all": {
+		usage:       "gh contrib all [username] [flags]",
+		description: "List pull requests, reviews, issues, and discussions by the user, with a Type column.",
+		flags:       flagList(windowFlags, listOutputFlags, []string{"only-prs", "only-issues", "base", "milestone", "query-extra", "linked", "with-reactions", "with-review-state", "include-closed-by-pr", "pr-start", "pr-end", "review-start", "review-end", "issue-start", "issue-end", "discussion-start", "discussion-end"}, runtimeFlags),
+		examples:    []string{"gh contrib all octocat", "gh contrib --body-only all octocat | gh contrib summarize"},
+	},
+	"footprint": {
+		usage:       "gh contrib footprint [username] [flags]",
+		description: "Count the user's pull requests and issues per repository, most active first.",
+		flags:       flagList(windowFlags, []string{"format", "output", "append", "bom", "csv-delimiter", "association", "filter", "filter-body", "base", "milestone", "query-extra", "linked", "top-repos"}, runtimeFlags),
+		examples:    []string{"gh contrib footprint octocat", "gh contrib --format json footprint octocat"},
+	},
+	"repo-activity": {
+		usage:       "gh contrib repo-activity [username...] [flags]",
+		description: "Show a matrix of contribution counts with a row per user and a column per repository, most active first.",
+		flags:       flagList(windowFlags, []string{"format", "output", "append", "bom", "csv-delimiter", "association", "filter", "filter-body", "base", "milestone", "query-extra", "linked", "top-repos", "author-company", "author-email", "score", "org-members-only", "fail-fast", "continue"}, runtimeFlags),
+		examples:    []string{"gh contrib --format table repo-activity alice bob carol", "gh contrib --top-repos 5 --period this-quarter repo-activity alice bob"},
+	},
+	"attention": {
+		usage:       "gh contrib attention [username] [flags]",
+		description: "List open pull requests with requested changes or comments and open issues assigned to the user. --since is ignored.",
+		flags:       []string{"org", "visibility", "format", "output", "append", "bom", "csv-delimiter", "normalize-urls", "max-items", "body-only", "graphql", "debug"},
+		examples:    []string{"gh contrib attention"},
+	},
+	"summarize": {
+		usage:       "gh contrib summarize [text] [flags]",
+		description: "Summarize PR/issue bodies from the argument or stdin, split on the entry delimiter.",
+		flags:       []string{"length", "summarize-format", "combine", "batch-size", "parallel", "preserve-links", "title-template", "redact", "redact-allow", "json-stream", "ignore-section", "system-prompt-file", "user-prompt-file", "ai-model", "model", "prompt-only", "estimate", "edit", "summary-footer", "since", "until", "period", "org", "no-summary-cache", "ai-retries", "entry-delimiter", "debug"},
+		examples:    []string{"gh contrib --body-only pulls octocat | gh contrib summarize", "gh contrib --combine --length long summarize < bodies.txt"},
+	},
+	"graph": {
+		usage:       "gh contrib graph [username] [flags]",
+		description: "Draw a weekly graph of the user's contributions with summary stats.",
+		flags:       flagList(windowFlags, []string{"association", "filter", "filter-body", "max-items", "base", "with-reviews", "milestone", "query-extra", "linked", "format", "output", "manifest", "width", "height", "graph-type", "granularity", "weight", "score", "watch", "detailed", "min-week-count", "calendar", "ascii", "when", "compare-previous", "pivot", "bom", "csv-delimiter", "windows", "github-output", "prometheus"}, runtimeFlags),
+		examples:    []string{"gh contrib graph octocat", "gh contrib --github-output graph"},
+	},
+	"config": {
+		usage:       "gh contrib config resolve [flags]",
+		description: "Show the effective org, model, since, and AI endpoint, and whether each comes from a flag, the config file, or the default.",
+		flags:       []string{"org", "ai-model", "model", "since", "period", "since-last-run", "state-file", "format"},
+		examples:    []string{"gh contrib config resolve", "gh contrib --org my-org --format table config resolve"},
+	},
+	"models": {
+		usage:       "gh contrib models [flags]",
+		description: "List the model IDs available at the AI endpoint and mark the current one.",
+		flags:       []string{"ai-model", "model", "debug"},
+		examples:    []string{"gh contrib models", "gh contrib --ai-model gpt-4o-mini summarize < bodies.txt"},
+	},
+	"examples": {
+		usage:       "gh contrib examples",
+		description: "Show example invocations for common tasks, combining commands and flags.",
+		examples:    []string{"gh contrib examples"},
+	},
+	"periods": {
+		usage:       "gh contrib periods [flags]",
+		description: "Show the start and end dates each --period preset resolves to today.",
+		flags:       []string{"timezone"},
+		examples:    []string{"gh contrib periods", "gh contrib --timezone America/New_York periods"},
+	},
+}
+
+// curatedExample is one entry in the examples command: a task and a command
+// line that does it. Tests check that every command and flag it uses exists.
+type curatedExample struct {
+	description string
+	command     string
+}
+
+// curatedExamples are printed by the examples command, in order.
+var curatedExamples = []curatedExample{
+	{"Monthly report of everything you did last month, as a table", "gh contrib --period last-month --format table all octocat"},
+	{"Summarize last month's pull requests with AI", "gh contrib --body-only --period last-month pulls octocat | gh contrib summarize"},
+	{"One cohesive summary of a quarter's work, with a footer linking to it all", "gh contrib --body-only --period last-quarter all octocat | gh contrib --combine --period last-quarter --summary-footer octocat summarize"},
+	{"Team leaderboard: contributions per repository for several people", "gh contrib --format table --top-repos 5 --period this-quarter repo-activity alice bob carol"},
+	{"Where someone works in another organization", "gh contrib --org my-org footprint octocat"},
+	{"Export everything as JSON for scripts", "gh contrib --format json --output contributions.json all octocat"},
+	{"Export a spreadsheet-ready TSV", "gh contrib --export --output contributions.tsv all octocat"},
+	{"Graph a quarter in two-week sprints", "gh contrib --granularity 2w --period this-quarter graph octocat"},
+	{"What needs your attention right now", "gh contrib attention octocat"},
+	{"Only what's new since the last run, for a recurring job", "gh contrib --since-last-run all octocat"},
+}
+
+// printExamples prints curatedExamples, each description followed by its
+// indented command.
+func printExamples() {
+	fmt.Println("Examples:")
+	for _, example := range curatedExamples {
+		fmt.Printf("\n  # %s\n  %s\n", example.description, example.command)
+	}
+	fmt.Println("\nRun 'gh contrib <command> --help' for the flags that apply to a command.")
+}
+
+// printCommandHelp prints the usage, flags from fs, and examples for a
+// command. It reports false if the command has no help entry.
+func printCommandHelp(name string, fs *flag.FlagSet) bool {
+	help, ok := commandHelps[name]
+	if !ok {
+		return false
+	}
+
+	fmt.Printf("Usage: %s\n\n%s\n", help.usage, help.description)
+
+	if len(help.flags) > 0 {
+		fmt.Println("\nFlags:")
+	}
+	for _, flagName := range help.flags {
+		f := fs.Lookup(flagName)
+		if f == nil {
+			continue
+		}
+		fmt.Printf("  --%s\n    \t%s", f.Name, f.Usage)
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			fmt.Printf(" (default %q)", f.DefValue)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("\nExamples:")
+	for _, example := range help.examples {
+		fmt.Printf("  %s\n", example)
+	}
+	return true
+}
+
+// writeRows renders rows under columns through newRowWriter, or as one CSV
+// file per repository with --split-by-repo. All row output goes through
+// here so shared columns render identically across commands. With --export
+// the fixed exportColumns replace columns.
+func writeRows(columns []column, rows []itemRow) {
+	if exportPreset {
+		columns = exportColumns
+	}
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.header
+	}
+	records := make([][]string, len(rows))
+	for i, row := range rows {
+		records[i] = make([]string, len(columns))
+		for j, col := range columns {
+			records[i][j] = col.value(row)
+		}
+	}
+
+	if splitByRepo {
+		items := make([]GitHubItem, len(rows))
+		for i, row := range rows {
+			items[i] = row.item
+		}
+		writeSplitByRepo(header, items, records)
+		return
+	}
+
+	outputItemCount += len(records)
+	writer := newRowWriter()
+	defer writer.Flush()
+	writer.Write(header)
+	for _, record := range records {
+		writer.Write(record)
+	}
+}
+
+// printItems renders items with the shared item columns.
+func printItems(items []GitHubItem) {
+	printLabeledItems(items, "")
+}
+
+// printLabeledItems renders items like printItems, labeling each row for
+// the --export type column when the item alone doesn't say what it is.
+func printLabeledItems(items []GitHubItem, label string) {
+	rows := make([]itemRow, len(items))
+	for i, item := range items {
+		rows[i] = itemRow{label: label, item: item}
+	}
+	writeRows(itemColumns(), rows)
+}
+
+// writeSplitByRepo writes each row, along with header, to a CSV file in
+// --output-dir named after the repository of the matching item
+// (owner-repo.csv, or unknown.csv). The files written are listed on stderr.
+func writeSplitByRepo(header []string, items []GitHubItem, rows [][]string) {
+	byRepo := make(map[string][][]string)
+	for i, item := range items {
+		name := repoFullName(item)
+		byRepo[name] = append(byRepo[name], rows[i])
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		return
+	}
+
+	repos := make([]string, 0, len(byRepo))
+	for name := range byRepo {
+		repos = append(repos, name)
+	}
+	sort.Strings(repos)
+
+	for _, name := range repos {
+		path := filepath.Join(outputDir, strings.ReplaceAll(name, "/", "-")+".csv")
+		if err := writeCSVFile(path, header, byRepo[name]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s (%d items)\n", path, len(byRepo[name]))
+		recordArtifact(path, "csv", len(byRepo[name]))
+	}
+}
+
+// manifestEntry describes one file written during a run, for --manifest.
+type manifestEntry struct {
+	Path  string `json:"path"`
+	Type  string `json:"type"`  // The file's format: csv, tsv, json, table, svg, or prometheus
+	Items int    `json:"items"` // Items written; 0 for outputs that aren't item lists, such as graphs
+}
+
+var (
+	manifestEntries []manifestEntry // Files written so far, in order
+	outputType      string          // Format written to --output, for its manifest entry
+	outputItemCount int             // Items written to --output, for its manifest entry
+)
+
+// recordOutputType notes the format being written to the output destination,
+// so the --output manifest entry names what the file actually holds.
+func recordOutputType(fileType string) {
+	outputType = fileType
+}
+
+// recordOutputArtifact adds the --output file to the manifest if anything
+// was written to it.
+func recordOutputArtifact() {
+	if outputFile != nil {
+		recordArtifact(outputPath, outputType, outputItemCount)
+	}
+}
+
+// finishManifest writes the --manifest, if requested, with the --output
+// file and everything recorded so far.
+func finishManifest() {
+	if manifestPath == "" {
+		return
+	}
+	recordOutputArtifact()
+	if err := writeManifest(manifestPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		runFailed.Store(true)
+	}
+}
+
+// recordArtifact adds a written file to the --manifest.
+func recordArtifact(path, fileType string, items int) {
+	manifestEntries = append(manifestEntries, manifestEntry{Path: path, Type: fileType, Items: items})
+}
+
+// writeManifest writes the files recorded in this run to path as a JSON
+// object with a "files" array, so automation can find them without
+// globbing. It is written even when no files were produced.
+func writeManifest(path string) error {
+	files := manifestEntries
+	if files == nil {
+		files = []manifestEntry{}
+	}
+	data, err := json.MarshalIndent(map[string][]manifestEntry{"files": files}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return nil
+}
+
+// writeCSVFile writes header and rows to a new CSV file at path.
+func writeCSVFile(path string, header []string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if bomFlag {
+		io.WriteString(f, utf8BOM)
+	}
+	writer := csv.NewWriter(f)
+	writer.Comma = csvComma
+	writer.Write(header)
+	writer.WriteAll(rows) // WriteAll flushes
+	return writer.Error()
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	recordOutputType("json")
+	encoder := json.NewEncoder(outputDest())
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+	}
+}
+
+func printItemsAsJSON(items []GitHubItem) {
+	if items == nil {
+		items = []GitHubItem{}
+	}
+	if normalizeState {
+		for i := range items {
+			items[i].CanonicalState = canonicalState(items[i])
+		}
+	}
+	outputItemCount += len(items)
+	printJSON(items)
+}
+
+// defaultContributorTypes maps lowercased label names to all-contributors
+// types when the config doesn't provide a mapping.
+var defaultContributorTypes = map[string]string{"documentation": "doc", "docs": "doc"}
+
+// getEffectiveContributorTypes returns the configured label mapping, or the
+// defaults, with label names lowercased.
+func getEffectiveContributorTypes() map[string]string {
+	configured := contributorTypesConfigFunc()
+	if len(configured) == 0 {
+		return defaultContributorTypes
+	}
+	mapping := make(map[string]string, len(configured))
+	for label, contributionType := range configured {
+		mapping[strings.ToLower(label)] = contributionType
+	}
+	return mapping
+}
+
+// contributorTypes infers the sorted all-contributors types for a user's
+// contributions: code for pull requests, review for reviews, and bug for
+// issues, plus any types mapped from the labels on their pull requests and
+// issues.
+func contributorTypes(results *contributionResults) []string {
+	labelTypes := getEffectiveContributorTypes()
+	seen := make(map[string]bool)
+	add := func(items []GitHubItem, contributionType string, useLabels bool) {
+		for _, item := range items {
+			seen[contributionType] = true
+			if !useLabels {
+				continue
+			}
+			for _, label := range item.Labels {
+				if mapped, ok := labelTypes[strings.ToLower(label.Name)]; ok {
+					seen[mapped] = true
+				}
+			}
 		}
+	}
+	add(results.prItems, "code", true)
+	add(results.reviewItems, "review", false) // Labels on a reviewed PR describe the author's work
+	add(results.issueItems, "bug", true)
 
-		for _, node := range resp.Search.Nodes {
-			state := "open"
-			if node.Closed {
-				state = "closed"
+	types := make([]string, 0, len(seen))
+	for contributionType := range seen {
+		types = append(types, contributionType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// typedItem is a GitHubItem tagged with its contribution type for JSON output.
+type typedItem struct {
+	Type string `json:"type"`
+	GitHubItem
+}
+
+func printContributionsAsJSON(results *contributionResults) {
+	items := []typedItem{}
+	for _, section := range results.sections() {
+		for _, item := range section.items {
+			if normalizeState {
+				item.CanonicalState = canonicalState(item)
 			}
-			allItems = append(allItems, GitHubItem{
-				Number:    node.Number,
-				Title:     node.Title,
-				HTMLURL:   node.URL,
-				Body:      node.Body,
-				State:     state,
-				CreatedAt: node.CreatedAt,
-				ClosedAt:  node.ClosedAt,
-			})
+			items = append(items, typedItem{Type: section.label, GitHubItem: item})
 		}
+	}
+	outputItemCount += len(items)
+	printJSON(items)
+}
 
-		if !resp.Search.PageInfo.HasNextPage {
-			break
+var outputFile *os.File    // Destination for --output once opened, nil for stdout
+var appendingToOutput bool // --append to a non-empty file: skip the header and BOM
+
+// outputDest returns where list output is written: the --output file if
+// set, otherwise stdout. The file is opened on first use, so a run that
+// fails before it has anything to write leaves the previous report intact.
+func outputDest() io.Writer {
+	if outputPath == "" {
+		return os.Stdout
+	}
+	if outputFile == nil {
+		f, hasContent, err := openOutputFile(outputPath, appendOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitFunc(1)
+			return io.Discard
 		}
-		cursor = &resp.Search.PageInfo.EndCursor
+		outputFile = f
+		appendingToOutput = hasContent
 	}
+	return outputFile
+}
 
-	if debug {
-		fmt.Printf("Fetched %d discussions for %s in %s\n", len(allItems), login, org)
+// closeOutputFile closes the --output file if it was opened.
+func closeOutputFile() {
+	if outputFile != nil {
+		outputFile.Close()
 	}
-
-	return allItems, nil
 }
 
-// contributionResults holds the results of fetching all contribution types concurrently.
-type contributionResults struct {
-	prItems         []GitHubItem
-	reviewItems     []GitHubItem
-	issueItems      []GitHubItem
-	discussionItems []GitHubItem
+// openOutputFile opens path for --output, truncating it unless appendMode is
+// set. It also reports whether the file already had content, in which case
+// appended output should not repeat the header.
+func openOutputFile(path string, appendMode bool) (*os.File, bool, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, false, fmt.Errorf("error opening output file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, fmt.Errorf("error reading output file: %w", err)
+	}
+	return f, appendMode && info.Size() > 0, nil
 }
 
-// fetchAllContributions fetches PRs, reviews, issues, and discussions concurrently.
-func fetchAllContributions(client GitHubClient, gqlClient GraphQLClient, login, org, sinceDate string) (*contributionResults, error) {
-	var (
-		wg      sync.WaitGroup
-		mu      sync.Mutex
-		results contributionResults
-		errs    []error
-	)
+// newCSVWriter returns a CSV writer on the output destination. With --bom it
+// first writes a UTF-8 byte order mark so Excel renders non-ASCII titles
+// correctly.
+func newCSVWriter() *csv.Writer {
+	recordOutputType("csv")
+	out := outputDest()
+	if bomFlag && !appendingToOutput {
+		io.WriteString(out, utf8BOM)
+	}
+	writer := csv.NewWriter(out)
+	writer.Comma = csvComma
+	return writer
+}
 
-	prQuery := buildQuery("is:pr", login)
-	prSearchURL := fmt.Sprintf("search/issues?q=%s", prQuery)
+// csvComma is the CSV field delimiter, set from --csv-delimiter.
+var csvComma = ','
 
-	reviewQuery := buildReviewQuery(login)
-	reviewSearchURL := fmt.Sprintf("search/issues?q=%s", reviewQuery)
+// parseCSVDelimiter parses a --csv-delimiter value: a single character other
+// than a quote, carriage return, or newline, which encoding/csv can't use.
+// European Excel, for one, expects ';'.
+func parseCSVDelimiter(value string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(value)
+	if size == 0 || size != len(value) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("--csv-delimiter must be a single character other than a quote or newline, got %q", value)
+	}
+	return r, nil
+}
 
-	issueQuery := buildQuery("is:issue", login)
-	issueSearchURL := fmt.Sprintf("search/issues?q=%s", issueQuery)
+// headerlessWriter drops the first record written to it, used to avoid
+// repeating the header when appending to an existing CSV file.
+type headerlessWriter struct {
+	rowWriter
+	skipped bool
+}
 
-	if debug {
-		fmt.Printf("Fetching PRs, reviews, issues, and discussions concurrently for %s\n", login)
+func (w *headerlessWriter) Write(record []string) error {
+	if !w.skipped {
+		w.skipped = true
+		return nil
 	}
+	return w.rowWriter.Write(record)
+}
 
-	wg.Add(4)
-
-	go func() {
-		defer wg.Done()
-		items, err := fetchAllResults(client, prSearchURL)
-		mu.Lock()
-		defer mu.Unlock()
-		if err != nil {
-			errs = append(errs, fmt.Errorf("error fetching pull requests: %w", err))
-			return
-		}
-		results.prItems = items
-	}()
+// rowWriter is implemented by the writers for the row-based output formats.
+type rowWriter interface {
+	Write(record []string) error
+	Flush()
+}
 
-	go func() {
-		defer wg.Done()
-		items, err := fetchAllResults(client, reviewSearchURL)
-		mu.Lock()
-		defer mu.Unlock()
-		if err != nil {
-			errs = append(errs, fmt.Errorf("error fetching reviews: %w", err))
-			return
-		}
-		results.reviewItems = items
-	}()
+// newRowWriter returns a table writer for --format table and a CSV (or,
+// for --format tsv, tab-separated) writer otherwise.
+func newRowWriter() rowWriter {
+	if outputFormat == "table" {
+		recordOutputType("table")
+		return &tableWriter{out: outputDest(), width: terminalWidthFunc()}
+	}
+	writer := newCSVWriter()
+	if outputFormat == "tsv" {
+		recordOutputType("tsv")
+		writer.Comma = '\t'
+	}
+	if appendingToOutput {
+		return &headerlessWriter{rowWriter: writer}
+	}
+	return writer
+}
 
-	go func() {
-		defer wg.Done()
-		items, err := fetchAllResults(client, issueSearchURL)
-		mu.Lock()
-		defer mu.Unlock()
-		if err != nil {
-			errs = append(errs, fmt.Errorf("error fetching issues: %w", err))
-			return
-		}
-		results.issueItems = items
-	}()
+var terminalWidthFunc = terminalWidth // Overridable for tests
 
-	go func() {
-		defer wg.Done()
-		items, err := fetchDiscussions(gqlClient, login, org, sinceDate)
-		mu.Lock()
-		defer mu.Unlock()
-		if err != nil {
-			errs = append(errs, fmt.Errorf("error fetching discussions: %w", err))
-			return
-		}
-		results.discussionItems = items
-	}()
+// terminalWidth returns the width of the attached terminal, or 0 if it
+// cannot be detected.
+func terminalWidth() int {
+	width, _, err := term.FromEnv().Size()
+	if err != nil || width <= 0 {
+		return 0
+	}
+	return width
+}
 
-	wg.Wait()
+const minTableColumnWidth = 10
+
+// tableWriter buffers rows and renders them on Flush as a box-drawn table
+// whose first row is the header. Columns are sized to their content; when
+// the table is wider than width, the Title column (or else the widest
+// column) is truncated to fit. A width of 0 means unknown, in which case a
+// plain space-aligned layout is used instead.
+type tableWriter struct {
+	out   io.Writer
+	width int
+	rows  [][]string
+}
 
-	if len(errs) > 0 {
-		return nil, errs[0]
+func (t *tableWriter) Write(record []string) error {
+	row := make([]string, len(record))
+	for i, cell := range record {
+		row[i] = strings.TrimSpace(cell)
 	}
+	t.rows = append(t.rows, row)
+	return nil
+}
 
-	// Deduplicate: remove reviews that the user also authored
-	results.reviewItems = deduplicateItems(results.prItems, results.reviewItems)
+func (t *tableWriter) Flush() {
+	if len(t.rows) == 0 {
+		return
+	}
+	widths := t.columnWidths()
+	if t.width <= 0 {
+		t.writeAligned(widths)
+	} else {
+		t.fitToWidth(widths)
+		t.writeBoxed(widths)
+	}
+	t.rows = nil
+}
 
-	return &results, nil
+func (t *tableWriter) columnWidths() []int {
+	widths := make([]int, len(t.rows[0]))
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if i < len(widths) && text.DisplayWidth(cell) > widths[i] {
+				widths[i] = text.DisplayWidth(cell)
+			}
+		}
+	}
+	return widths
 }
 
-func fetchAllResults(client GitHubClient, searchURL string) ([]GitHubItem, error) {
-	var allItems []GitHubItem
-	page := 1
-	const maxPages = 10 // Safety break to prevent infinite loops in case of API issues
+// fitToWidth shrinks one column so the boxed table fits in t.width.
+func (t *tableWriter) fitToWidth(widths []int) {
+	total := 1 // Left border
+	for _, w := range widths {
+		total += w + 3 // Padding on both sides plus the right border
+	}
+	excess := total - t.width
+	if excess <= 0 {
+		return
+	}
 
-	for page <= maxPages {
-		separator := "&"
-		if !strings.Contains(searchURL, "?") {
-			separator = "?"
+	col := -1
+	for i, name := range t.rows[0] {
+		if name == "Title" {
+			col = i
 		}
-		paginatedURL := fmt.Sprintf("%s%spage=%d&per_page=100", searchURL, separator, page)
-
-		if debug {
-			fmt.Printf("Fetching page %d: %s\n", page, paginatedURL)
+	}
+	if col < 0 {
+		for i, w := range widths {
+			if col < 0 || w > widths[col] {
+				col = i
+			}
 		}
+	}
+	widths[col] = max(widths[col]-excess, minTableColumnWidth)
+}
 
-		response := GitHubResponse{}
-
-		err := client.Get(paginatedURL, &response)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching page %d from %s: %w", page, paginatedURL, err)
+func (t *tableWriter) writeBoxed(widths []int) {
+	border := func(left, mid, right string) {
+		parts := make([]string, len(widths))
+		for i, w := range widths {
+			parts[i] = strings.Repeat("─", w+2)
 		}
+		fmt.Fprintln(t.out, left+strings.Join(parts, mid)+right)
+	}
 
-		if debug {
-			fmt.Printf("Page %d: Found %d items (TotalCount: %d)\n", page, len(response.Items), response.TotalCount)
+	border("┌", "┬", "┐")
+	for r, row := range t.rows {
+		cells := make([]string, len(widths))
+		for i, w := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = text.Truncate(w, row[i])
+			}
+			cells[i] = " " + text.PadRight(w, cell) + " "
 		}
-
-		allItems = append(allItems, response.Items...)
-
-		if len(response.Items) < 100 {
-			break
+		fmt.Fprintln(t.out, "│"+strings.Join(cells, "│")+"│")
+		if r == 0 {
+			border("├", "┼", "┤")
 		}
-
-		page++
 	}
+	border("└", "┴", "┘")
+}
 
-	if page > maxPages {
-		fmt.Fprintf(os.Stderr, "Warning: Reached maximum page limit (%d) for URL: %s\n", maxPages, searchURL)
+func (t *tableWriter) writeAligned(widths []int) {
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			if i == len(row)-1 {
+				cells[i] = cell // No trailing padding on the last column
+			} else {
+				cells[i] = text.PadRight(widths[i], cell)
+			}
+		}
+		fmt.Fprintln(t.out, strings.Join(cells, "  "))
 	}
-
-	return allItems, nil
 }
 
-func printUserInfo(client GitHubClient) {
-	response := struct{ Login string }{}
-	err := client.Get("user", &response)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error fetching user info: %v\n", err)
-		return
-	}
-	fmt.Printf("running as %s\n", response.Login)
+// itemRow is one row of row output: an item plus the cells some commands
+// add to it, the contribution type (all, attention) and reason (attention).
+type itemRow struct {
+	label  string
+	reason string
+	item   GitHubItem
 }
 
-func printHelp(client GitHubClient) {
-	fmt.Println("gh-contrib: A tool to better understand GitHub Issues and Pull Requests.")
-	printUserInfo(client)
-	fmt.Println("\nAvailable commands:")
-	fmt.Println("  pulls <username>   - Get Pull Requests authored by <username> in the 'github' (or specified) org.")
-	fmt.Println("  reviews <username> - Get Pull Requests reviewed by <username> in the 'github' (or specified) org.")
-	fmt.Println("  issues <username>  - Get Issues authored by <username> in the 'github' (or specified) org.")
-	fmt.Println("  discussions <username> - Get Discussions authored by <username> in the 'github' (or specified) org.")
-	fmt.Println("  all <username>     - Get all Pull Requests, Reviews, Issues, and Discussions by <username> in the 'github' (or specified) org.")
-	fmt.Println("  summarize          - Summarize PR/Issue bodies from stdin or argument. Use --prompt-only to output the raw prompt.")
-	fmt.Println("  graph <username>   - Graph visualization for contributions by <username>.")
-	fmt.Println("\nFlags:")
-	flag.PrintDefaults()
+// column is one column of row output: its header and how to render a row.
+type column struct {
+	header string
+	value  func(itemRow) string
 }
 
-func printPullRequestsAsCSV(pullRequests []GitHubItem) {
-	writer := csv.NewWriter(os.Stdout)
-	defer writer.Flush()
+var (
+	typeColumn   = column{"Type", func(r itemRow) string { return r.label }}
+	urlColumn    = column{"URL", func(r itemRow) string { return displayURL(r.item.HTMLURL) }}
+	titleColumn  = column{"Title", func(r itemRow) string { return r.item.Title }}
+	stateColumn  = column{"State", func(r itemRow) string { return displayState(r.item) }}
+	reasonColumn = column{"Reason", func(r itemRow) string { return r.reason }}
+)
 
-	// Write the header row
-	writer.Write([]string{"URL", "Title", "State"})
+// exportHeaders and exportColumns are the fixed --export schema. Type is the
+// contribution type for all (e.g. "Review") and the item type elsewhere;
+// state is the raw API state; dates are the API's ISO 8601 timestamps, with
+// closed_at empty for open items.
+var (
+	exportHeaders = []string{"repo", "number", "type", "state", "title", "url", "created_at", "closed_at"}
+	exportColumns = []column{
+		{exportHeaders[0], func(r itemRow) string { return repoFullName(r.item) }},
+		{exportHeaders[1], func(r itemRow) string { return fmt.Sprintf("%d", r.item.Number) }},
+		{exportHeaders[2], exportType},
+		{exportHeaders[3], func(r itemRow) string { return r.item.State }},
+		{exportHeaders[4], func(r itemRow) string { return r.item.Title }},
+		{exportHeaders[5], func(r itemRow) string { return r.item.HTMLURL }},
+		{exportHeaders[6], func(r itemRow) string { return r.item.CreatedAt }},
+		{exportHeaders[7], func(r itemRow) string { return r.item.ClosedAt }},
+	}
+)
 
-	// Write each pull request as a row
-	for _, pr := range pullRequests {
-		writer.Write([]string{
-			pr.HTMLURL + " ", // Add a space after the URL intentionally to make terminal clicking easier
-			pr.Title,
-			pr.State,
-		})
+// exportType returns the row's label, falling back to the item's own type
+// for commands that don't label rows.
+func exportType(r itemRow) string {
+	switch {
+	case r.label != "":
+		return r.label
+	case r.item.PullRequest != nil:
+		return "Pull Request"
 	}
+	return "Issue"
 }
 
-func printIssuesAsCSV(issues []GitHubItem) {
-	writer := csv.NewWriter(os.Stdout)
-	defer writer.Flush()
+// itemColumns returns the item columns shared by the list outputs, including
+// the optional ones enabled by flags.
+func itemColumns() []column {
+	columns := []column{urlColumn, titleColumn, stateColumn}
+	if normalizeState {
+		columns = append(columns, column{"Canonical State", func(r itemRow) string { return canonicalState(r.item) }})
+	}
+	if associationFlag != "" {
+		columns = append(columns, column{"Association", func(r itemRow) string { return r.item.AuthorAssociation }})
+	}
+	if withReactions {
+		columns = append(columns, column{"Reactions", func(r itemRow) string { return fmt.Sprintf("%d", r.item.TotalReactions) }})
+	}
+	if sortFlag == "comments" || withCommentCount {
+		columns = append(columns, column{"Comments", func(r itemRow) string { return fmt.Sprintf("%d", r.item.Comments) }})
+	}
+	if includeClosedByPR {
+		columns = append(columns, column{"Closed By", func(r itemRow) string { return r.item.ClosedByPR }})
+	}
+	if withReviewState {
+		columns = append(columns, column{"Review State", func(r itemRow) string { return r.item.ReviewState }})
+	}
+	if dedupeByTitle {
+		columns = append(columns, column{"Duplicates", func(r itemRow) string { return fmt.Sprintf("%d", r.item.Duplicates) }})
+	}
+	return columns
+}
 
-	// Write the header row
-	writer.Write([]string{"URL", "Title", "State"})
+// displayURL returns url followed by a space, which makes it easier to click
+// in a terminal, or url alone with --normalize-urls. JSON output never goes
+// through here, so its URLs are always clean.
+func displayURL(url string) string {
+	if normalizeURLs {
+		return url
+	}
+	return url + " "
+}
 
-	// Write each issue as a row
-	for _, issue := range issues {
-		writer.Write([]string{
-			issue.HTMLURL + " ", // Add a space after the URL intentionally to make terminal clicking easier
-			issue.Title,
-			issue.State,
-		})
+// canonicalState maps the item's API state onto one vocabulary shared by
+// every contribution type for --normalize-state: merged for merged pull
+// requests, not_planned for issues closed as not planned or as duplicates,
+// and otherwise the API's open or closed.
+func canonicalState(item GitHubItem) string {
+	switch {
+	case item.isMerged():
+		return "merged"
+	case item.State == "closed" && (item.StateReason == "not_planned" || item.StateReason == "duplicate"):
+		return "not_planned"
+	}
+	return item.State
+}
+
+// displayState returns the item's state for display: the API state by
+// default, or an icon distinguishing open, merged, and closed with --emoji.
+func displayState(item GitHubItem) string {
+	if !emojiFlag {
+		return item.State
 	}
+	switch {
+	case item.isMerged():
+		return "🟣"
+	case item.State == "open":
+		return "🟢"
+	case item.State == "closed":
+		return "🔴"
+	}
+	return item.State
 }
 
 func printBodies(items []GitHubItem, startMarker, endMarker string) {
 	for _, item := range items {
-		// Use the correct delimiter constant for consistency between entries
-		fmt.Printf("%s\n%s #%d\n%s\n%s\n%s\n", startMarker, item.Title, item.Number, item.Body, endMarker, entryDelimiter)
+		if containsAny(item.Body, startMarker, endMarker, markers.entry) {
+			fmt.Fprintf(warningWriter(), "Warning: body of %s contains an output marker and may be split incorrectly downstream\n", item.HTMLURL)
+		}
+		// Use the correct delimiter for consistency between entries
+		fmt.Printf("%s\n%s #%d\n%s\n%s\n%s\n", startMarker, item.Title, item.Number, redact(item.Body), endMarker, markers.entry)
+	}
+}
+
+// containsAny reports whether s contains any of the given substrings.
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if substr != "" && strings.Contains(s, substr) {
+			return true
+		}
 	}
+	return false
 }
 
 var modelConfigFunc = getModelFromConfig // Default to the actual implementation
@@ -1360,6 +6909,100 @@ func getModelFromConfig() string {
 	return defaultModel // Default to 'gpt-4o' if model is not configured
 }
 
+// extensionConfig holds the keys gh-contrib reads from the
+// extensions.gh-contrib section of the gh config file.
+type extensionConfig struct {
+	ContributorTypes map[string]string  `yaml:"contributor_types"`
+	IgnoreSections   []string           `yaml:"ignore_sections"`
+	StrictOrg        bool               `yaml:"strict_org"`
+	ScoreWeights     map[string]float64 `yaml:"score_weights"`
+}
+
+// readExtensionConfig reads the extensions.gh-contrib section from the gh
+// config file at GH_CONFIG_PATH or ~/.config/gh/config.yml. It returns the
+// zero value if the file or section is missing or unreadable.
+func readExtensionConfig() extensionConfig {
+	configPath := os.Getenv("GH_CONFIG_PATH")
+	if configPath == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return extensionConfig{}
+		}
+		configPath = filepath.Join(usr.HomeDir, ".config", "gh", "config.yml")
+	}
+
+	configData, err := os.ReadFile(configPath)
+	if err != nil {
+		return extensionConfig{}
+	}
+
+	var config struct {
+		Extensions map[string]extensionConfig `yaml:"extensions"`
+	}
+
+	if err := yaml.Unmarshal(configData, &config); err != nil {
+		// A value of the wrong type (say, a word where a score weight
+		// belongs) fails only that key; yaml.v2 still decodes the others,
+		// and dropping them would quietly turn off strict_org
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			configWarning.Do(func() {
+				fmt.Fprintf(warningWriter(), "Warning: ignoring the gh-contrib settings in %s: %v\n", configPath, err)
+			})
+			return extensionConfig{}
+		}
+		configWarning.Do(func() {
+			fmt.Fprintf(warningWriter(), "Warning: ignoring invalid gh-contrib settings in %s: %s\n", configPath, strings.Join(typeErr.Errors, "; "))
+		})
+	}
+
+	return config.Extensions["gh-contrib"]
+}
+
+// configWarning makes readExtensionConfig report a bad config file once per
+// run rather than once per key read.
+var configWarning sync.Once
+
+var contributorTypesConfigFunc = getContributorTypesFromConfig // Default to the actual implementation
+
+// getContributorTypesFromConfig reads extensions.gh-contrib.contributor_types,
+// a map of label name to all-contributors type. It returns nil if the map is
+// missing or unreadable.
+func getContributorTypesFromConfig() map[string]string {
+	return readExtensionConfig().ContributorTypes
+}
+
+var ignoreSectionsConfigFunc = getIgnoreSectionsFromConfig // Default to the actual implementation
+
+// getIgnoreSectionsFromConfig reads extensions.gh-contrib.ignore_sections.
+// It returns nil if the list is missing or unreadable.
+func getIgnoreSectionsFromConfig() []string {
+	return readExtensionConfig().IgnoreSections
+}
+
+// getStrictOrgFromConfig reads extensions.gh-contrib.strict_org. It returns
+// false if the key is missing or unreadable.
+func getStrictOrgFromConfig() bool {
+	return readExtensionConfig().StrictOrg
+}
+
+// getScoreWeightsFromConfig reads extensions.gh-contrib.score_weights, a map
+// of --score category to points. It returns nil if the map is missing or
+// unreadable.
+func getScoreWeightsFromConfig() map[string]float64 {
+	return readExtensionConfig().ScoreWeights
+}
+
+// updatedDate returns the item's updated_at when --date-field updated is
+// set, so graph buckets follow the same field the search filtered on.
+func updatedDate(item GitHubItem) (time.Time, bool) {
+	if dateField != "updated" || item.UpdatedAt == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, item.UpdatedAt)
+	return t, err == nil
+}
+
 // processItems adds items to the week map for visualization
 func processItems(items []GitHubItem, sinceDate time.Time, weekMap map[string]int, weekStartDates map[string]time.Time) {
 	for _, item := range items {
@@ -1367,7 +7010,9 @@ func processItems(items []GitHubItem, sinceDate time.Time, weekMap map[string]in
 		var itemDate time.Time
 		var err error
 
-		if item.ClosedAt != "" {
+		if updated, ok := updatedDate(item); ok {
+			itemDate = updated
+		} else if item.ClosedAt != "" {
 			itemDate, err = time.Parse(time.RFC3339, item.ClosedAt)
 			if err != nil {
 				// If we can't parse closed_at, try using created_at
@@ -1392,26 +7037,15 @@ func processItems(items []GitHubItem, sinceDate time.Time, weekMap map[string]in
 			itemDate = time.Now()
 		}
 
-		weekNumber := int(itemDate.Sub(sinceDate).Hours() / (24 * 7))
+		weekNumber := int(itemDate.Sub(sinceDate).Hours() / (24 * 7 * float64(weeksPerBucket)))
 		if weekNumber < 0 {
 			// Handle items that were closed before the since date
 			// This shouldn't happen with the API query, but just in case
 			weekNumber = 0
 		}
 
-		weekStart := sinceDate.AddDate(0, 0, weekNumber*7)
-		weekEnd := weekStart.AddDate(0, 0, 6)
-		// Ensure the end date doesn't go beyond today
-		now := time.Now()
-		if weekEnd.After(now) {
-			weekEnd = now
-		}
-		weekKey := fmt.Sprintf("Week %2d (%s - %s)",
-			weekNumber+1,
-			weekStart.Format("Jan 02"),
-			weekEnd.Format("Jan 02"))
-
-		weekMap[weekKey]++
+		weekKey, weekStart := graphBucket(weekNumber, sinceDate)
+		weekMap[weekKey] += itemWeight(item)
 		weekStartDates[weekKey] = weekStart
 	}
 }
@@ -1419,40 +7053,84 @@ func processItems(items []GitHubItem, sinceDate time.Time, weekMap map[string]in
 // countItemsByWeek counts items by week and state for visualization
 func countItemsByWeek(items []GitHubItem, itemType string, sinceDate time.Time, weekContributionMap map[string]map[contributionType]int) {
 	for _, item := range items {
-		// Use closed_at or created_at date to determine the week
-		var itemDate time.Time
-		var err error
+		contribType := contributionType{itemType, item.State}
 
-		if item.ClosedAt != "" {
-			itemDate, err = time.Parse(time.RFC3339, item.ClosedAt)
-			if err != nil && item.CreatedAt != "" {
-				itemDate, _ = time.Parse(time.RFC3339, item.CreatedAt)
-			}
-		} else if item.CreatedAt != "" {
+		weekContributionMap[itemWeekKey(item, sinceDate)][contribType] += itemWeight(item)
+	}
+}
+
+// itemWeight is how much an item adds to its graph bar: 1 by default, or its
+// total reactions with --weight reactions.
+func itemWeight(item GitHubItem) int {
+	if graphWeight == "reactions" {
+		return item.TotalReactions
+	}
+	return 1
+}
+
+// itemWeekKey returns the graph week an item is counted in, as the same
+// "Week N (start - end)" label used for the bars.
+func itemWeekKey(item GitHubItem, sinceDate time.Time) string {
+	// Use closed_at or created_at date to determine the week
+	var itemDate time.Time
+	var err error
+
+	if updated, ok := updatedDate(item); ok {
+		itemDate = updated
+	} else if item.ClosedAt != "" {
+		itemDate, err = time.Parse(time.RFC3339, item.ClosedAt)
+		if err != nil && item.CreatedAt != "" {
 			itemDate, _ = time.Parse(time.RFC3339, item.CreatedAt)
-		} else {
-			itemDate = time.Now()
 		}
+	} else if item.CreatedAt != "" {
+		itemDate, _ = time.Parse(time.RFC3339, item.CreatedAt)
+	} else {
+		itemDate = time.Now()
+	}
 
-		weekNumber := int(itemDate.Sub(sinceDate).Hours() / (24 * 7))
-		if weekNumber < 0 {
-			weekNumber = 0
-		}
+	weekNumber := int(itemDate.Sub(sinceDate).Hours() / (24 * 7 * float64(weeksPerBucket)))
+	if weekNumber < 0 {
+		weekNumber = 0
+	}
 
-		weekStart := sinceDate.AddDate(0, 0, weekNumber*7)
-		weekEnd := weekStart.AddDate(0, 0, 6)
-		// Ensure the end date doesn't go beyond today
-		now := time.Now()
-		if weekEnd.After(now) {
-			weekEnd = now
-		}
-		weekKey := fmt.Sprintf("Week %2d (%s - %s)",
-			weekNumber+1,
-			weekStart.Format("Jan 02"),
-			weekEnd.Format("Jan 02"))
+	key, _ := graphBucket(weekNumber, sinceDate)
+	return key
+}
 
-		contribType := contributionType{itemType, item.State}
+// bucketNoun names the graph buckets in notes and axis labels.
+func bucketNoun() string {
+	if weeksPerBucket > 1 {
+		return "sprints"
+	}
+	return "weeks"
+}
+
+// graphBucket returns the label and start date of the index-th graph bucket
+// after sinceDate: "Week N (start - end)" by default, or "Sprint N (start -
+// end)" when --granularity merges several weeks per bucket. The end date is
+// capped at the end of the window.
+func graphBucket(index int, sinceDate time.Time) (string, time.Time) {
+	start := sinceDate.AddDate(0, 0, index*7*weeksPerBucket)
+	end := start.AddDate(0, 0, 7*weeksPerBucket-1)
+	if now := windowEnd(); end.After(now) {
+		end = now
+	}
+	name := "Week"
+	if weeksPerBucket > 1 {
+		name = "Sprint"
+	}
+	return fmt.Sprintf("%s %2d (%s - %s)", name, index+1, start.Format("Jan 02"), end.Format("Jan 02")), start
+}
 
-		weekContributionMap[weekKey][contribType]++
+// parseGranularity parses a --granularity value: "week", or "Nw" for buckets
+// of N weeks (so "2w" is a biweekly, sprint-sized view). N must be at least 1.
+func parseGranularity(value string) (int, error) {
+	if value == "week" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(value, "w"))
+	if err != nil || !strings.HasSuffix(value, "w") || n < 1 {
+		return 0, fmt.Errorf("--granularity must be 'week' or 'Nw' with N at least 1 (e.g., 2w), got '%s'", value)
 	}
+	return n, nil
 }
@@ -3,13 +3,22 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/cli/go-gh/v2/pkg/text"
 )
 
 // --- Mock Implementations ---
@@ -18,7 +27,9 @@ import (
 type MockGitHubClient struct {
 	// GetFunc allows customizing the Get behavior for different paths.
 	GetFunc func(path string, response interface{}) error
-	// GetCalls records the paths called with Get.
+	// GetWithResponseFunc allows customizing the raw response for different paths.
+	GetWithResponseFunc func(path string) (*http.Response, error)
+	// GetCalls records the paths called with Get and GetWithResponse.
 	GetCalls []string
 	mu       sync.Mutex
 }
@@ -33,6 +44,16 @@ func (m *MockGitHubClient) Get(path string, response interface{}) error {
 	return nil
 }
 
+func (m *MockGitHubClient) GetWithResponse(path string) (*http.Response, error) {
+	m.mu.Lock()
+	m.GetCalls = append(m.GetCalls, path)
+	m.mu.Unlock()
+	if m.GetWithResponseFunc != nil {
+		return m.GetWithResponseFunc(path)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
 // MockGraphQLClient simulates the GitHub GraphQL API client.
 type MockGraphQLClient struct {
 	DoFunc  func(query string, variables map[string]interface{}, response interface{}) error
@@ -67,6 +88,7 @@ type MockSummarizer struct {
 	SummaryToReturn string
 	ErrorToReturn   error
 	SummarizeCalls  []string // Record the text passed to Summarize
+	CombinedCalls   []string // Record the text passed to SummarizeCombined
 }
 
 func (m *MockSummarizer) Summarize(text string) (string, error) {
@@ -74,6 +96,11 @@ func (m *MockSummarizer) Summarize(text string) (string, error) {
 	return m.SummaryToReturn, m.ErrorToReturn
 }
 
+func (m *MockSummarizer) SummarizeCombined(text string) (string, error) {
+	m.CombinedCalls = append(m.CombinedCalls, text)
+	return m.SummaryToReturn, m.ErrorToReturn
+}
+
 // --- Test Helper Functions ---
 
 // captureOutput captures stdout and stderr during a function execution.
@@ -106,9 +133,129 @@ func resetFlags() {
 	// NOTE: This is not robust if other packages define flags.
 	// Consider using specific flag sets or test setup/teardown for more complex scenarios.
 	debug = false
-	since = time.Now().AddDate(0, 0, -30).Format(dateFormat) // Reset to default
 	bodyOnly = false
 	visibilityFlag = ""
+	withReactions = false
+	maxItems = 0
+	githubOutput = false
+	withReviews = false
+	until = ""
+	periodFlag = ""
+	associationFlag = ""
+	bomFlag = false
+	timezoneFlag = ""
+	location = time.UTC
+	since = defaultSince() // After location, as in main
+	outputFormat = "csv"
+	rawOutput = false
+	ignoreSections = nil
+	baseFlag = ""
+	summaryLengthFlag = "medium"
+	combineFlag = false
+	emojiFlag = false
+	useGraphQL = false
+	outputPath = ""
+	appendOutput = false
+	closeOutputFile()
+	outputFile = nil
+	splitByRepo = false
+	outputDir = ""
+	calendarFlag = false
+	asciiFlag = false
+	jsonStream = false
+	filterPattern = nil
+	filterBody = false
+	topRepos = 0
+	checkClock = false
+	whenFlag = false
+	sortFlag = "created"
+	includeClosedByPR = false
+	quiet = false
+	suppressWarnings = false
+	retryEmpty = 0
+	maxConcurrency = defaultMaxConcurrency
+	exportPreset = false
+	dateField = "created"
+	aiRetries = 1
+	estimateFlag = false
+	detailedFlag = false
+	minWeekCount = 0
+	editFlag = false
+	graphType = "bar"
+	svgWidth, svgHeight = 800, 400
+	milestoneFlag = ""
+	authorCompany = false
+	closedSince = false
+	queryExtra = ""
+	summaryFooter = ""
+	batchSize = 1
+	onlyPRs = false
+	onlyIssues = false
+	granularityFlag = "week"
+	weeksPerBucket = 1
+	authorEmail = false
+	preserveLinksFlag = false
+	refreshTokenFlag = false
+	summarizeFormat = "markdown"
+	graphWeight = "count"
+	orgReposFilter = ""
+	topicRepos = nil
+	csvDelimiter = ","
+	csvComma = ','
+	untilInclusive = true
+	summarizeParallel = 1
+	scoreFlag = false
+	watchInterval = 0
+	prometheusFlag = false
+	withResponseTime = false
+	normalizeState = false
+	noSessionCache = false
+	searchCacheDir = ""
+	searchCacheIdentity = ""
+	redactFlag = false
+	redactAllow = nil
+	titleTemplate = ""
+	orgMembersOnly = false
+	pivotFlag = false
+	windowsFlag = ""
+	compareWindows = nil
+	manifestPath = ""
+	manifestEntries = nil
+	outputType = ""
+	outputItemCount = 0
+	releaseAnchor = "latest"
+	strictOrg = false
+	withCommentCount = false
+	orgMembersCache = make(map[string]map[string]bool)
+	comparePrevious = false
+	dedupeByTitle = false
+	dedupeStripPattern = nil
+	systemPromptOverride = ""
+	userPromptOverride = ""
+	countOnly = false
+	sinceLastRun = false
+	stateFile = ""
+	runFailed.Store(false)
+	searchSucceeded.Store(false)
+	linkedFlag = ""
+	normalizeURLs = false
+	failFast = false
+	sinceSource = "default (30 days ago)"
+	continueOnError = false
+	withReviewState = false
+	noSummaryCache = false
+	appendingToOutput = false
+	markers = bodyMarkers{
+		entry:           entryDelimiter,
+		prStart:         startOfPR,
+		prEnd:           endOfPR,
+		issueStart:      startOfIssue,
+		issueEnd:        endOfIssue,
+		reviewStart:     startOfReview,
+		reviewEnd:       endOfReview,
+		discussionStart: startOfDiscussion,
+		discussionEnd:   endOfDiscussion,
+	}
 }
 
 // --- Test Functions ---
@@ -160,6 +307,41 @@ func TestHandlePullsCommand_CSV(t *testing.T) {
 	}
 }
 
+func TestHandlePullsCommand_CSVDelimiter(t *testing.T) {
+	resetFlags()
+	csvComma = ';'
+	mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		resp := GitHubResponse{TotalCount: 1, Items: []GitHubItem{
+			{Number: 123, Title: "Fix a; b", HTMLURL: "http://example.com/pr/123", State: "open"},
+		}}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}}
+
+	stdout, _ := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	// Fields containing the delimiter are quoted
+	expected := "URL;Title;State\nhttp://example.com/pr/123 ;\"Fix a; b\";open\n"
+	if stdout != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, stdout)
+	}
+}
+
+func TestParseCSVDelimiter(t *testing.T) {
+	for _, value := range []string{";", "|", "\t", "§"} {
+		if r, err := parseCSVDelimiter(value); err != nil || string(r) != value {
+			t.Errorf("parseCSVDelimiter(%q) = %q, %v; want the character", value, r, err)
+		}
+	}
+	for _, value := range []string{"", ";;", "\"", "\n", "\r", "\xff"} {
+		if _, err := parseCSVDelimiter(value); err == nil {
+			t.Errorf("Expected parseCSVDelimiter(%q) to fail", value)
+		}
+	}
+}
+
 func TestHandlePullsCommand_BodyOnly(t *testing.T) {
 	resetFlags()
 	bodyOnly = true // Set the flag for this test
@@ -202,200 +384,143 @@ func TestHandlePullsCommand_BodyOnly(t *testing.T) {
 	}
 }
 
-func TestHandleIssuesCommand_CSV(t *testing.T) {
-	resetFlags()
-	mockClient := &MockGitHubClient{}
-	testLogin := "testuser"
-	testArgs := []string{"issues", testLogin}
-
-	mockClient.GetFunc = func(path string, response interface{}) error {
-		// Match percent-encoded Issue search URL
-		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Aissue") && strings.Contains(path, "author%3Atestuser") && strings.Contains(path, "page=1") {
-			resp := GitHubResponse{
-				TotalCount: 1,
-				Items: []GitHubItem{
-					{Number: 456, Title: "Test Issue", HTMLURL: "http://example.com/issue/456", State: "closed", Repository: struct {
-						Name string `json:"name"`
-					}{"another-repo"}},
-				},
+func TestLimitedClient_BoundsInFlightRequests(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	mock := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			mu.Lock()
+			inFlight++
+			if inFlight > peak {
+				peak = inFlight
 			}
-			data, _ := json.Marshal(resp)
-			return json.Unmarshal(data, response)
-		}
-		return fmt.Errorf("unexpected API call: %s", path)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return nil
+		},
 	}
-
-	stdout, stderr := captureOutput(func() {
-		handleIssuesCommand(testArgs, mockClient)
-	})
-
-	if stderr != "" {
-		t.Errorf("Expected no stderr, got: %s", stderr)
+	client := newLimitedClient(mock, 2)
+
+	// Two enrichment-style passes running side by side share the limit
+	var wg sync.WaitGroup
+	for pass := 0; pass < 2; pass++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runBounded(10, enrichmentConcurrency, func(i int) {
+				client.Get(fmt.Sprintf("repos/o/r/issues/%d", i), nil)
+			})
+		}()
 	}
+	wg.Wait()
 
-	expectedHeader := "URL,Title,State"
-	expectedRow := "http://example.com/issue/456 ,Test Issue,closed"
-
-	if !strings.Contains(stdout, expectedHeader) {
-		t.Errorf("Expected stdout to contain header '%s', got: %s", expectedHeader, stdout)
-	}
-	if !strings.Contains(stdout, expectedRow) {
-		t.Errorf("Expected stdout to contain row '%s', got: %s", expectedRow, stdout)
+	if peak > 2 {
+		t.Errorf("Expected at most 2 requests in flight, saw %d", peak)
 	}
-	if len(mockClient.GetCalls) != 1 {
-		t.Errorf("Expected 1 API call, got %d", len(mockClient.GetCalls))
+	if len(mock.GetCalls) != 20 {
+		t.Errorf("Expected every request to go through, got %d", len(mock.GetCalls))
 	}
 }
 
-func TestHandleAllCommand_CSV(t *testing.T) {
-	resetFlags()
-	mockClient := &MockGitHubClient{}
-	mockGQLClient := &MockGraphQLClient{}
-	testLogin := "testuser"
-	testArgs := []string{"all", testLogin}
-
-	mockClient.GetFunc = func(path string, response interface{}) error {
-		var items []GitHubItem
-		// Match percent-encoded PR URL
-		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3Atestuser") && strings.Contains(path, "page=1") {
-			items = []GitHubItem{
-				{Number: 123, Title: "Test PR", HTMLURL: "http://example.com/pr/123", State: "open", Repository: struct {
-					Name string `json:"name"`
-				}{"test-repo"}},
-			}
-		} else if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "reviewed-by%3Atestuser") && strings.Contains(path, "page=1") {
-			items = []GitHubItem{
-				{Number: 789, Title: "Reviewed PR", HTMLURL: "http://example.com/pr/789", State: "closed", Repository: struct {
-					Name string `json:"name"`
-				}{"review-repo"}},
-			}
-		} else if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Aissue") && strings.Contains(path, "author%3Atestuser") && strings.Contains(path, "page=1") {
-			items = []GitHubItem{
-				{Number: 456, Title: "Test Issue", HTMLURL: "http://example.com/issue/456", State: "closed", Repository: struct {
-					Name string `json:"name"`
-				}{"another-repo"}},
-			}
-		} else {
-			return fmt.Errorf("unexpected API call: %s", path)
-		}
-
-		resp := GitHubResponse{TotalCount: len(items), Items: items}
-		data, _ := json.Marshal(resp)
-		return json.Unmarshal(data, response)
+func TestLimitedClient_SharesLimitWithGraphQL(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	track := func() {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
 	}
-
-	// Mock GraphQL client returns one discussion
-	mockGQLClient.DoFunc = func(query string, variables map[string]interface{}, response interface{}) error {
-		resp := response.(*DiscussionSearchResponse)
-		resp.Search.Nodes = []struct {
-			Title     string `json:"title"`
-			URL       string `json:"url"`
-			Body      string `json:"body"`
-			Number    int    `json:"number"`
-			CreatedAt string `json:"createdAt"`
-			ClosedAt  string `json:"closedAt"`
-			Closed    bool   `json:"closed"`
-		}{
-			{Title: "Test Discussion", URL: "http://example.com/discussion/1", Number: 1, Closed: false, CreatedAt: "2025-01-20T00:00:00Z"},
-		}
-		resp.Search.PageInfo.HasNextPage = false
+	client := newLimitedClient(&MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		track()
 		return nil
-	}
+	}}, 2)
+	gqlClient := client.graphQL(&MockGraphQLClient{DoFunc: func(query string, variables map[string]interface{}, response interface{}) error {
+		track()
+		return nil
+	}})
 
-	stdout, stderr := captureOutput(func() {
-		handleAllCommand(testArgs, mockClient, mockGQLClient)
+	runBounded(10, 10, func(i int) {
+		if i%2 == 0 {
+			client.Get("user", nil)
+		} else {
+			gqlClient.Do("query", nil, nil)
+		}
 	})
-
-	if stderr != "" {
-		t.Errorf("Expected no stderr, got: %s", stderr)
-	}
-
-	expectedHeader := "Type,URL,Title,State"
-	expectedPRRow := "Pull Request,http://example.com/pr/123 ,Test PR,open"
-	expectedReviewRow := "Review,http://example.com/pr/789 ,Reviewed PR,closed"
-	expectedIssueRow := "Issue,http://example.com/issue/456 ,Test Issue,closed"
-	expectedDiscussionRow := "Discussion,http://example.com/discussion/1 ,Test Discussion,open"
-
-	if !strings.Contains(stdout, expectedHeader) {
-		t.Errorf("Expected stdout to contain header '%s', got: %s", expectedHeader, stdout)
-	}
-	if !strings.Contains(stdout, expectedPRRow) {
-		t.Errorf("Expected stdout to contain PR row '%s', got: %s", expectedPRRow, stdout)
-	}
-	if !strings.Contains(stdout, expectedReviewRow) {
-		t.Errorf("Expected stdout to contain Review row '%s', got: %s", expectedReviewRow, stdout)
-	}
-	if !strings.Contains(stdout, expectedIssueRow) {
-		t.Errorf("Expected stdout to contain Issue row '%s', got: %s", expectedIssueRow, stdout)
-	}
-	if !strings.Contains(stdout, expectedDiscussionRow) {
-		t.Errorf("Expected stdout to contain Discussion row '%s', got: %s", expectedDiscussionRow, stdout)
-	}
-	if len(mockClient.GetCalls) != 3 { // One for PRs, one for Reviews, one for Issues
-		t.Errorf("Expected 3 API calls, got %d", len(mockClient.GetCalls))
+	if peak > 2 {
+		t.Errorf("Expected REST and GraphQL to share the limit of 2, saw %d in flight", peak)
 	}
 }
 
-func TestHandleReviewsCommand_CSV(t *testing.T) {
+func TestHandlePullsCommand_WithReactions(t *testing.T) {
 	resetFlags()
+	withReactions = true
 	mockClient := &MockGitHubClient{}
-	testLogin := "testuser"
-	testArgs := []string{"reviews", testLogin}
+	testArgs := []string{"pulls", "testuser"}
 
 	mockClient.GetFunc = func(path string, response interface{}) error {
-		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "reviewed-by%3Atestuser") && strings.Contains(path, "page=1") {
+		if strings.Contains(path, "search/issues?q=") {
 			resp := GitHubResponse{
-				TotalCount: 1,
+				TotalCount: 2,
 				Items: []GitHubItem{
-					{Number: 789, Title: "Reviewed PR", HTMLURL: "http://example.com/pr/789", State: "closed", Repository: struct {
-						Name string `json:"name"`
-					}{"review-repo"}},
+					{Number: 1, Title: "Popular PR", HTMLURL: "https://github.com/octo/repo/pull/1", State: "open"},
+					{Number: 2, Title: "Quiet PR", HTMLURL: "https://github.com/octo/repo/pull/2", State: "closed"},
 				},
 			}
 			data, _ := json.Marshal(resp)
 			return json.Unmarshal(data, response)
 		}
+		if strings.HasPrefix(path, "repos/octo/repo/issues/1/reactions") {
+			data, _ := json.Marshal([]map[string]string{{"content": "+1"}, {"content": "heart"}, {"content": "rocket"}})
+			return json.Unmarshal(data, response)
+		}
+		if strings.HasPrefix(path, "repos/octo/repo/issues/2/reactions") {
+			return json.Unmarshal([]byte("[]"), response)
+		}
 		return fmt.Errorf("unexpected API call: %s", path)
 	}
 
 	stdout, stderr := captureOutput(func() {
-		handleReviewsCommand(testArgs, mockClient)
+		handlePullsCommand(testArgs, mockClient)
 	})
 
 	if stderr != "" {
 		t.Errorf("Expected no stderr, got: %s", stderr)
 	}
 
-	expectedHeader := "URL,Title,State"
-	expectedRow := "http://example.com/pr/789 ,Reviewed PR,closed"
-
-	if !strings.Contains(stdout, expectedHeader) {
-		t.Errorf("Expected stdout to contain header '%s', got: %s", expectedHeader, stdout)
+	expectedRows := []string{
+		"URL,Title,State,Reactions",
+		"https://github.com/octo/repo/pull/1 ,Popular PR,open,3",
+		"https://github.com/octo/repo/pull/2 ,Quiet PR,closed,0",
 	}
-	if !strings.Contains(stdout, expectedRow) {
-		t.Errorf("Expected stdout to contain row '%s', got: %s", expectedRow, stdout)
+	for _, row := range expectedRows {
+		if !strings.Contains(stdout, row) {
+			t.Errorf("Expected stdout to contain '%s', got: %s", row, stdout)
+		}
 	}
-	if len(mockClient.GetCalls) != 1 {
-		t.Errorf("Expected 1 API call, got %d", len(mockClient.GetCalls))
+	if len(mockClient.GetCalls) != 3 {
+		t.Errorf("Expected 3 API calls (search + 2 reactions), got %d", len(mockClient.GetCalls))
 	}
 }
 
-func TestHandleReviewsCommand_BodyOnly(t *testing.T) {
+func TestHandlePullsCommand_BodyOnlySkipsReactions(t *testing.T) {
 	resetFlags()
+	withReactions = true
 	bodyOnly = true
 	mockClient := &MockGitHubClient{}
-	testLogin := "testuser"
-	testArgs := []string{"reviews", testLogin}
 
 	mockClient.GetFunc = func(path string, response interface{}) error {
-		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "reviewed-by%3Atestuser") && strings.Contains(path, "page=1") {
+		if strings.Contains(path, "search/issues?q=") {
 			resp := GitHubResponse{
 				TotalCount: 1,
-				Items: []GitHubItem{
-					{Number: 789, Title: "Reviewed PR", Body: "Review body.", HTMLURL: "http://example.com/pr/789", State: "closed", Repository: struct {
-						Name string `json:"name"`
-					}{"review-repo"}},
-				},
+				Items:      []GitHubItem{{Number: 1, Title: "PR", HTMLURL: "https://github.com/octo/repo/pull/1", Body: "Body text"}},
 			}
 			data, _ := json.Marshal(resp)
 			return json.Unmarshal(data, response)
@@ -403,85 +528,2695 @@ func TestHandleReviewsCommand_BodyOnly(t *testing.T) {
 		return fmt.Errorf("unexpected API call: %s", path)
 	}
 
-	stdout, stderr := captureOutput(func() {
-		handleReviewsCommand(testArgs, mockClient)
+	stdout, _ := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
 	})
 
-	if stderr != "" {
-		t.Errorf("Expected no stderr, got: %s", stderr)
+	if !strings.Contains(stdout, "Body text") {
+		t.Errorf("Expected the body in stdout, got: %s", stdout)
+	}
+	if len(mockClient.GetCalls) != 1 {
+		t.Errorf("Expected only the search call under --body-only, got %v", mockClient.GetCalls)
 	}
+}
 
-	expectedOutput := fmt.Sprintf("%s\n%s #%d\n%s\n%s\n%s\n", startOfReview, "Reviewed PR", 789, "Review body.", endOfReview, entryDelimiter)
+func TestGraphQLUserClient(t *testing.T) {
+	restClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			if path == "user" {
+				return fmt.Errorf("expected user lookup to go through GraphQL")
+			}
+			return json.Unmarshal([]byte(`{"total_count":0,"items":[]}`), response)
+		},
+	}
+	gqlClient := &MockGraphQLClient{
+		DoFunc: func(query string, variables map[string]interface{}, response interface{}) error {
+			return json.Unmarshal([]byte(`{"viewer":{"login":"octocat"}}`), response)
+		},
+	}
+	client := &graphQLUserClient{GitHubClient: restClient, gql: gqlClient}
 
-	if stdout != expectedOutput {
-		t.Errorf("Expected stdout to be:\n%s\nGot:\n%s", expectedOutput, stdout)
+	login, err := resolveLogin([]string{"pulls"}, client)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if login != "octocat" {
+		t.Errorf("Expected login 'octocat', got %q", login)
+	}
+	if len(gqlClient.DoCalls) != 1 || gqlClient.DoCalls[0] != viewerQuery {
+		t.Errorf("Expected one viewer query, got %v", gqlClient.DoCalls)
+	}
+
+	var search GitHubResponse
+	if err := client.Get("search/issues?q=test", &search); err != nil {
+		t.Errorf("Expected other paths to use REST, got error: %v", err)
+	}
+	if len(restClient.GetCalls) != 1 || restClient.GetCalls[0] != "search/issues?q=test" {
+		t.Errorf("Expected only the search call on the REST client, got %v", restClient.GetCalls)
 	}
 }
 
-func TestBuildReviewQuery(t *testing.T) {
-	resetFlags()
-	testLogin := "testuser"
+func TestCommandHelps_FlagsExist(t *testing.T) {
+	for name, help := range commandHelps {
+		for _, flagName := range help.flags {
+			if flag.CommandLine.Lookup(flagName) == nil {
+				t.Errorf("%s help lists unknown flag --%s", name, flagName)
+			}
+		}
+	}
+}
 
-	originalOrgConfigFunc := orgConfigFunc
-	orgConfigFunc = func() (string, error) {
-		return "github", nil
+func TestCuratedExamples_FlagsAndCommandsExist(t *testing.T) {
+	for _, example := range curatedExamples {
+		// Each command in a pipeline must be a known subcommand with known flags
+		for _, stage := range strings.Split(example.command, "|") {
+			fields := strings.Fields(stage)
+			if len(fields) < 3 || fields[0] != "gh" || fields[1] != "contrib" {
+				t.Errorf("Example %q: expected a gh contrib command, got %q", example.description, stage)
+				continue
+			}
+			hasCommand := false
+			for _, field := range fields[2:] {
+				if name, ok := strings.CutPrefix(field, "--"); ok {
+					name, _, _ = strings.Cut(name, "=")
+					if flag.CommandLine.Lookup(name) == nil {
+						t.Errorf("Example %q uses unknown flag --%s", example.description, name)
+					}
+				} else if _, ok := commandHelps[field]; ok {
+					hasCommand = true
+				}
+			}
+			if !hasCommand {
+				t.Errorf("Example %q: no known command in %q", example.description, stage)
+			}
+		}
 	}
-	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+}
 
-	since = "2025-01-15"
-	expected := "is%3Apr+org%3Agithub+reviewed-by%3Atestuser+sort%3Acreated-desc+created%3A%3E2025-01-15"
-	actual := buildReviewQuery(testLogin)
-	if actual != expected {
-		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+func TestPrintExamples(t *testing.T) {
+	stdout, _ := captureOutput(printExamples)
+	if !strings.Contains(stdout, "  # "+curatedExamples[0].description+"\n  "+curatedExamples[0].command+"\n") {
+		t.Errorf("Expected each description above its command, got:\n%s", stdout)
 	}
 }
 
-func TestDeduplicateItems(t *testing.T) {
-	existing := []GitHubItem{
-		{HTMLURL: "http://example.com/pr/1"},
-		{HTMLURL: "http://example.com/pr/2"},
+func TestPrintCommandHelp(t *testing.T) {
+	stdout, _ := captureOutput(func() {
+		if !printCommandHelp("pulls", flag.CommandLine) {
+			t.Error("Expected help for pulls")
+		}
+	})
+	for _, expected := range []string{"Usage: gh contrib pulls [username] [flags]", "  --base\n", "Examples:\n  gh contrib pulls octocat"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected help to contain %q, got:\n%s", expected, stdout)
+		}
 	}
-	candidates := []GitHubItem{
-		{HTMLURL: "http://example.com/pr/2", Title: "Duplicate"},
-		{HTMLURL: "http://example.com/pr/3", Title: "Unique"},
+	if strings.Contains(stdout, "--prompt-only") {
+		t.Errorf("Expected summarize-only flags to be omitted, got:\n%s", stdout)
 	}
 
-	result := deduplicateItems(existing, candidates)
+	if printCommandHelp("unknown", flag.CommandLine) {
+		t.Error("Expected no help for an unknown command")
+	}
+}
 
-	if len(result) != 1 {
-		t.Fatalf("Expected 1 item after dedup, got %d", len(result))
+func TestFetchAllResults_WarnsOverSearchCap(t *testing.T) {
+	tests := []struct {
+		name       string
+		totalCount int
+		wantWarn   bool
+	}{
+		{"under cap", 1000, false},
+		{"over cap", 2500, true},
 	}
-	if result[0].HTMLURL != "http://example.com/pr/3" {
-		t.Errorf("Expected unique item, got %s", result[0].HTMLURL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags()
+			mockClient := &MockGitHubClient{
+				GetFunc: func(path string, response interface{}) error {
+					resp := GitHubResponse{TotalCount: tt.totalCount, Items: []GitHubItem{{Number: 1, HTMLURL: "http://example.com/pr/1"}}}
+					data, _ := json.Marshal(resp)
+					return json.Unmarshal(data, response)
+				},
+			}
+
+			_, stderr := captureOutput(func() {
+				if _, err := fetchAllResults(mockClient, "search/issues?q=test"); err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			})
+
+			warned := strings.Contains(stderr, "GitHub only returns the first 1000")
+			if warned != tt.wantWarn {
+				t.Errorf("Expected warning=%v, got stderr: %q", tt.wantWarn, stderr)
+			}
+		})
 	}
 }
 
-func TestHandleSummarizeCommand(t *testing.T) {
+func TestFetchAllResults_QueryRejected(t *testing.T) {
 	resetFlags()
-	mockSummarizer := &MockSummarizer{
-		SummaryToReturn: "This is the summary.",
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			return &api.HTTPError{StatusCode: http.StatusUnprocessableEntity, Message: "Validation Failed"}
+		},
 	}
-	testArgs := []string{"summarize"} // Input will come from stdin
-
-	// Prepare stdin
-	inputBody := "Some text to summarize" + entryDelimiter + "Another piece of text"
-	oldStdin := os.Stdin
-	r, w, _ := os.Pipe()
-	os.Stdin = r
-	w.WriteString(inputBody)
-	w.Close()
-	defer func() { os.Stdin = oldStdin }() // Restore stdin
 
-	stdout, stderr := captureOutput(func() {
-		handleSummarizeCommand(testArgs, mockSummarizer, false)
-	})
+	_, err := fetchAllResults(mockClient, "search/issues?q=test")
+	if err == nil || !strings.Contains(err.Error(), "rejected the search query: Validation Failed") || !strings.Contains(err.Error(), "fewer qualifiers") {
+		t.Errorf("Expected a rejected-query error, got: %v", err)
+	}
 
-	if stderr != "" {
-		t.Errorf("Expected no stderr, got: %s", stderr)
+	// The API's own explanation is shown rather than a guessed cause
+	missingUser := "The listed users cannot be searched either because the users do not exist or you do not have permission to view the users."
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		return &api.HTTPError{StatusCode: http.StatusUnprocessableEntity, Message: "Validation Failed", Errors: []api.HTTPErrorItem{{Message: missingUser}}}
+	}
+	if _, err := fetchAllResults(mockClient, "search/issues?q=test"); err == nil || !strings.Contains(err.Error(), "rejected the search query: "+missingUser) {
+		t.Errorf("Expected the API's error message, got: %v", err)
 	}
 
-	expectedOutput := `This is the summary.
-This is the summary.
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		return &api.HTTPError{StatusCode: http.StatusInternalServerError}
+	}
+	if _, err := fetchAllResults(mockClient, "search/issues?q=test"); err == nil || strings.Contains(err.Error(), "rejected the search query") {
+		t.Errorf("Expected a generic error for other statuses, got: %v", err)
+	}
+}
+
+func TestFetchAllResults_SessionCache(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	defer func() { timeNowFunc = time.Now }()
+	// a directory that doesn't exist yet, so the cache creates it
+	searchCacheDir = filepath.Join(t.TempDir(), "searches")
+
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			var resp GitHubResponse
+			if strings.Contains(path, "q=found") {
+				resp.Items = []GitHubItem{{Number: 1, Title: "Cached", HTMLURL: "http://example.com/pr/1", State: "open"}}
+			}
+			if strings.Contains(path, "q=big") {
+				resp.TotalCount = 1500
+				resp.Items = []GitHubItem{{Number: 2, Title: "Truncated", HTMLURL: "http://example.com/pr/2", State: "open"}}
+			}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		},
+	}
+	fetch := func(searchURL string) []GitHubItem {
+		items, err := fetchAllResults(mockClient, searchURL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return items
+	}
+
+	fetch("search/issues?q=found")
+	items := fetch("search/issues?q=found")
+	if len(mockClient.GetCalls) != 1 {
+		t.Errorf("Expected the repeated search to be served from the cache, got %d API calls", len(mockClient.GetCalls))
+	}
+	if len(items) != 1 || items[0].Title != "Cached" {
+		t.Errorf("Expected the cached item, got %+v", items)
+	}
+
+	t.Run("DifferentQueryMisses", func(t *testing.T) {
+		mockClient.GetCalls = nil
+		fetch("search/issues?q=found+is%3Aissue")
+		if len(mockClient.GetCalls) != 1 {
+			t.Errorf("Expected a different query to be fetched, got %d API calls", len(mockClient.GetCalls))
+		}
+	})
+
+	t.Run("EmptyResultsNotCached", func(t *testing.T) {
+		mockClient.GetCalls = nil
+		fetch("search/issues?q=empty")
+		fetch("search/issues?q=empty")
+		if len(mockClient.GetCalls) != 2 {
+			t.Errorf("Expected empty results to be fetched again, got %d API calls", len(mockClient.GetCalls))
+		}
+	})
+
+	t.Run("ExpiredAfterTTL", func(t *testing.T) {
+		mockClient.GetCalls = nil
+		timeNowFunc = func() time.Time { return time.Now().Add(searchCacheTTL + time.Minute) }
+		fetch("search/issues?q=found")
+		if len(mockClient.GetCalls) != 1 {
+			t.Errorf("Expected an expired entry to be fetched again, got %d API calls", len(mockClient.GetCalls))
+		}
+		timeNowFunc = time.Now
+
+		stale := time.Now().Add(-searchCacheTTL - time.Minute)
+		os.Chtimes(searchCachePath("search/issues?q=found+is%3Aissue"), stale, stale)
+		pruneSearchCache()
+		entries, _ := os.ReadDir(searchCacheDir)
+		if len(entries) != 1 {
+			t.Errorf("Expected only the fresh entry to survive pruning, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("IncompleteWarningRepeated", func(t *testing.T) {
+		mockClient.GetCalls = nil
+		_, stderr := captureOutput(func() {
+			fetch("search/issues?q=big")
+			fetch("search/issues?q=big")
+		})
+		if len(mockClient.GetCalls) != 1 {
+			t.Errorf("Expected the repeated search to be served from the cache, got %d API calls", len(mockClient.GetCalls))
+		}
+		if strings.Count(stderr, "results are incomplete") != 2 {
+			t.Errorf("Expected the incomplete-results warning on the cache hit too, got %q", stderr)
+		}
+	})
+
+	t.Run("PrivateFiles", func(t *testing.T) {
+		// A directory and file left world-readable by an older version
+		// are tightened on the next write
+		os.Chmod(searchCacheDir, 0o755)
+		os.WriteFile(searchCachePath("search/issues?q=found"), []byte("stale"), 0o644)
+		os.Chmod(searchCachePath("search/issues?q=found"), 0o644)
+		fetch("search/issues?q=found")
+
+		for path, want := range map[string]os.FileMode{
+			searchCacheDir:                           0o700,
+			searchCachePath("search/issues?q=found"): 0o600,
+		} {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if info.Mode().Perm() != want {
+				t.Errorf("Expected %s to be %v, got %v", path, want, info.Mode().Perm())
+			}
+		}
+	})
+
+	t.Run("OtherIdentityMisses", func(t *testing.T) {
+		mockClient.GetCalls = nil
+		searchCacheIdentity = "github.example.com\nother-user"
+		fetch("search/issues?q=found")
+		if len(mockClient.GetCalls) != 1 {
+			t.Errorf("Expected another identity's results not to be reused, got %d API calls", len(mockClient.GetCalls))
+		}
+		searchCacheIdentity = ""
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		mockClient.GetCalls = nil
+		searchCacheDir = ""
+		fetch("search/issues?q=found")
+		if len(mockClient.GetCalls) != 1 {
+			t.Errorf("Expected no cache when disabled, got %d API calls", len(mockClient.GetCalls))
+		}
+	})
+}
+
+func TestFetchAllResults_RetryEmpty(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	retryEmpty = 3
+
+	var slept []time.Duration
+	sleepFunc = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleepFunc = time.Sleep }()
+
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			resp := GitHubResponse{}
+			if len(slept) == 2 {
+				resp.Items = []GitHubItem{{Number: 1, HTMLURL: "http://example.com/pr/1"}}
+			}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	items, err := fetchAllResults(mockClient, "search/issues?q=test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected 1 item after retrying, got %d", len(items))
+	}
+	if len(mockClient.GetCalls) != 3 || len(slept) != 2 {
+		t.Errorf("Expected 3 searches and 2 waits, got %d searches and %d waits", len(mockClient.GetCalls), len(slept))
+	}
+
+	t.Run("GivesUpAfterN", func(t *testing.T) {
+		slept = nil
+		mockClient := &MockGitHubClient{
+			GetFunc: func(path string, response interface{}) error {
+				data, _ := json.Marshal(GitHubResponse{})
+				return json.Unmarshal(data, response)
+			},
+		}
+		items, err := fetchAllResults(mockClient, "search/issues?q=test")
+		if err != nil || len(items) != 0 {
+			t.Errorf("Expected no items and no error, got %d items, err %v", len(items), err)
+		}
+		if len(mockClient.GetCalls) != 4 {
+			t.Errorf("Expected 1 search plus 3 retries, got %d", len(mockClient.GetCalls))
+		}
+	})
+}
+
+func TestHandleIssuesCommand_IncludeClosedByPR(t *testing.T) {
+	resetFlags()
+	includeClosedByPR = true
+	mockClient := &MockGitHubClient{}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		switch {
+		case strings.Contains(path, "search/issues?q="):
+			resp := GitHubResponse{Items: []GitHubItem{
+				{Number: 1, Title: "Fixed by PR", HTMLURL: "https://github.com/octo/repo/issues/1", State: "closed"},
+				{Number: 2, Title: "Closed manually", HTMLURL: "https://github.com/octo/repo/issues/2", State: "closed"},
+				{Number: 3, Title: "Still open", HTMLURL: "https://github.com/octo/repo/issues/3", State: "open"},
+				{Number: 4, Title: "Direct push", HTMLURL: "https://github.com/octo/repo/issues/4", State: "closed"},
+			}}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		case strings.HasPrefix(path, "repos/octo/repo/issues/1/timeline"):
+			return json.Unmarshal([]byte(`[
+				{"event":"cross-referenced","source":{"issue":{"html_url":"https://github.com/octo/repo/pull/9","pull_request":{}}}},
+				{"event":"closed","commit_id":"abc123","commit_url":"https://api.github.com/repos/octo/lib/commits/abc123"}
+			]`), response)
+		case path == "repos/octo/lib/commits/abc123/pulls":
+			return json.Unmarshal([]byte(`[
+				{"html_url":"https://github.com/octo/lib/pull/3","merged_at":null},
+				{"html_url":"https://github.com/octo/lib/pull/10","merged_at":"2025-04-20T10:00:00Z","merge_commit_sha":"abc123"}
+			]`), response)
+		case strings.HasPrefix(path, "repos/octo/repo/issues/2/timeline"):
+			return json.Unmarshal([]byte(`[{"event":"closed","commit_id":null}]`), response)
+		case strings.HasPrefix(path, "repos/octo/repo/issues/4/timeline"):
+			// A merged PR mentioned the issue, but a direct commit closed it
+			return json.Unmarshal([]byte(`[
+				{"event":"cross-referenced","source":{"issue":{"html_url":"https://github.com/octo/repo/pull/11","pull_request":{"merged_at":"2025-04-20T10:00:00Z"}}}},
+				{"event":"closed","commit_id":"def456","commit_url":"https://api.github.com/repos/octo/repo/commits/def456"}
+			]`), response)
+		case path == "repos/octo/repo/commits/def456/pulls":
+			return json.Unmarshal([]byte(`[]`), response)
+		}
+		return fmt.Errorf("unexpected API call: %s", path)
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleIssuesCommand([]string{"issues", "testuser"}, mockClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+	expected := "URL,Title,State,Closed By\n" +
+		"https://github.com/octo/repo/issues/1 ,Fixed by PR,closed,https://github.com/octo/lib/pull/10\n" +
+		"https://github.com/octo/repo/issues/2 ,Closed manually,closed,\n" +
+		"https://github.com/octo/repo/issues/3 ,Still open,open,\n" +
+		"https://github.com/octo/repo/issues/4 ,Direct push,closed,\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+	if len(mockClient.GetCalls) != 6 {
+		t.Errorf("Expected 6 API calls (search, 3 timelines for closed issues, 2 closing commits), got %d", len(mockClient.GetCalls))
+	}
+}
+
+func TestHandleIssuesCommand_WithResponseTime(t *testing.T) {
+	resetFlags()
+	withResponseTime = true
+	mockClient := &MockGitHubClient{}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		switch {
+		case strings.Contains(path, "search/issues?q="):
+			resp := GitHubResponse{Items: []GitHubItem{
+				{Number: 1, Title: "Quick reply", HTMLURL: "https://github.com/octo/repo/issues/1", State: "open", CreatedAt: "2025-04-20T10:00:00Z", Comments: 3},
+				{Number: 2, Title: "Slow reply", HTMLURL: "https://github.com/octo/repo/issues/2", State: "open", CreatedAt: "2025-04-20T10:00:00Z", Comments: 1},
+				{Number: 3, Title: "Only me and a bot", HTMLURL: "https://github.com/octo/repo/issues/3", State: "open", CreatedAt: "2025-04-20T10:00:00Z", Comments: 2},
+				{Number: 4, Title: "No comments", HTMLURL: "https://github.com/octo/repo/issues/4", State: "open", CreatedAt: "2025-04-20T10:00:00Z"},
+			}}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		case strings.HasPrefix(path, "repos/octo/repo/issues/1/comments"):
+			return json.Unmarshal([]byte(`[
+				{"created_at":"2025-04-20T10:05:00Z","user":{"login":"TestUser","type":"User"}},
+				{"created_at":"2025-04-20T12:30:00Z","user":{"login":"maintainer","type":"User"}},
+				{"created_at":"2025-04-21T09:00:00Z","user":{"login":"other","type":"User"}}
+			]`), response)
+		case strings.HasPrefix(path, "repos/octo/repo/issues/2/comments"):
+			return json.Unmarshal([]byte(`[{"created_at":"2025-04-22T14:30:00Z","user":{"login":"maintainer","type":"User"}}]`), response)
+		case strings.HasPrefix(path, "repos/octo/repo/issues/3/comments"):
+			return json.Unmarshal([]byte(`[
+				{"created_at":"2025-04-20T10:00:30Z","user":{"login":"triage[bot]","type":"Bot"}},
+				{"created_at":"2025-04-20T11:00:00Z","user":{"login":"testuser","type":"User"}}
+			]`), response)
+		}
+		return fmt.Errorf("unexpected API call: %s", path)
+	}
+
+	_, stderr := captureOutput(func() {
+		handleIssuesCommand([]string{"issues", "testuser"}, mockClient)
+	})
+
+	// Responses after 2h 30m and 2d 4h 30m
+	expected := "First response: median 1d 3h, average 1d 3h across 2 of 4 issues (2 with no response yet)\n"
+	if stderr != expected {
+		t.Errorf("Expected stderr %q, got %q", expected, stderr)
+	}
+	if len(mockClient.GetCalls) != 4 {
+		t.Errorf("Expected 4 API calls (search + comments for 3 commented issues), got %d", len(mockClient.GetCalls))
+	}
+}
+
+func TestResponseTimeSummary_NoResponses(t *testing.T) {
+	items := []GitHubItem{{CreatedAt: "2025-04-20T10:00:00Z"}, {CreatedAt: "2025-04-21T10:00:00Z"}}
+	if got, want := responseTimeSummary(items), "First response: none of the 2 issues has a response yet"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestFormatResponseTime(t *testing.T) {
+	tests := map[time.Duration]string{
+		30 * time.Second:              "<1m",
+		12 * time.Minute:              "12m",
+		3*time.Hour + 15*time.Minute:  "3h 15m",
+		52*time.Hour + 40*time.Minute: "2d 4h",
+	}
+	for d, want := range tests {
+		if got := formatResponseTime(d); got != want {
+			t.Errorf("formatResponseTime(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestParseItemURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOwner  string
+		wantRepo   string
+		wantNumber int
+		wantOK     bool
+	}{
+		{"pull request", "https://github.com/octo/repo/pull/12", "octo", "repo", 12, true},
+		{"issue", "https://github.com/octo/repo/issues/34", "octo", "repo", 34, true},
+		{"discussion", "https://github.com/octo/repo/discussions/5", "", "", 0, false},
+		{"malformed", "not a url", "", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, repo, number, ok := parseItemURL(tt.input)
+			if ok != tt.wantOK || owner != tt.wantOwner || repo != tt.wantRepo || number != tt.wantNumber {
+				t.Errorf("parseItemURL(%q) = (%q, %q, %d, %v), want (%q, %q, %d, %v)",
+					tt.input, owner, repo, number, ok, tt.wantOwner, tt.wantRepo, tt.wantNumber, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDisplayState_Emoji(t *testing.T) {
+	resetFlags()
+	merged := GitHubItem{State: "closed", PullRequest: &pullRequestRef{MergedAt: "2025-04-20T10:00:00Z"}}
+	closed := GitHubItem{State: "closed", PullRequest: &pullRequestRef{}}
+	open := GitHubItem{State: "open"}
+
+	if got := displayState(merged); got != "closed" {
+		t.Errorf("Expected plain state by default, got %q", got)
+	}
+
+	emojiFlag = true
+	tests := []struct {
+		name string
+		item GitHubItem
+		want string
+	}{
+		{"open", open, "🟢"},
+		{"merged", merged, "🟣"},
+		{"closed", closed, "🔴"},
+	}
+	for _, tt := range tests {
+		if got := displayState(tt.item); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCanonicalState(t *testing.T) {
+	tests := []struct {
+		name string
+		item GitHubItem
+		want string
+	}{
+		{"open issue", GitHubItem{State: "open"}, "open"},
+		{"open pull request", GitHubItem{State: "open", PullRequest: &pullRequestRef{}}, "open"},
+		{"merged pull request", GitHubItem{State: "closed", PullRequest: &pullRequestRef{MergedAt: "2025-04-20T10:00:00Z"}}, "merged"},
+		{"closed pull request", GitHubItem{State: "closed", PullRequest: &pullRequestRef{}}, "closed"},
+		{"completed issue", GitHubItem{State: "closed", StateReason: "completed"}, "closed"},
+		{"not planned issue", GitHubItem{State: "closed", StateReason: "not_planned"}, "not_planned"},
+		{"duplicate issue", GitHubItem{State: "closed", StateReason: "duplicate"}, "not_planned"},
+		{"reopened issue", GitHubItem{State: "open", StateReason: "reopened"}, "open"},
+	}
+	for _, tt := range tests {
+		if got := canonicalState(tt.item); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestNormalizeState_Output(t *testing.T) {
+	resetFlags()
+	normalizeState = true
+	items := []GitHubItem{
+		{HTMLURL: "https://github.com/octo/repo/pull/1", Title: "Merged", State: "closed", PullRequest: &pullRequestRef{MergedAt: "2025-04-20T10:00:00Z"}},
+		{HTMLURL: "https://github.com/octo/repo/issues/2", Title: "Wontfix", State: "closed", StateReason: "not_planned"},
+	}
+
+	stdout, _ := captureOutput(func() { printItems(items) })
+	expected := "URL,Title,State,Canonical State\n" +
+		"https://github.com/octo/repo/pull/1 ,Merged,closed,merged\n" +
+		"https://github.com/octo/repo/issues/2 ,Wontfix,closed,not_planned\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+
+	stdout, _ = captureOutput(func() { printItemsAsJSON(items) })
+	if !strings.Contains(stdout, `"canonical_state": "merged"`) || !strings.Contains(stdout, `"canonical_state": "not_planned"`) {
+		t.Errorf("Expected canonical_state fields in JSON, got:\n%s", stdout)
+	}
+}
+
+func TestPrintBodies_CustomMarkers(t *testing.T) {
+	resetFlags()
+	markers.entry = "<<<END>>>"
+	items := []GitHubItem{{Number: 7, Title: "Custom", Body: "Plain body.", HTMLURL: "http://example.com/pr/7"}}
+
+	stdout, stderr := captureOutput(func() {
+		printBodies(items, "<<<PR>>>", "<<</PR>>>")
+	})
+
+	expected := "<<<PR>>>\nCustom #7\nPlain body.\n<<</PR>>>\n<<<END>>>\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+}
+
+func TestPrintBodies_WarnsWhenBodyContainsMarker(t *testing.T) {
+	resetFlags()
+	items := []GitHubItem{{Number: 8, Title: "Sneaky", Body: "quoting " + entryDelimiter + " here", HTMLURL: "http://example.com/pr/8"}}
+
+	_, stderr := captureOutput(func() {
+		printBodies(items, markers.prStart, markers.prEnd)
+	})
+
+	if !strings.Contains(stderr, "http://example.com/pr/8 contains an output marker") {
+		t.Errorf("Expected marker warning on stderr, got: %s", stderr)
+	}
+}
+
+func TestWriteRows_SharedColumnsMatch(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	withReactions = true
+	item := GitHubItem{Title: "Title, with comma", HTMLURL: "http://example.com/pr/1", State: "open", TotalReactions: 3}
+
+	single, _ := captureOutput(func() { printItems([]GitHubItem{item}) })
+	combined, _ := captureOutput(func() {
+		writeRows(append([]column{typeColumn}, itemColumns()...), []itemRow{{label: "Pull Request", item: item}})
+	})
+
+	wantSingle := "URL,Title,State,Reactions\nhttp://example.com/pr/1 ,\"Title, with comma\",open,3\n"
+	if single != wantSingle {
+		t.Errorf("Expected single-type output %q, got %q", wantSingle, single)
+	}
+	wantCombined := "Type,URL,Title,State,Reactions\nPull Request,http://example.com/pr/1 ,\"Title, with comma\",open,3\n"
+	if combined != wantCombined {
+		t.Errorf("Expected all output %q, got %q", wantCombined, combined)
+	}
+
+	normalizeURLs = true
+	compact, _ := captureOutput(func() {
+		writeRows([]column{typeColumn, urlColumn, titleColumn, reasonColumn}, []itemRow{{label: "Issue", reason: "Assigned", item: item}})
+	})
+	wantCompact := "Type,URL,Title,Reason\nIssue,http://example.com/pr/1,\"Title, with comma\",Assigned\n"
+	if compact != wantCompact {
+		t.Errorf("Expected compact output %q, got %q", wantCompact, compact)
+	}
+}
+
+func TestNormalizeURLs(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	items := []GitHubItem{{Title: "PR", HTMLURL: "http://example.com/pr/1", State: "open"}}
+
+	csvOut, _ := captureOutput(func() { printItems(items) })
+	if !strings.Contains(csvOut, "http://example.com/pr/1 ,") {
+		t.Errorf("Expected the trailing space by default, got %q", csvOut)
+	}
+	jsonOut, _ := captureOutput(func() { printItemsAsJSON(items) })
+	if !strings.Contains(jsonOut, `"html_url": "http://example.com/pr/1"`) {
+		t.Errorf("Expected clean URLs in JSON by default, got %q", jsonOut)
+	}
+
+	normalizeURLs = true
+	csvOut, _ = captureOutput(func() { printItems(items) })
+	if !strings.Contains(csvOut, "http://example.com/pr/1,PR,open") {
+		t.Errorf("Expected no trailing space with --normalize-urls, got %q", csvOut)
+	}
+}
+
+func TestExportPreset(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	exportPreset, outputFormat, normalizeURLs = true, "tsv", true
+	withReactions = true // Optional columns don't change the export schema
+	items := []GitHubItem{
+		{Number: 7, Title: "Fix flake", HTMLURL: "https://github.com/o/r/pull/7", State: "closed", CreatedAt: "2025-01-02T03:04:05Z", ClosedAt: "2025-01-03T00:00:00Z", PullRequest: &pullRequestRef{}},
+		{Number: 8, Title: "Bug", HTMLURL: "https://github.com/o/r/issues/8", State: "open", CreatedAt: "2025-01-04T00:00:00Z"},
+	}
+
+	stdout, _ := captureOutput(func() { printItems(items) })
+	want := "repo\tnumber\ttype\tstate\ttitle\turl\tcreated_at\tclosed_at\n" +
+		"o/r\t7\tPull Request\tclosed\tFix flake\thttps://github.com/o/r/pull/7\t2025-01-02T03:04:05Z\t2025-01-03T00:00:00Z\n" +
+		"o/r\t8\tIssue\topen\tBug\thttps://github.com/o/r/issues/8\t2025-01-04T00:00:00Z\t\n"
+	if stdout != want {
+		t.Errorf("Unexpected export output:\n got: %q\nwant: %q", stdout, want)
+	}
+
+	// Row labels (all, attention) take precedence over the item type
+	stdout, _ = captureOutput(func() {
+		writeRows(append([]column{typeColumn}, itemColumns()...), []itemRow{{label: "Review", item: items[0]}})
+	})
+	if !strings.Contains(stdout, "o/r\t7\tReview\t") {
+		t.Errorf("Expected the row label as the type, got %q", stdout)
+	}
+}
+
+func TestPrintItems_BOM(t *testing.T) {
+	resetFlags()
+	items := []GitHubItem{{Title: "Emoji 🎉 title", HTMLURL: "http://example.com/pr/1", State: "open"}}
+
+	stdout, _ := captureOutput(func() {
+		printItems(items)
+	})
+	if strings.HasPrefix(stdout, utf8BOM) {
+		t.Errorf("Expected no BOM by default, got: %q", stdout)
+	}
+
+	bomFlag = true
+	stdout, _ = captureOutput(func() {
+		printItems(items)
+	})
+	if !strings.HasPrefix(stdout, "\xef\xbb\xbfURL,Title,State\n") {
+		t.Errorf("Expected BOM bytes before the header, got: %q", stdout)
+	}
+	if !strings.Contains(stdout, "Emoji 🎉 title") {
+		t.Errorf("Expected title to be preserved, got: %q", stdout)
+	}
+}
+
+func TestPrintItems_AppendToOutput(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	path := filepath.Join(t.TempDir(), "report.csv")
+
+	write := func(appendMode bool, item GitHubItem) {
+		outputPath, appendOutput = path, appendMode
+		defer func() {
+			closeOutputFile()
+			outputFile, appendingToOutput = nil, false
+		}()
+		printItems([]GitHubItem{item})
+	}
+
+	// An empty file gets the header once, then appends skip it
+	write(true, GitHubItem{Title: "First", HTMLURL: "http://example.com/pr/1", State: "open"})
+	write(true, GitHubItem{Title: "Second", HTMLURL: "http://example.com/pr/2", State: "closed"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "URL,Title,State\nhttp://example.com/pr/1 ,First,open\nhttp://example.com/pr/2 ,Second,closed\n"
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, data)
+	}
+
+	// Without --append the file is overwritten
+	write(false, GitHubItem{Title: "Third", HTMLURL: "http://example.com/pr/3", State: "open"})
+	data, _ = os.ReadFile(path)
+	if expected := "URL,Title,State\nhttp://example.com/pr/3 ,Third,open\n"; string(data) != expected {
+		t.Errorf("Expected overwrite:\n%s\ngot:\n%s", expected, data)
+	}
+}
+
+func TestOutputFile_KeptWhenFetchFails(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	outputPath = filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(outputPath, []byte("previous report\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		return fmt.Errorf("HTTP 502: Bad Gateway")
+	}}
+
+	captureOutput(func() { handlePullsCommand([]string{"pulls", "testuser"}, mockClient) })
+	if data, _ := os.ReadFile(outputPath); string(data) != "previous report\n" {
+		t.Errorf("Expected a failed search to leave the previous report, got %q", data)
+	}
+	if outputFile != nil {
+		t.Error("Expected the output file not to be opened")
+	}
+}
+
+func TestHandlePullsCommand_SplitByRepo(t *testing.T) {
+	resetFlags()
+	splitByRepo = true
+	outputDir = filepath.Join(t.TempDir(), "reports")
+
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			resp := GitHubResponse{Items: []GitHubItem{
+				{Number: 1, Title: "One", HTMLURL: "https://github.com/octo/alpha/pull/1", State: "open"},
+				{Number: 2, Title: "Two", HTMLURL: "https://github.com/octo/beta/pull/2", State: "closed"},
+				{Number: 3, Title: "Three", HTMLURL: "https://github.com/octo/alpha/pull/3", State: "closed"},
+				{Number: 4, Title: "Odd", HTMLURL: "not a url", State: "open"},
+			}}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	if stdout != "" {
+		t.Errorf("Expected no stdout, got: %s", stdout)
+	}
+
+	expectedFiles := map[string]string{
+		"octo-alpha.csv": "URL,Title,State\nhttps://github.com/octo/alpha/pull/1 ,One,open\nhttps://github.com/octo/alpha/pull/3 ,Three,closed\n",
+		"octo-beta.csv":  "URL,Title,State\nhttps://github.com/octo/beta/pull/2 ,Two,closed\n",
+		"unknown.csv":    "URL,Title,State\nnot a url ,Odd,open\n",
+	}
+	for name, expected := range expectedFiles {
+		path := filepath.Join(outputDir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+			continue
+		}
+		if string(data) != expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", name, expected, data)
+		}
+		if !strings.Contains(stderr, "Wrote "+path) {
+			t.Errorf("Expected manifest line for %s, got stderr: %s", path, stderr)
+		}
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	dir := t.TempDir()
+	outputDir = filepath.Join(dir, "reports")
+
+	items := []GitHubItem{
+		{HTMLURL: "https://github.com/octo/alpha/pull/1"},
+		{HTMLURL: "https://github.com/octo/beta/pull/2"},
+		{HTMLURL: "https://github.com/octo/alpha/pull/3"},
+	}
+	captureOutput(func() {
+		writeSplitByRepo([]string{"URL"}, items, [][]string{{"1"}, {"2"}, {"3"}})
+	})
+	recordArtifact(filepath.Join(dir, "all.json"), "json", 3)
+
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := writeManifest(manifest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatalf("Expected the manifest to be written: %v", err)
+	}
+	var got struct {
+		Files []manifestEntry `json:"files"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Manifest is not valid JSON: %v\n%s", err, data)
+	}
+	expected := []manifestEntry{
+		{Path: filepath.Join(outputDir, "octo-alpha.csv"), Type: "csv", Items: 2},
+		{Path: filepath.Join(outputDir, "octo-beta.csv"), Type: "csv", Items: 1},
+		{Path: filepath.Join(dir, "all.json"), Type: "json", Items: 3},
+	}
+	if fmt.Sprint(got.Files) != fmt.Sprint(expected) {
+		t.Errorf("Expected manifest files %v, got %v", expected, got.Files)
+	}
+
+	// A run that wrote nothing still gets a manifest with an empty list
+	manifestEntries = nil
+	if err := writeManifest(manifest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(manifest); string(data) != "{\n  \"files\": []\n}\n" {
+		t.Errorf("Expected an empty file list, got %s", data)
+	}
+}
+
+func TestWriteRows_CountsOutputItems(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	captureOutput(func() {
+		printItems([]GitHubItem{{HTMLURL: "https://github.com/o/r/pull/1"}, {HTMLURL: "https://github.com/o/r/pull/2"}})
+	})
+	if outputItemCount != 2 {
+		t.Errorf("Expected 2 items counted for the --output manifest entry, got %d", outputItemCount)
+	}
+}
+
+func TestRecordOutputArtifact(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	outputPath = filepath.Join(t.TempDir(), "report.json")
+
+	// Nothing written: no entry, even though --output was set
+	recordOutputArtifact()
+	if len(manifestEntries) != 0 {
+		t.Errorf("Expected no entry for an output file that was never written, got %v", manifestEntries)
+	}
+
+	outputFormat = "json"
+	printItemsAsJSON([]GitHubItem{{HTMLURL: "https://github.com/o/r/pull/1"}})
+	recordOutputArtifact()
+	expected := []manifestEntry{{Path: outputPath, Type: "json", Items: 1}}
+	if fmt.Sprint(manifestEntries) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, manifestEntries)
+	}
+
+	// The type follows what was written, not --format
+	closeOutputFile()
+	outputFile, manifestEntries, outputItemCount = nil, nil, 0
+	outputPath = filepath.Join(t.TempDir(), "metrics.prom")
+	mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		return json.Unmarshal([]byte(`{"total_count":0,"items":[]}`), response)
+	}}
+	outputFormat, prometheusFlag = "csv", true
+	captureOutput(func() {
+		handleGraphCommand([]string{"graph", "testuser"}, mockClient, &MockGraphQLClient{})
+	})
+	recordOutputArtifact()
+	if len(manifestEntries) != 1 || manifestEntries[0].Type != "prometheus" {
+		t.Errorf("Expected a prometheus entry, got %v", manifestEntries)
+	}
+}
+
+func TestPrintPullRequests_TableFormat(t *testing.T) {
+	originalWidthFunc := terminalWidthFunc
+	defer func() { terminalWidthFunc = originalWidthFunc }()
+
+	items := []GitHubItem{
+		{Title: "A fairly long pull request title that will not fit", HTMLURL: "https://github.com/o/r/pull/1", State: "open"},
+		{Title: "Short", HTMLURL: "https://github.com/o/r/pull/22", State: "closed"},
+	}
+
+	t.Run("boxed and truncated to terminal width", func(t *testing.T) {
+		resetFlags()
+		outputFormat = "table"
+		terminalWidthFunc = func() int { return 70 }
+
+		stdout, _ := captureOutput(func() {
+			printItems(items)
+		})
+
+		lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+		if len(lines) != 6 {
+			t.Fatalf("Expected 6 lines (3 borders, header, 2 rows), got %d:\n%s", len(lines), stdout)
+		}
+		if !strings.HasPrefix(lines[0], "┌") || !strings.HasPrefix(lines[2], "├") || !strings.HasPrefix(lines[5], "└") {
+			t.Errorf("Expected box-drawing borders, got:\n%s", stdout)
+		}
+		if !strings.Contains(lines[1], "│ URL ") || !strings.Contains(lines[1], "│ Title ") {
+			t.Errorf("Expected header row, got: %s", lines[1])
+		}
+		for _, line := range lines {
+			if w := text.DisplayWidth(line); w > 70 {
+				t.Errorf("Expected line to fit in 70 columns, got %d: %s", w, line)
+			}
+		}
+		if !strings.Contains(stdout, "...") || strings.Contains(stdout, "will not fit") {
+			t.Errorf("Expected long title to be truncated, got:\n%s", stdout)
+		}
+	})
+
+	t.Run("aligned fallback when width is unknown", func(t *testing.T) {
+		resetFlags()
+		outputFormat = "table"
+		terminalWidthFunc = func() int { return 0 }
+
+		stdout, _ := captureOutput(func() {
+			printItems(items)
+		})
+
+		expected := "URL                             Title                                               State\n" +
+			"https://github.com/o/r/pull/1   A fairly long pull request title that will not fit  open\n" +
+			"https://github.com/o/r/pull/22  Short                                               closed\n"
+		if stdout != expected {
+			t.Errorf("Expected aligned output:\n%s\ngot:\n%s", expected, stdout)
+		}
+	})
+}
+
+func TestHandlePullsCommand_Raw(t *testing.T) {
+	resetFlags()
+	rawOutput = true
+	rawOnce = sync.Once{}
+
+	exitCode := -1
+	originalExitFunc := exitFunc
+	exitFunc = func(code int) { exitCode = code }
+	defer func() { exitFunc = originalExitFunc }()
+
+	rawBody := `{"total_count":1,"items":[{"number":1,"repository_url":"https://api.github.com/repos/octo/repo"}]}`
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			return fmt.Errorf("expected no parsed API call, got: %s", path)
+		},
+		GetWithResponseFunc: func(path string) (*http.Response, error) {
+			if !strings.Contains(path, "page=1") {
+				return nil, fmt.Errorf("expected first page, got: %s", path)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(rawBody))}, nil
+		},
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	if !strings.HasPrefix(stdout, rawBody+"\n") {
+		t.Errorf("Expected raw body on stdout, got: %s", stdout)
+	}
+	if exitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", exitCode)
+	}
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+}
+
+func TestHandleIssuesCommand_CSV(t *testing.T) {
+	resetFlags()
+	mockClient := &MockGitHubClient{}
+	testLogin := "testuser"
+	testArgs := []string{"issues", testLogin}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		// Match percent-encoded Issue search URL
+		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Aissue") && strings.Contains(path, "author%3Atestuser") && strings.Contains(path, "page=1") {
+			resp := GitHubResponse{
+				TotalCount: 1,
+				Items: []GitHubItem{
+					{Number: 456, Title: "Test Issue", HTMLURL: "http://example.com/issue/456", State: "closed", Repository: struct {
+						Name string `json:"name"`
+					}{"another-repo"}},
+				},
+			}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		}
+		return fmt.Errorf("unexpected API call: %s", path)
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleIssuesCommand(testArgs, mockClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+
+	expectedHeader := "URL,Title,State"
+	expectedRow := "http://example.com/issue/456 ,Test Issue,closed"
+
+	if !strings.Contains(stdout, expectedHeader) {
+		t.Errorf("Expected stdout to contain header '%s', got: %s", expectedHeader, stdout)
+	}
+	if !strings.Contains(stdout, expectedRow) {
+		t.Errorf("Expected stdout to contain row '%s', got: %s", expectedRow, stdout)
+	}
+	if len(mockClient.GetCalls) != 1 {
+		t.Errorf("Expected 1 API call, got %d", len(mockClient.GetCalls))
+	}
+}
+
+func TestHandleIssuesCommand_Association(t *testing.T) {
+	resetFlags()
+	associationFlag = "contributor,first_time_contributor"
+	mockClient := &MockGitHubClient{}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		resp := GitHubResponse{
+			TotalCount: 3,
+			Items: []GitHubItem{
+				{Number: 1, Title: "From maintainer", HTMLURL: "http://example.com/issue/1", State: "open", AuthorAssociation: "MEMBER"},
+				{Number: 2, Title: "From contributor", HTMLURL: "http://example.com/issue/2", State: "open", AuthorAssociation: "CONTRIBUTOR"},
+				{Number: 3, Title: "From newcomer", HTMLURL: "http://example.com/issue/3", State: "closed", AuthorAssociation: "FIRST_TIME_CONTRIBUTOR"},
+			},
+		}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleIssuesCommand([]string{"issues", "testuser"}, mockClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+
+	expectedRows := []string{
+		"URL,Title,State,Association",
+		"http://example.com/issue/2 ,From contributor,open,CONTRIBUTOR",
+		"http://example.com/issue/3 ,From newcomer,closed,FIRST_TIME_CONTRIBUTOR",
+	}
+	for _, row := range expectedRows {
+		if !strings.Contains(stdout, row) {
+			t.Errorf("Expected stdout to contain '%s', got: %s", row, stdout)
+		}
+	}
+	if strings.Contains(stdout, "From maintainer") {
+		t.Errorf("Expected MEMBER item to be filtered out, got: %s", stdout)
+	}
+}
+
+func TestFetchTopicRepos(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	page := func(n int) []map[string]string {
+		items := make([]map[string]string, n)
+		for i := range items {
+			items[i] = map[string]string{"full_name": fmt.Sprintf("octo/Repo-%d", len(items)-i)}
+		}
+		return items
+	}
+	mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		if !strings.HasPrefix(path, "search/repositories?q=org%3Aocto+topic%3Akubernetes") {
+			return fmt.Errorf("unexpected API call: %s", path)
+		}
+		items := page(100)
+		if strings.Contains(path, "page=2") {
+			items = []map[string]string{{"full_name": "octo/Last"}}
+		}
+		data, _ := json.Marshal(map[string]interface{}{"items": items})
+		return json.Unmarshal(data, response)
+	}}
+
+	repos, err := fetchTopicRepos(mockClient, "octo", "kubernetes")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(mockClient.GetCalls) != 2 {
+		t.Errorf("Expected to stop after the short second page, got %v", mockClient.GetCalls)
+	}
+	if len(repos) != 101 || !repos["octo/last"] || !repos["octo/repo-1"] {
+		t.Errorf("Expected 101 lowercased repos, got %d", len(repos))
+	}
+
+	// Items outside the topic's repos are filtered out, discussions included
+	topicRepos = repos
+	items := []GitHubItem{
+		{Number: 1, HTMLURL: "https://github.com/octo/Last/pull/1", AuthorAssociation: "MEMBER"},
+		{Number: 2, HTMLURL: "https://github.com/octo/other/issues/2", AuthorAssociation: "MEMBER"},
+		{Number: 3, HTMLURL: "https://github.com/octo/repo-7/discussions/3", AuthorAssociation: "CONTRIBUTOR"},
+	}
+	if got := filterItems(items); len(got) != 2 || got[0].Number != 1 || got[1].Number != 3 {
+		t.Errorf("Expected items 1 and 3, got %+v", got)
+	}
+
+	// --association still narrows the topic's repos when both are set
+	associationFlag = "member"
+	if got := filterItems(items); len(got) != 1 || got[0].Number != 1 {
+		t.Errorf("Expected only item 1, got %+v", got)
+	}
+}
+
+func TestFilterItems_Regex(t *testing.T) {
+	items := []GitHubItem{
+		{Title: "Fix flaky test", Body: "Retries the network call"},
+		{Title: "Add feature", Body: "No retry needed"},
+		{Title: "Docs update", Body: "Typos"},
+	}
+
+	resetFlags()
+	filterPattern = regexp.MustCompile(`(?i)^fix|feature`)
+	got := filterItems(items)
+	if len(got) != 2 || got[0].Title != "Fix flaky test" || got[1].Title != "Add feature" {
+		t.Errorf("Expected title matches in order, got %+v", got)
+	}
+
+	filterBody = true
+	filterPattern = regexp.MustCompile(`(?i)retr`)
+	got = filterItems(items)
+	if len(got) != 2 || got[0].Title != "Fix flaky test" || got[1].Title != "Add feature" {
+		t.Errorf("Expected body matches with --filter-body, got %+v", got)
+	}
+
+	resetFlags()
+	if got := filterItems(items); len(got) != 3 {
+		t.Errorf("Expected all items without --filter, got %d", len(got))
+	}
+}
+
+func TestHandlePullsCommand_FilterWithoutAssociation(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	filterPattern = regexp.MustCompile(`(?i)^fix`)
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			return json.Unmarshal([]byte(`{"total_count":2,"items":[
+				{"number":1,"title":"Fix login","html_url":"http://example.com/pr/1","state":"open","author_association":"MEMBER"},
+				{"number":2,"title":"Add metrics","html_url":"http://example.com/pr/2","state":"open","author_association":"CONTRIBUTOR"}
+			]}`), response)
+		},
+	}
+
+	stdout, _ := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	expected := "URL,Title,State\nhttp://example.com/pr/1 ,Fix login,open\n"
+	if stdout != expected {
+		t.Errorf("Expected --filter alone to keep matches of any association:\n%s\nGot:\n%s", expected, stdout)
+	}
+}
+
+func TestDedupeItems(t *testing.T) {
+	items := []GitHubItem{
+		{Title: "Fix login timeout", HTMLURL: "u1", CreatedAt: "2025-01-10T00:00:00Z"},
+		{Title: "Add metrics", HTMLURL: "u2", CreatedAt: "2025-01-09T00:00:00Z"},
+		{Title: "[backport] Fix login  timeout (#12)", HTMLURL: "u3", CreatedAt: "2025-01-12T00:00:00Z"},
+		{Title: "fix login timeout", HTMLURL: "u4", CreatedAt: "2025-01-08T00:00:00Z"},
+	}
+
+	resetFlags()
+	defer resetFlags()
+	if got := dedupeItems(items); len(got) != 4 {
+		t.Errorf("Expected no deduping without --dedupe-by-title, got %d items", len(got))
+	}
+
+	dedupeByTitle = true
+	got := dedupeItems(items)
+	if len(got) != 3 || got[0].HTMLURL != "u1" || got[0].Duplicates != 1 {
+		t.Errorf("Expected exact-normalized titles collapsed onto u1, got %+v", got)
+	}
+
+	dedupeStripPattern = regexp.MustCompile(`(?i)\[backport\]|\(#\d+\)`)
+	got = dedupeItems(items)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 items with --dedupe-strip, got %+v", got)
+	}
+	if got[0].HTMLURL != "u3" || got[0].Duplicates != 2 {
+		t.Errorf("Expected most recent u3 with 2 duplicates first, got %+v", got[0])
+	}
+	if got[1].HTMLURL != "u2" || got[1].Duplicates != 0 {
+		t.Errorf("Expected u2 unchanged, got %+v", got[1])
+	}
+}
+
+func TestValidateAssociations(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"MEMBER", false},
+		{"owner, member", false},
+		{"FIRST_TIME_CONTRIBUTOR,NONE", false},
+		{"STRANGER", true},
+		{"MEMBER,", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if err := validateAssociations(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("validateAssociations(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleAllCommand_OnlyIssues(t *testing.T) {
+	resetFlags()
+	onlyIssues = true
+	mockClient := &MockGitHubClient{}
+	mockGQLClient := &MockGraphQLClient{}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		if !strings.Contains(path, "is%3Aissue") {
+			return fmt.Errorf("unexpected API call: %s", path)
+		}
+		items := []GitHubItem{
+			{Number: 456, Title: "Test Issue", HTMLURL: "http://example.com/issue/456", State: "closed"},
+			{Number: 3, Title: "Converted PR", HTMLURL: "http://example.com/pull/3", State: "closed"},
+		}
+		resp := GitHubResponse{TotalCount: len(items), Items: items}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+	mockGQLClient.DoFunc = func(query string, variables map[string]interface{}, response interface{}) error {
+		t.Errorf("Expected no discussion search with --only-issues")
+		return nil
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleAllCommand([]string{"all", "testuser"}, mockClient, mockGQLClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+	if len(mockClient.GetCalls) != 1 {
+		t.Errorf("Expected only the issue search, got %d calls: %v", len(mockClient.GetCalls), mockClient.GetCalls)
+	}
+	if !strings.Contains(stdout, "Type,URL,Title,State") || !strings.Contains(stdout, "Issue,http://example.com/issue/456 ,Test Issue,closed") {
+		t.Errorf("Expected the all format with the issue row, got: %s", stdout)
+	}
+	if strings.Contains(stdout, "Converted PR") {
+		t.Errorf("Expected pull requests from the issue search to be dropped, got: %s", stdout)
+	}
+}
+
+func TestAllCommandKinds(t *testing.T) {
+	resetFlags()
+	if allCommandKinds() != allContributionKinds {
+		t.Errorf("Expected all kinds by default, got %+v", allCommandKinds())
+	}
+	onlyPRs = true
+	if got := allCommandKinds(); got != (contributionKinds{prs: true}) {
+		t.Errorf("Expected only PRs, got %+v", got)
+	}
+}
+
+func TestHandleAllCommand_CSV(t *testing.T) {
+	resetFlags()
+	mockClient := &MockGitHubClient{}
+	mockGQLClient := &MockGraphQLClient{}
+	testLogin := "testuser"
+	testArgs := []string{"all", testLogin}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		var items []GitHubItem
+		// Match percent-encoded PR URL
+		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3Atestuser") && strings.Contains(path, "page=1") {
+			items = []GitHubItem{
+				{Number: 123, Title: "Test PR", HTMLURL: "http://example.com/pr/123", State: "open", Repository: struct {
+					Name string `json:"name"`
+				}{"test-repo"}},
+			}
+		} else if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "reviewed-by%3Atestuser") && strings.Contains(path, "page=1") {
+			items = []GitHubItem{
+				{Number: 789, Title: "Reviewed PR", HTMLURL: "http://example.com/pr/789", State: "closed", Repository: struct {
+					Name string `json:"name"`
+				}{"review-repo"}},
+			}
+		} else if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Aissue") && strings.Contains(path, "author%3Atestuser") && strings.Contains(path, "page=1") {
+			items = []GitHubItem{
+				{Number: 456, Title: "Test Issue", HTMLURL: "http://example.com/issue/456", State: "closed", Repository: struct {
+					Name string `json:"name"`
+				}{"another-repo"}},
+			}
+		} else {
+			return fmt.Errorf("unexpected API call: %s", path)
+		}
+
+		resp := GitHubResponse{TotalCount: len(items), Items: items}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	// Mock GraphQL client returns one discussion
+	mockGQLClient.DoFunc = func(query string, variables map[string]interface{}, response interface{}) error {
+		resp := response.(*DiscussionSearchResponse)
+		resp.Search.Nodes = []discussionNode{
+			{Title: "Test Discussion", URL: "http://example.com/discussion/1", Number: 1, Closed: false, CreatedAt: "2025-01-20T00:00:00Z"},
+		}
+		resp.Search.PageInfo.HasNextPage = false
+		return nil
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleAllCommand(testArgs, mockClient, mockGQLClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+
+	expectedHeader := "Type,URL,Title,State"
+	expectedPRRow := "Pull Request,http://example.com/pr/123 ,Test PR,open"
+	expectedReviewRow := "Review,http://example.com/pr/789 ,Reviewed PR,closed"
+	expectedIssueRow := "Issue,http://example.com/issue/456 ,Test Issue,closed"
+	expectedDiscussionRow := "Discussion,http://example.com/discussion/1 ,Test Discussion,open"
+
+	if !strings.Contains(stdout, expectedHeader) {
+		t.Errorf("Expected stdout to contain header '%s', got: %s", expectedHeader, stdout)
+	}
+	if !strings.Contains(stdout, expectedPRRow) {
+		t.Errorf("Expected stdout to contain PR row '%s', got: %s", expectedPRRow, stdout)
+	}
+	if !strings.Contains(stdout, expectedReviewRow) {
+		t.Errorf("Expected stdout to contain Review row '%s', got: %s", expectedReviewRow, stdout)
+	}
+	if !strings.Contains(stdout, expectedIssueRow) {
+		t.Errorf("Expected stdout to contain Issue row '%s', got: %s", expectedIssueRow, stdout)
+	}
+	if !strings.Contains(stdout, expectedDiscussionRow) {
+		t.Errorf("Expected stdout to contain Discussion row '%s', got: %s", expectedDiscussionRow, stdout)
+	}
+	if len(mockClient.GetCalls) != 3 { // One for PRs, one for Reviews, one for Issues
+		t.Errorf("Expected 3 API calls, got %d", len(mockClient.GetCalls))
+	}
+}
+
+func TestContributorTypes(t *testing.T) {
+	originalConfigFunc := contributorTypesConfigFunc
+	defer func() { contributorTypesConfigFunc = originalConfigFunc }()
+	contributorTypesConfigFunc = func() map[string]string { return nil }
+
+	results := &contributionResults{
+		prItems:     []GitHubItem{{Title: "Update guide", Labels: []itemLabel{{Name: "Documentation"}}}},
+		reviewItems: []GitHubItem{{Title: "Someone's docs", Labels: []itemLabel{{Name: "design"}}}},
+		issueItems:  []GitHubItem{{Title: "Crash on start"}},
+	}
+
+	if got := strings.Join(contributorTypes(results), ","); got != "bug,code,doc,review" {
+		t.Errorf("Expected default mapping types, got %s", got)
+	}
+
+	contributorTypesConfigFunc = func() map[string]string { return map[string]string{"Design": "design"} }
+	if got := strings.Join(contributorTypes(results), ","); got != "bug,code,review" {
+		t.Errorf("Expected configured mapping to replace defaults and skip review labels, got %s", got)
+	}
+
+	if got := contributorTypes(&contributionResults{}); len(got) != 0 {
+		t.Errorf("Expected no types without contributions, got %v", got)
+	}
+}
+
+func TestHandleAttentionCommand_CSV(t *testing.T) {
+	resetFlags()
+	mockClient := &MockGitHubClient{}
+	testArgs := []string{"attention", "testuser"}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		var items []GitHubItem
+		if !strings.Contains(path, "is%3Aopen") {
+			return fmt.Errorf("expected open-only query, got: %s", path)
+		}
+		if strings.Contains(path, "created%3A") {
+			return fmt.Errorf("expected no since qualifier, got: %s", path)
+		}
+		if strings.Contains(path, "review%3Achanges_requested") {
+			items = []GitHubItem{
+				{Number: 1, Title: "Needs changes", HTMLURL: "http://example.com/pr/1", State: "open"},
+			}
+		} else if strings.Contains(path, "comments%3A%3E0") {
+			items = []GitHubItem{
+				{Number: 1, Title: "Needs changes", HTMLURL: "http://example.com/pr/1", State: "open"},
+				{Number: 2, Title: "Has feedback", HTMLURL: "http://example.com/pr/2", State: "open"},
+			}
+		} else if strings.Contains(path, "assignee%3Atestuser") {
+			items = []GitHubItem{
+				{Number: 3, Title: "Assigned issue", HTMLURL: "http://example.com/issue/3", State: "open"},
+			}
+		} else {
+			return fmt.Errorf("unexpected API call: %s", path)
+		}
+
+		resp := GitHubResponse{TotalCount: len(items), Items: items}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleAttentionCommand(testArgs, mockClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+
+	expectedRows := []string{
+		"Type,URL,Title,Reason",
+		"Pull Request,http://example.com/pr/1 ,Needs changes,changes requested",
+		"Pull Request,http://example.com/pr/2 ,Has feedback,has comments",
+		"Issue,http://example.com/issue/3 ,Assigned issue,assigned",
+	}
+	for _, row := range expectedRows {
+		if !strings.Contains(stdout, row) {
+			t.Errorf("Expected stdout to contain '%s', got: %s", row, stdout)
+		}
+	}
+	if strings.Count(stdout, "http://example.com/pr/1 ") != 1 {
+		t.Errorf("Expected PR 1 to be listed once, got: %s", stdout)
+	}
+	if len(mockClient.GetCalls) != 3 {
+		t.Errorf("Expected 3 API calls, got %d", len(mockClient.GetCalls))
+	}
+}
+
+func TestHandleFootprintCommand(t *testing.T) {
+	mockClient := &MockGitHubClient{}
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		var items []GitHubItem
+		if strings.Contains(path, "is%3Apr") {
+			items = []GitHubItem{
+				{Number: 1, HTMLURL: "https://github.com/octo/alpha/pull/1", State: "open"},
+				{Number: 2, HTMLURL: "https://github.com/octo/beta/pull/2", State: "closed"},
+				{Number: 3, HTMLURL: "https://github.com/octo/beta/pull/3", State: "closed"},
+			}
+		} else if strings.Contains(path, "is%3Aissue") {
+			items = []GitHubItem{
+				{Number: 4, HTMLURL: "https://github.com/octo/alpha/issues/4", State: "open"},
+				{Number: 5, HTMLURL: "https://github.com/octo/gamma/issues/5", State: "open"},
+				{Number: 6, HTMLURL: "https://github.com/octo/beta/issues/6", State: "open"},
+			}
+		} else {
+			return fmt.Errorf("unexpected API call: %s", path)
+		}
+		resp := GitHubResponse{TotalCount: len(items), Items: items}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		resetFlags()
+		stdout, stderr := captureOutput(func() {
+			handleFootprintCommand([]string{"footprint", "testuser"}, mockClient)
+		})
+
+		if stderr != "" {
+			t.Errorf("Expected no stderr, got: %s", stderr)
+		}
+		expected := "Repository,PRs,Issues,Total\n" +
+			"octo/beta,2,1,3\n" +
+			"octo/alpha,1,1,2\n" +
+			"octo/gamma,0,1,1\n"
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		resetFlags()
+		outputFormat = "json"
+		stdout, _ := captureOutput(func() {
+			handleFootprintCommand([]string{"footprint", "testuser"}, mockClient)
+		})
+
+		var footprint []repoFootprint
+		if err := json.Unmarshal([]byte(stdout), &footprint); err != nil {
+			t.Fatalf("Expected valid JSON, got error %v for: %s", err, stdout)
+		}
+		if len(footprint) != 3 || footprint[0] != (repoFootprint{Repository: "octo/beta", PRs: 2, Issues: 1, Total: 3}) {
+			t.Errorf("Unexpected footprint: %+v", footprint)
+		}
+	})
+
+	t.Run("TopRepos", func(t *testing.T) {
+		resetFlags()
+		topRepos = 1
+		stdout, _ := captureOutput(func() {
+			handleFootprintCommand([]string{"footprint", "testuser"}, mockClient)
+		})
+
+		expected := "Repository,PRs,Issues,Total\n" +
+			"octo/beta,2,1,3\n" +
+			"others (2 repos),1,2,3\n"
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+	})
+}
+
+func TestHandleRepoActivityCommand(t *testing.T) {
+	mockClient := &MockGitHubClient{}
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		var items []GitHubItem
+		switch {
+		case strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3Aalice"):
+			items = []GitHubItem{
+				{Number: 1, HTMLURL: "https://github.com/octo/alpha/pull/1"},
+				{Number: 2, HTMLURL: "https://github.com/octo/beta/pull/2"},
+			}
+		case strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3Abob"):
+			items = []GitHubItem{
+				{Number: 3, HTMLURL: "https://github.com/octo/beta/pull/3"},
+				{Number: 4, HTMLURL: "https://github.com/octo/gamma/pull/4"},
+			}
+		case strings.Contains(path, "is%3Aissue") && strings.Contains(path, "author%3Abob"):
+			items = []GitHubItem{{Number: 5, HTMLURL: "https://github.com/octo/beta/issues/5"}}
+		case strings.Contains(path, "is%3Aissue"):
+		default:
+			return fmt.Errorf("unexpected API call: %s", path)
+		}
+		resp := GitHubResponse{TotalCount: len(items), Items: items}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		resetFlags()
+		stdout, _ := captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "bob"}, mockClient, &MockGraphQLClient{})
+		})
+
+		expected := "User,octo/beta,octo/alpha,octo/gamma,Total\n" +
+			"alice,1,1,0,2\n" +
+			"bob,2,0,1,3\n"
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+	})
+
+	t.Run("TopRepos", func(t *testing.T) {
+		resetFlags()
+		topRepos = 1
+		stdout, _ := captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "bob"}, mockClient, &MockGraphQLClient{})
+		})
+
+		expected := "User,octo/beta,others (2 repos),Total\n" +
+			"alice,1,1,2\n" +
+			"bob,2,1,3\n"
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+	})
+
+	t.Run("Score", func(t *testing.T) {
+		resetFlags()
+		defer resetFlags()
+		originalConfigFunc := scoreWeightsConfigFunc
+		defer func() { scoreWeightsConfigFunc = originalConfigFunc }()
+		scoreWeightsConfigFunc = func() map[string]float64 { return nil }
+		scoreFlag = true
+		scoreClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+			if strings.HasPrefix(path, "users/") {
+				return json.Unmarshal([]byte(`{"company":"@octo"}`), response)
+			}
+			var items []GitHubItem
+			switch {
+			case strings.Contains(path, "reviewed-by%3Aalice"):
+				items = []GitHubItem{{Number: 9, HTMLURL: "https://github.com/octo/gamma/pull/9", State: "closed"}}
+			case strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3Aalice"):
+				items = []GitHubItem{{Number: 1, HTMLURL: "https://github.com/octo/alpha/pull/1", State: "closed", PullRequest: &pullRequestRef{MergedAt: "2025-04-20T10:00:00Z"}}}
+			case strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3Abob"):
+				items = []GitHubItem{{Number: 2, HTMLURL: "https://github.com/octo/alpha/pull/2", State: "open", PullRequest: &pullRequestRef{}}}
+			case strings.Contains(path, "is%3Aissue") && strings.Contains(path, "author%3Abob"):
+				items = []GitHubItem{{Number: 3, HTMLURL: "https://github.com/octo/beta/issues/3", State: "closed"}}
+			}
+			data, _ := json.Marshal(GitHubResponse{TotalCount: len(items), Items: items})
+			return json.Unmarshal(data, response)
+		}}
+		run := func() (string, string) {
+			return captureOutput(func() {
+				handleRepoActivityCommand([]string{"repo-activity", "bob", "alice"}, scoreClient, &MockGraphQLClient{})
+			})
+		}
+
+		// alice: 3 for the merged PR + 2 for the review; bob: 0.5 for the open PR + 1 for the closed issue.
+		// Rows are ranked by score, so alice comes first despite her lower total
+		stdout, stderr := run()
+		expected := "User,octo/alpha,octo/beta,Total,Score\n" +
+			"alice,1,0,1,5\n" +
+			"bob,1,1,2,1.5\n"
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+		if !strings.Contains(stderr, "Score = 3 × merged_pr") {
+			t.Errorf("Expected the formula on stderr, got %q", stderr)
+		}
+
+		quiet = true
+		if _, stderr := run(); !strings.Contains(stderr, "Score = 3 × merged_pr") {
+			t.Errorf("Expected the formula to stay visible with --quiet, got %q", stderr)
+		}
+
+		quiet = false
+		outputFormat = "json"
+		stdout, _ = run()
+		if !strings.Contains(stdout, `"login": "alice",`+"\n"+`    "score": 5,`) || !strings.Contains(stdout, `"score": 1.5`) {
+			t.Errorf("Expected each user's score in JSON, got:\n%s", stdout)
+		}
+
+		authorCompany = true
+		stdout, _ = run()
+		if !strings.Contains(stdout, `"score": 6.5`) {
+			t.Errorf("Expected the company's summed score in JSON, got:\n%s", stdout)
+		}
+	})
+
+	t.Run("OrgMembersOnly", func(t *testing.T) {
+		resetFlags()
+		orgMembersOnly = true
+		orgFlag = "octo"
+		defer func() { orgFlag = "" }()
+		membersClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+			switch path {
+			case "orgs/octo/members?per_page=100&page=1":
+				return json.Unmarshal([]byte(`[{"login":"Alice"}]`), response)
+			case "user/memberships/orgs/octo":
+				return json.Unmarshal([]byte(`{"state":"active"}`), response)
+			}
+			return mockClient.GetFunc(path, response)
+		}}
+
+		stdout, stderr := captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "bob"}, membersClient, &MockGraphQLClient{})
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "bob"}, membersClient, &MockGraphQLClient{})
+		})
+
+		expected := "User,octo/alpha,octo/beta,Total\nalice,1,1,2\n"
+		if stdout != expected+expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected+expected, stdout)
+		}
+		if !strings.Contains(stderr, "Skipping non-members of 'octo': bob") {
+			t.Errorf("Expected the skipped login to be reported, got stderr: %q", stderr)
+		}
+		memberListings := 0
+		for _, call := range membersClient.GetCalls {
+			if strings.HasPrefix(call, "orgs/octo/members") {
+				memberListings++
+			}
+		}
+		if memberListings != 1 {
+			t.Errorf("Expected the member list to be fetched once per run, got %d", memberListings)
+		}
+
+		quiet = true
+		_, stderr = captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "bob"}, membersClient, &MockGraphQLClient{})
+		})
+		quiet = false
+		if strings.Contains(stderr, "Skipping non-members") {
+			t.Errorf("Expected --quiet to hide the skipped logins, got stderr: %q", stderr)
+		}
+	})
+
+	t.Run("OrgMembersOnly_Fallbacks", func(t *testing.T) {
+		resetFlags()
+		publicOnly := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+			if path == "orgs/octo/members?per_page=100&page=1" {
+				return json.Unmarshal([]byte(`[{"login":"alice"}]`), response)
+			}
+			return errors.New("HTTP 404: Not Found")
+		}}
+		var kept []string
+		_, stderr := captureOutput(func() {
+			kept = filterOrgMembers(publicOnly, "octo", []string{"alice", "bob"})
+		})
+		if strings.Join(kept, ",") != "alice" || !strings.Contains(stderr, "can't read private membership of 'octo'") {
+			t.Errorf("Expected public members only with a warning, got %q and stderr %q", kept, stderr)
+		}
+
+		resetFlags()
+		forbidden := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+			return errors.New("HTTP 403: Forbidden")
+		}}
+		_, stderr = captureOutput(func() {
+			kept = filterOrgMembers(forbidden, "octo", []string{"alice", "bob"})
+		})
+		if strings.Join(kept, ",") != "alice,bob" || !strings.Contains(stderr, "--org-members-only is ignored") {
+			t.Errorf("Expected every login kept with a warning, got %q and stderr %q", kept, stderr)
+		}
+	})
+
+	t.Run("AuthorCompany", func(t *testing.T) {
+		resetFlags()
+		authorCompany = true
+		profiles := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+			switch path {
+			case "users/alice":
+				return json.Unmarshal([]byte(`{"company": " @GitHub "}`), response)
+			case "users/bob":
+				return json.Unmarshal([]byte(`{"company": null}`), response)
+			}
+			return mockClient.GetFunc(path, response)
+		}}
+		stdout, _ := captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "bob"}, profiles, &MockGraphQLClient{})
+		})
+
+		expected := "Company,Users,octo/beta,octo/alpha,octo/gamma,Total\n" +
+			"Independent,bob,2,0,1,3\n" +
+			"GitHub,alice,1,1,0,2\n"
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+
+		// Each login is looked up once, and failures are labeled rather than dropped
+		profiles.GetCalls = nil
+		companies := fetchCompanies(profiles, []string{"alice", "alice", "bob", "ghost"})
+		if len(profiles.GetCalls) != 3 {
+			t.Errorf("Expected one profile lookup per distinct login, got %v", profiles.GetCalls)
+		}
+		if companies["alice"] != "GitHub" || companies["bob"] != independentCompany || companies["ghost"] != unknownCompany {
+			t.Errorf("Unexpected companies: %v", companies)
+		}
+	})
+
+	t.Run("AuthorEmail", func(t *testing.T) {
+		resetFlags()
+		authorEmail = true
+		profiles := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+			switch path {
+			case "users/alice":
+				return json.Unmarshal([]byte(`{"email": "alice@example.com"}`), response)
+			case "users/bob":
+				return json.Unmarshal([]byte(`{"email": null}`), response)
+			}
+			return mockClient.GetFunc(path, response)
+		}}
+		stdout, stderr := captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "bob"}, profiles, &MockGraphQLClient{})
+		})
+
+		// A hidden email leaves the cell blank
+		expected := "User,Email,octo/beta,octo/alpha,octo/gamma,Total\n" +
+			"alice,alice@example.com,1,1,0,2\n" +
+			"bob,,2,0,1,3\n"
+		if stdout != expected {
+			t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+		}
+		if stderr != "" {
+			t.Errorf("Expected no stderr, got: %s", stderr)
+		}
+	})
+
+	failing := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		if strings.Contains(path, "author%3Abroken") {
+			return fmt.Errorf("boom")
+		}
+		return mockClient.GetFunc(path, response)
+	}}
+
+	t.Run("ContinueByDefault", func(t *testing.T) {
+		resetFlags()
+		exitCode := -1
+		originalExitFunc := exitFunc
+		exitFunc = func(code int) { exitCode = code }
+		defer func() { exitFunc = originalExitFunc }()
+
+		stdout, stderr := captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "broken", "bob"}, failing, &MockGraphQLClient{})
+		})
+
+		if !strings.Contains(stdout, "alice,") || !strings.Contains(stdout, "bob,") || strings.Contains(stdout, "broken") {
+			t.Errorf("Expected rows for the other users only, got:\n%s", stdout)
+		}
+		if !strings.Contains(stderr, "Failed logins (1 of 3): broken") {
+			t.Errorf("Expected a failure summary, got: %s", stderr)
+		}
+		if exitCode != 1 {
+			t.Errorf("Expected exit code 1, got %d", exitCode)
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		resetFlags()
+		failFast = true
+		exitCode := -1
+		originalExitFunc := exitFunc
+		exitFunc = func(code int) { exitCode = code }
+		defer func() { exitFunc = originalExitFunc }()
+
+		stdout, _ := captureOutput(func() {
+			handleRepoActivityCommand([]string{"repo-activity", "alice", "broken", "bob"}, failing, &MockGraphQLClient{})
+		})
+
+		if stdout != "" {
+			t.Errorf("Expected no report after the first failure, got:\n%s", stdout)
+		}
+		if exitCode != 1 {
+			t.Errorf("Expected exit code 1, got %d", exitCode)
+		}
+	})
+}
+
+func TestHandlePullsCommand_SortByComments(t *testing.T) {
+	resetFlags()
+	sortFlag = "comments"
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			return json.Unmarshal([]byte(`{"total_count":4,"items":[
+				{"number":1,"title":"Quiet","html_url":"http://example.com/pr/1","state":"open","comments":1},
+				{"number":2,"title":"Hot","html_url":"http://example.com/pr/2","state":"open","comments":42},
+				{"number":3,"title":"Silent","html_url":"http://example.com/pr/3","state":"closed","comments":0},
+				{"number":4,"title":"Warm","html_url":"http://example.com/pr/4","state":"closed","comments":7}
+			]}`), response)
+		},
+	}
+
+	stdout, _ := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	expected := "URL,Title,State,Comments\n" +
+		"http://example.com/pr/2 ,Hot,open,42\n" +
+		"http://example.com/pr/4 ,Warm,closed,7\n" +
+		"http://example.com/pr/1 ,Quiet,open,1\n" +
+		"http://example.com/pr/3 ,Silent,closed,0\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+}
+
+func TestHandlePullsCommand_IncludeCommentCount(t *testing.T) {
+	resetFlags()
+	withCommentCount = true
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			return json.Unmarshal([]byte(`{"total_count":2,"items":[
+				{"number":1,"title":"Quiet","html_url":"http://example.com/pr/1","state":"open","comments":1},
+				{"number":2,"title":"Hot","html_url":"http://example.com/pr/2","state":"open","comments":42}
+			]}`), response)
+		},
+	}
+
+	items, err := fetchAllResults(mockClient, "search/issues?q=x")
+	if err != nil || len(items) != 2 || items[1].Comments != 42 {
+		t.Fatalf("Expected comments to parse from the search payload, got %+v (err %v)", items, err)
+	}
+
+	stdout, _ := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	// Search order is kept; only --sort comments reorders
+	expected := "URL,Title,State,Comments\n" +
+		"http://example.com/pr/1 ,Quiet,open,1\n" +
+		"http://example.com/pr/2 ,Hot,open,42\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
+	}
+}
+
+func TestHandlePullsCommand_JSON(t *testing.T) {
+	resetFlags()
+	outputFormat = "json"
+	mockClient := &MockGitHubClient{}
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		resp := GitHubResponse{
+			TotalCount: 1,
+			Items:      []GitHubItem{{Number: 123, Title: "Test PR", HTMLURL: "http://example.com/pr/123", State: "open"}},
+		}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	stdout, _ := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	var items []GitHubItem
+	if err := json.Unmarshal([]byte(stdout), &items); err != nil {
+		t.Fatalf("Expected valid JSON, got error %v for: %s", err, stdout)
+	}
+	if len(items) != 1 || items[0].HTMLURL != "http://example.com/pr/123" || items[0].Title != "Test PR" {
+		t.Errorf("Unexpected items: %+v", items)
+	}
+	// A zero count is written out, so consumers can tell 0 from missing
+	if !strings.Contains(stdout, `"comments": 0`) {
+		t.Errorf("Expected a comments field of 0, got: %s", stdout)
+	}
+}
+
+func TestHandleReviewsCommand_CSV(t *testing.T) {
+	resetFlags()
+	mockClient := &MockGitHubClient{}
+	testLogin := "testuser"
+	testArgs := []string{"reviews", testLogin}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "reviewed-by%3Atestuser") && strings.Contains(path, "page=1") {
+			resp := GitHubResponse{
+				TotalCount: 1,
+				Items: []GitHubItem{
+					{Number: 789, Title: "Reviewed PR", HTMLURL: "http://example.com/pr/789", State: "closed", Repository: struct {
+						Name string `json:"name"`
+					}{"review-repo"}},
+				},
+			}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		}
+		return fmt.Errorf("unexpected API call: %s", path)
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleReviewsCommand(testArgs, mockClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+
+	expectedHeader := "URL,Title,State"
+	expectedRow := "http://example.com/pr/789 ,Reviewed PR,closed"
+
+	if !strings.Contains(stdout, expectedHeader) {
+		t.Errorf("Expected stdout to contain header '%s', got: %s", expectedHeader, stdout)
+	}
+	if !strings.Contains(stdout, expectedRow) {
+		t.Errorf("Expected stdout to contain row '%s', got: %s", expectedRow, stdout)
+	}
+	if len(mockClient.GetCalls) != 1 {
+		t.Errorf("Expected 1 API call, got %d", len(mockClient.GetCalls))
+	}
+}
+
+func TestHandleReviewsCommand_Count(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	countOnly = true
+	maxItems = 1
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			resp := GitHubResponse{TotalCount: 3, Items: []GitHubItem{
+				{Number: 1, Title: "One", HTMLURL: "http://example.com/pr/1"},
+				{Number: 2, Title: "Two", HTMLURL: "http://example.com/pr/2"},
+				{Number: 3, Title: "Three", HTMLURL: "http://example.com/pr/3"},
+			}}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		},
+	}
+
+	stdout, _ := captureOutput(func() {
+		handleReviewsCommand([]string{"reviews", "testuser"}, mockClient)
+	})
+
+	if stdout != "3\n" {
+		t.Errorf("Expected just the uncapped count, got: %q", stdout)
+	}
+}
+
+func TestHandleReviewsCommand_WithReviewState(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	withReviewState = true
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			switch {
+			case strings.Contains(path, "search/issues?q="):
+				resp := GitHubResponse{Items: []GitHubItem{
+					{Number: 1, Title: "Approved later", HTMLURL: "https://github.com/octo/repo/pull/1", State: "open"},
+					{Number: 2, Title: "Needs work", HTMLURL: "https://github.com/octo/repo/pull/2", State: "open"},
+					{Number: 3, Title: "Approved then chatted", HTMLURL: "https://github.com/octo/repo/pull/3", State: "open"},
+					{Number: 4, Title: "Only comments", HTMLURL: "https://github.com/octo/repo/pull/4", State: "open"},
+				}}
+				data, _ := json.Marshal(resp)
+				return json.Unmarshal(data, response)
+			case strings.HasPrefix(path, "repos/octo/repo/pulls/1/reviews"):
+				return json.Unmarshal([]byte(`[
+					{"state":"CHANGES_REQUESTED","user":{"login":"TestUser"}},
+					{"state":"COMMENTED","user":{"login":"someone"}},
+					{"state":"APPROVED","user":{"login":"testuser"}},
+					{"state":"PENDING","user":{"login":"testuser"}}
+				]`), response)
+			case strings.HasPrefix(path, "repos/octo/repo/pulls/2/reviews"):
+				return json.Unmarshal([]byte(`[{"state":"CHANGES_REQUESTED","user":{"login":"testuser"}}]`), response)
+			case strings.HasPrefix(path, "repos/octo/repo/pulls/3/reviews"):
+				return json.Unmarshal([]byte(`[
+					{"state":"APPROVED","user":{"login":"testuser"}},
+					{"state":"COMMENTED","user":{"login":"testuser"}}
+				]`), response)
+			case strings.HasPrefix(path, "repos/octo/repo/pulls/4/reviews"):
+				return json.Unmarshal([]byte(`[{"state":"COMMENTED","user":{"login":"testuser"}}]`), response)
+			}
+			return fmt.Errorf("unexpected API call: %s", path)
+		},
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleReviewsCommand([]string{"reviews", "testuser"}, mockClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+	for _, want := range []string{
+		"URL,Title,State,Review State",
+		"https://github.com/octo/repo/pull/1 ,Approved later,open,approved",
+		"https://github.com/octo/repo/pull/2 ,Needs work,open,changes_requested",
+		"https://github.com/octo/repo/pull/3 ,Approved then chatted,open,approved",
+		"https://github.com/octo/repo/pull/4 ,Only comments,open,commented",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected stdout to contain %q, got:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestHandleReviewsCommand_BodyOnly(t *testing.T) {
+	resetFlags()
+	bodyOnly = true
+	mockClient := &MockGitHubClient{}
+	testLogin := "testuser"
+	testArgs := []string{"reviews", testLogin}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		if strings.Contains(path, "search/issues?q=") && strings.Contains(path, "is%3Apr") && strings.Contains(path, "reviewed-by%3Atestuser") && strings.Contains(path, "page=1") {
+			resp := GitHubResponse{
+				TotalCount: 1,
+				Items: []GitHubItem{
+					{Number: 789, Title: "Reviewed PR", Body: "Review body.", HTMLURL: "http://example.com/pr/789", State: "closed", Repository: struct {
+						Name string `json:"name"`
+					}{"review-repo"}},
+				},
+			}
+			data, _ := json.Marshal(resp)
+			return json.Unmarshal(data, response)
+		}
+		return fmt.Errorf("unexpected API call: %s", path)
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handleReviewsCommand(testArgs, mockClient)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+
+	expectedOutput := fmt.Sprintf("%s\n%s #%d\n%s\n%s\n%s\n", startOfReview, "Reviewed PR", 789, "Review body.", endOfReview, entryDelimiter)
+
+	if stdout != expectedOutput {
+		t.Errorf("Expected stdout to be:\n%s\nGot:\n%s", expectedOutput, stdout)
+	}
+}
+
+func TestBuildReviewQuery(t *testing.T) {
+	resetFlags()
+	testLogin := "testuser"
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	since = "2025-01-15"
+	expected := "is%3Apr+org%3Agithub+reviewed-by%3Atestuser+sort%3Acreated-desc+created%3A%3E2025-01-15"
+	actual := buildReviewQuery(testLogin)
+	if actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+}
+
+func TestDeduplicateItems(t *testing.T) {
+	existing := []GitHubItem{
+		{HTMLURL: "http://example.com/pr/1"},
+		{HTMLURL: "http://example.com/pr/2"},
+	}
+	candidates := []GitHubItem{
+		{HTMLURL: "http://example.com/pr/2", Title: "Duplicate"},
+		{HTMLURL: "http://example.com/pr/3", Title: "Unique"},
+	}
+
+	result := deduplicateItems(existing, candidates)
+
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 item after dedup, got %d", len(result))
+	}
+	if result[0].HTMLURL != "http://example.com/pr/3" {
+		t.Errorf("Expected unique item, got %s", result[0].HTMLURL)
+	}
+}
+
+func TestFetchAllContributions_MovesPullsOutOfIssues(t *testing.T) {
+	resetFlags()
+	mockClient := &MockGitHubClient{}
+	mockGQLClient := &MockGraphQLClient{}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		var items []GitHubItem
+		if strings.Contains(path, "is%3Apr") && strings.Contains(path, "author%3Atestuser") {
+			items = []GitHubItem{
+				{Number: 1, Title: "Real PR", HTMLURL: "https://github.com/octo/repo/pull/1", State: "open"},
+			}
+		} else if strings.Contains(path, "is%3Aissue") {
+			items = []GitHubItem{
+				{Number: 2, Title: "Real Issue", HTMLURL: "https://github.com/octo/repo/issues/2", State: "open"},
+				{Number: 3, Title: "Converted PR", HTMLURL: "https://github.com/octo/repo/pull/3", State: "closed"},
+				{Number: 1, Title: "Real PR", HTMLURL: "https://github.com/octo/repo/pull/1", State: "open"},
+			}
+		}
+		resp := GitHubResponse{TotalCount: len(items), Items: items}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	results, err := fetchAllContributions(mockClient, mockGQLClient, "testuser", "github", since, allContributionKinds)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(results.issueItems) != 1 || results.issueItems[0].Number != 2 {
+		t.Errorf("Expected only the real issue to remain, got: %+v", results.issueItems)
+	}
+	if len(results.prItems) != 2 {
+		t.Fatalf("Expected 2 PRs after moving the misfiled one, got %d", len(results.prItems))
+	}
+	if results.prItems[1].Number != 3 {
+		t.Errorf("Expected misfiled PR #3 to be moved to PRs, got #%d", results.prItems[1].Number)
+	}
+}
+
+func TestCapContributions(t *testing.T) {
+	resetFlags()
+	maxItems = 3
+	results := &contributionResults{
+		prItems:         []GitHubItem{{Number: 1}, {Number: 2}},
+		reviewItems:     []GitHubItem{{Number: 3}},
+		issueItems:      []GitHubItem{{Number: 4}, {Number: 5}},
+		discussionItems: []GitHubItem{{Number: 6}},
+	}
+
+	_, stderr := captureOutput(func() {
+		capContributions(results)
+	})
+
+	if len(results.prItems) != 2 || len(results.reviewItems) != 1 || len(results.issueItems) != 0 || len(results.discussionItems) != 0 {
+		t.Errorf("Expected 2 PRs, 1 review, 0 issues, 0 discussions, got %d/%d/%d/%d",
+			len(results.prItems), len(results.reviewItems), len(results.issueItems), len(results.discussionItems))
+	}
+	if !strings.Contains(stderr, "showing 3 of 6 items") {
+		t.Errorf("Expected cap note on stderr, got: %s", stderr)
+	}
+}
+
+func TestHandlePullsCommand_MaxItems(t *testing.T) {
+	resetFlags()
+	maxItems = 1
+	mockClient := &MockGitHubClient{}
+
+	mockClient.GetFunc = func(path string, response interface{}) error {
+		resp := GitHubResponse{
+			TotalCount: 2,
+			Items: []GitHubItem{
+				{Number: 1, Title: "Newest PR", HTMLURL: "http://example.com/pr/1", State: "open"},
+				{Number: 2, Title: "Older PR", HTMLURL: "http://example.com/pr/2", State: "open"},
+			},
+		}
+		data, _ := json.Marshal(resp)
+		return json.Unmarshal(data, response)
+	}
+
+	stdout, stderr := captureOutput(func() {
+		handlePullsCommand([]string{"pulls", "testuser"}, mockClient)
+	})
+
+	if !strings.Contains(stdout, "Newest PR") || strings.Contains(stdout, "Older PR") {
+		t.Errorf("Expected only the first PR, got: %s", stdout)
+	}
+	if !strings.Contains(stderr, "capped by --max-items") {
+		t.Errorf("Expected cap note on stderr, got: %s", stderr)
+	}
+}
+
+// MockModelLister simulates an AI endpoint's model listing.
+type MockModelLister struct {
+	Models []string
+	Err    error
+}
+
+func (m *MockModelLister) ListModels() ([]string, error) {
+	return m.Models, m.Err
+}
+
+func TestListModels(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   []string
+	}{
+		{"OpenAIEnvelope", http.StatusOK, `{"object":"list","data":[{"id":"gpt-4o-mini"},{"id":"gpt-4o"}]}`, []string{"gpt-4o", "gpt-4o-mini"}},
+		{"BareArray", http.StatusOK, `[{"id":"azureml://registries/x/models/gpt-4o","name":"gpt-4o"}]`, []string{"gpt-4o"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/models" {
+					t.Errorf("Expected request to /models, got %s", r.URL.Path)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+					t.Errorf("Expected bearer token, got %q", got)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			s := &AzureAISummarizer{httpClient: server.Client(), tokenFetcher: &MockTokenFetcher{TokenToReturn: "test-token"}, endpoint: server.URL + "/chat/completions"}
+			got, err := s.ListModels()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		s := &AzureAISummarizer{httpClient: server.Client(), tokenFetcher: &MockTokenFetcher{}, endpoint: server.URL + "/chat/completions"}
+		if _, err := s.ListModels(); err != errModelListingUnsupported {
+			t.Errorf("Expected errModelListingUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestRepairJSONSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{"Valid", `[{"title":"A"}]`, `[{"title":"A"}]`, false},
+		{"CodeFence", "```json\n[{\"title\":\"A\"}]\n```", `[{"title":"A"}]`, false},
+		{"SurroundingText", "Here you go: {\"title\":\"A\"} Hope it helps", `{"title":"A"}`, false},
+		{"Invalid", `[{"title": "A",}]`, "", true},
+		{"NoJSON", "Just prose", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repairJSONSummary(tt.content)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Expected %q (error %v), got %q (%v)", tt.want, tt.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func TestHandleSummarizeCommand_JSONMergesRequests(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	summarizeFormat = "json"
+	mockSummarizer := &MockSummarizer{SummaryToReturn: `[{"title":"Fix","summary":"Fixed it.","links":["https://example.com/1"]}]`}
+	input := "First" + entryDelimiter + "Second" + entryDelimiter
+
+	stdout, _ := captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", input}, mockSummarizer, false)
+	})
+
+	var summaries []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &summaries); err != nil {
+		t.Fatalf("Expected one JSON document, got error %v for:\n%s", err, stdout)
+	}
+	if len(mockSummarizer.SummarizeCalls) != 2 || len(summaries) != 2 || summaries[1]["title"] != "Fix" {
+		t.Errorf("Expected both requests' objects in one array, got %v", summaries)
+	}
+
+	if merged, err := mergeJSONSummaries([]string{`[{"a":1}]`, `{"b":2}`}); err != nil || merged != "[\n  {\n    \"a\": 1\n  },\n  {\n    \"b\": 2\n  }\n]" {
+		t.Errorf("Expected a lone object to be appended to the array, got %q (err %v)", merged, err)
+	}
+}
+
+func TestBuildSystemPrompt_SummarizeFormat(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	if prompt := buildSystemPrompt(); !strings.Contains(prompt, "## <descriptive title>") {
+		t.Errorf("Expected the Markdown format by default, got:\n%s", prompt)
+	}
+	summarizeFormat = "json"
+	if prompt := buildSystemPrompt(); !strings.Contains(prompt, `"links" (an array of artifact URLs)`) || strings.Contains(prompt, "## <descriptive title>") {
+		t.Errorf("Expected only the JSON instructions, got:\n%s", prompt)
+	}
+	summarizeFormat = "bullets"
+	if prompt := buildSystemPrompt(); !strings.Contains(prompt, "terse Markdown bullets") {
+		t.Errorf("Expected the bullet instructions, got:\n%s", prompt)
+	}
+}
+
+func TestAzureAISummarizer_JSONFormat(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	summarizeFormat = "json"
+	responses := []string{
+		`{"choices":[{"message":{"content":"Sorry, not JSON"}}]}`,
+		`{"choices":[{"message":{"content":"` + "```json\\n[{\\\"title\\\":\\\"A\\\"}]\\n```" + `"}}]}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, responses[requests%len(responses)])
+		requests++
+	}))
+	defer server.Close()
+	s := &AzureAISummarizer{httpClient: server.Client(), tokenFetcher: &MockTokenFetcher{TokenToReturn: "test-token"}, endpoint: server.URL}
+
+	// Invalid JSON is retried, and the fenced answer is repaired
+	summary, err := s.Summarize("Entry")
+	if err != nil || summary != `[{"title":"A"}]` {
+		t.Fatalf("Expected the repaired JSON summary, got %q (err %v)", summary, err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected one retry, got %d requests", requests)
+	}
+
+	requests = 0
+	aiRetries = 0
+	if _, err := s.Summarize("Entry"); !errors.Is(err, errInvalidJSONSummary) {
+		t.Errorf("Expected errInvalidJSONSummary with --ai-retries 0, got %v", err)
+	}
+}
+
+func TestAzureAISummarizer_RetriesEmptyContent(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	responses := []string{
+		`{"choices":[{"message":{"content":""}}]}`,
+		`{"choices":[{"message":{"content":"Second time lucky"}}]}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, responses[requests%len(responses)])
+		requests++
+	}))
+	defer server.Close()
+	s := &AzureAISummarizer{httpClient: server.Client(), tokenFetcher: &MockTokenFetcher{TokenToReturn: "test-token"}, endpoint: server.URL}
+
+	summary, err := s.Summarize("Entry")
+	if err != nil || summary != "Second time lucky" {
+		t.Fatalf("Expected the retried summary, got %q (err %v)", summary, err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected one retry, got %d requests", requests)
+	}
+
+	requests = 0
+	aiRetries = 0
+	if _, err := s.Summarize("Entry"); !errors.Is(err, errEmptySummary) {
+		t.Errorf("Expected errEmptySummary with --ai-retries 0, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected no retry with --ai-retries 0, got %d requests", requests)
+	}
+
+	// HTTP failures are not retried
+	requests = 0
+	aiRetries = 3
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	s.endpoint = failing.URL
+	if _, err := s.Summarize("Entry"); err == nil || requests != 1 {
+		t.Errorf("Expected a single failed request, got %d (err %v)", requests, err)
+	}
+}
+
+func TestResolveSettings(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	originalOrgConfigFunc, originalModelConfigFunc := orgConfigFunc, modelConfigFunc
+	defer func() { orgConfigFunc, modelConfigFunc = originalOrgConfigFunc, originalModelConfigFunc }()
+	orgConfigFunc = func() (string, error) { return "config-org", nil }
+	modelConfigFunc = func() string { return "config-model" }
+
+	sources := func() map[string]string {
+		got := make(map[string]string)
+		for _, setting := range resolveSettings() {
+			got[setting.Setting] = setting.Value + " <- " + setting.Source
+		}
+		return got
+	}
+
+	got := sources()
+	if got["org"] != "config-org <- config" || got["model"] != "config-model <- config" {
+		t.Errorf("Expected config values, got %v", got)
+	}
+	if got["endpoint"] != aiEndpoint+" <- default" || !strings.HasSuffix(got["since"], "<- default (30 days ago)") {
+		t.Errorf("Expected default since and endpoint, got %v", got)
+	}
+
+	orgFlag = "flag-org"
+	aiModelFlag = "flag-model"
+	got = sources()
+	if got["org"] != "flag-org <- flag (--org)" || got["model"] != "flag-model <- flag (--ai-model)" {
+		t.Errorf("Expected flag values to win, got %v", got)
+	}
+
+	orgFlag, aiModelFlag = "", ""
+	orgConfigFunc = func() (string, error) { return "", fmt.Errorf("no config") }
+	modelConfigFunc = func() string { return defaultModel }
+	got = sources()
+	if got["org"] != defaultOrg+" <- default" || got["model"] != defaultModel+" <- default" {
+		t.Errorf("Expected defaults, got %v", got)
+	}
+}
+
+func TestCheckStrictOrg(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	originalOrgConfigFunc, originalStrictOrgConfigFunc := orgConfigFunc, strictOrgConfigFunc
+	defer func() { orgConfigFunc, strictOrgConfigFunc = originalOrgConfigFunc, originalStrictOrgConfigFunc }()
+	defer func() { orgFlag = "" }()
+	orgConfigFunc = func() (string, error) { return "", fmt.Errorf("no config") }
+	strictOrgConfigFunc = func() bool { return false }
+
+	if err := checkStrictOrg(); err != nil {
+		t.Errorf("Expected the %s fallback without strict mode, got %v", defaultOrg, err)
+	}
+
+	strictOrg = true
+	err := checkStrictOrg()
+	if err == nil || !strings.Contains(err.Error(), "--org ORG") || !strings.Contains(err.Error(), "extensions.gh-contrib") {
+		t.Errorf("Expected an error with guidance on setting the org, got %v", err)
+	}
+
+	strictOrg = false
+	strictOrgConfigFunc = func() bool { return true }
+	if err := checkStrictOrg(); err == nil {
+		t.Error("Expected strict_org in the config to enable strict mode")
+	}
+
+	orgConfigFunc = func() (string, error) { return "config-org", nil }
+	if err := checkStrictOrg(); err != nil {
+		t.Errorf("Expected a configured org to satisfy strict mode, got %v", err)
+	}
+
+	orgConfigFunc = func() (string, error) { return "", fmt.Errorf("no config") }
+	orgFlag = "flag-org"
+	if err := checkStrictOrg(); err != nil {
+		t.Errorf("Expected --org to satisfy strict mode, got %v", err)
+	}
+}
+
+func TestHandleModelsCommand(t *testing.T) {
+	resetFlags()
+	aiModelFlag = "gpt-4o"
+	defer func() { aiModelFlag = "" }()
+
+	stdout, _ := captureOutput(func() {
+		handleModelsCommand(&MockModelLister{Models: []string{"gpt-4o", "gpt-4o-mini"}})
+	})
+	if stdout != "gpt-4o (current)\ngpt-4o-mini\n" {
+		t.Errorf("Unexpected output: %q", stdout)
+	}
+
+	stdout, _ = captureOutput(func() {
+		handleModelsCommand(&MockModelLister{Err: errModelListingUnsupported})
+	})
+	if !strings.Contains(stdout, "doesn't support listing models") || !strings.Contains(stdout, "Current model: gpt-4o") {
+		t.Errorf("Expected unsupported message with current model, got: %q", stdout)
+	}
+}
+
+func TestHandleSummarizeCommand_Estimate(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	estimateFlag = true
+	mock := &MockSummarizer{}
+	input := strings.Repeat("a", 400) + markers.entry + strings.Repeat("b", 40)
+
+	stdout, _ := captureOutput(func() { handleSummarizeCommand([]string{"summarize", input}, mock, false) })
+	if len(mock.SummarizeCalls) != 0 || len(mock.CombinedCalls) != 0 {
+		t.Fatal("Expected --estimate not to call the summarizer")
+	}
+	system := estimateTokens(buildSystemPrompt())
+	wantInput := 2*system + estimateTokens(buildUserPrompt(strings.Repeat("a", 400))) + estimateTokens(buildUserPrompt(strings.Repeat("b", 40)))
+	for _, want := range []string{
+		"Entry 1: ~100 tokens",
+		"Entry 2: ~10 tokens",
+		"Requests: 2",
+		fmt.Sprintf("Estimated input tokens: ~%d", wantInput),
+		"Estimated output tokens: up to 2000 (--length medium)",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, stdout)
+		}
+	}
+
+	// Over-budget --combine input is chunked, then the partials are combined
+	combineFlag = true
+	summaryLengthFlag = "short"
+	est := estimateSummarize([]string{strings.Repeat("a", combineCharBudget), strings.Repeat("b", combineCharBudget)})
+	if est.requests != 3 || est.outputTokens != 900 {
+		t.Errorf("Expected 3 requests and up to 900 output tokens, got %d and %d", est.requests, est.outputTokens)
+	}
+}
+
+func TestHandleSummarizeCommand_Edit(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	originalTerminalFunc, originalEditorFunc := stdoutIsTerminalFunc, runEditorFunc
+	defer func() { stdoutIsTerminalFunc, runEditorFunc = originalTerminalFunc, originalEditorFunc }()
+	t.Setenv("EDITOR", "vi")
+	editFlag = true
+
+	var editorCalls []string
+	runEditorFunc = func(editor, path string) error {
+		editorCalls = append(editorCalls, editor)
+		content, _ := os.ReadFile(path)
+		return os.WriteFile(path, []byte(strings.ToUpper(string(content))), 0600)
+	}
+	mock := &MockSummarizer{SummaryToReturn: "draft summary"}
+	input := "Entry one" + markers.entry + "Entry two"
+
+	stdoutIsTerminalFunc = func() bool { return true }
+	stdout, _ := captureOutput(func() { handleSummarizeCommand([]string{"summarize", input}, mock, false) })
+	if stdout != "DRAFT SUMMARY\nDRAFT SUMMARY\n" || len(editorCalls) != 1 || editorCalls[0] != "vi" {
+		t.Errorf("Expected both summaries edited in one vi session, got %q after %v", stdout, editorCalls)
+	}
+
+	// Piped output skips the editor
+	stdoutIsTerminalFunc = func() bool { return false }
+	stdout, _ = captureOutput(func() { handleSummarizeCommand([]string{"summarize", input}, mock, false) })
+	if stdout != "draft summary\ndraft summary\n" || len(editorCalls) != 1 {
+		t.Errorf("Expected plain output when piped, got %q", stdout)
+	}
+
+	// So does a missing $EDITOR, with a warning
+	stdoutIsTerminalFunc = func() bool { return true }
+	t.Setenv("EDITOR", "")
+	stdout, stderr := captureOutput(func() { handleSummarizeCommand([]string{"summarize", input}, mock, false) })
+	if stdout != "draft summary\ndraft summary\n" || !strings.Contains(stderr, "--edit needs $EDITOR") {
+		t.Errorf("Expected plain output and a warning without $EDITOR, got %q / %q", stdout, stderr)
+	}
+}
+
+func TestHandleSummarizeCommand_SummaryFooter(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) { return "octo-org", nil }
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+	mock := &MockSummarizer{SummaryToReturn: "Shipped the login flow."}
+
+	stdout, _ := captureOutput(func() { handleSummarizeCommand([]string{"summarize", "Entry"}, mock, false) })
+	if strings.Contains(stdout, "Scope:") {
+		t.Errorf("Expected no footer by default, got %q", stdout)
+	}
+
+	summaryFooter = "octocat"
+	since, until = "2025-01-01", "2025-01-31"
+	stdout, _ = captureOutput(func() { handleSummarizeCommand([]string{"summarize", "Entry"}, mock, false) })
+	for _, want := range []string{
+		"Shipped the login flow.\n\n---\nScope: octocat in octo-org, 2025-01-01 to 2025-01-31\n",
+		"Pull requests: " + buildWebURL("is:pr", "octocat") + "\n",
+		"Issues: " + buildWebURL("is:issue", "octocat") + "\n",
+		"All activity: " + buildWebURL("", "octocat") + "\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, stdout)
+		}
+	}
+
+	// No footer without a summary to describe
+	mock.ErrorToReturn = fmt.Errorf("boom")
+	stdout, _ = captureOutput(func() { handleSummarizeCommand([]string{"summarize", "Entry"}, mock, false) })
+	if stdout != "" {
+		t.Errorf("Expected no footer when summarizing failed, got %q", stdout)
+	}
+}
+
+func TestCachingSummarizer(t *testing.T) {
+	resetFlags()
+	mock := &MockSummarizer{SummaryToReturn: "Fresh summary"}
+	cache := &cachingSummarizer{Summarizer: mock, dir: filepath.Join(t.TempDir(), "summaries"), model: "gpt-4o"}
+
+	for i := 0; i < 2; i++ {
+		summary, err := cache.Summarize("Entry text")
+		if err != nil || summary != "Fresh summary" {
+			t.Fatalf("Expected the summary on call %d, got %q (err %v)", i+1, summary, err)
+		}
+	}
+	if len(mock.SummarizeCalls) != 1 {
+		t.Errorf("Expected a cache hit to skip the summarizer, got %d calls", len(mock.SummarizeCalls))
+	}
+	if info, err := os.Stat(cache.dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("Expected a 0700 summary cache directory (err %v)", err)
+	}
+
+	// A different model, text, or mode misses the cache
+	cache.model = "gpt-4o-mini"
+	cache.Summarize("Entry text")
+	cache.Summarize("Other text")
+	cache.SummarizeCombined("Other text")
+	if len(mock.SummarizeCalls) != 3 || len(mock.CombinedCalls) != 1 {
+		t.Errorf("Expected misses for new keys, got %d and %d calls", len(mock.SummarizeCalls), len(mock.CombinedCalls))
+	}
+
+	// Failures are not cached
+	mock.ErrorToReturn = fmt.Errorf("boom")
+	if _, err := cache.Summarize("Failing text"); err == nil {
+		t.Error("Expected the summarizer error")
+	}
+	mock.ErrorToReturn = nil
+	if summary, _ := cache.Summarize("Failing text"); summary != "Fresh summary" {
+		t.Errorf("Expected a retry after a failure, got %q", summary)
+	}
+}
+
+func TestHandleSummarizeCommand(t *testing.T) {
+	resetFlags()
+	mockSummarizer := &MockSummarizer{
+		SummaryToReturn: "This is the summary.",
+	}
+	testArgs := []string{"summarize"} // Input will come from stdin
+
+	// Prepare stdin
+	inputBody := "Some text to summarize" + entryDelimiter + "Another piece of text"
+	oldStdin := os.Stdin
+	r, w, _ := os.Pipe()
+	os.Stdin = r
+	w.WriteString(inputBody)
+	w.Close()
+	defer func() { os.Stdin = oldStdin }() // Restore stdin
+
+	stdout, stderr := captureOutput(func() {
+		handleSummarizeCommand(testArgs, mockSummarizer, false)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+
+	expectedOutput := `This is the summary.
+This is the summary.
 `
 	if stdout != expectedOutput {
 		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expectedOutput, stdout)
@@ -507,21 +3242,646 @@ func TestBuildQuery(t *testing.T) {
 	orgConfigFunc = func() (string, error) {
 		return "github", nil
 	}
-	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	// Test default query includes since filter
+	expected := fmt.Sprintf("is%%3Apr+org%%3Agithub+author%%3Atestuser+sort%%3Acreated-desc+created%%3A%%3E%s", since)
+	actual := buildQuery("is:pr", testLogin)
+	if actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+
+	// Test with custom since flag
+	since = "2025-01-15"
+	expectedSince := "is%3Aissue+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+created%3A%3E2025-01-15"
+	actualSince := buildQuery("is:issue", testLogin)
+	if actualSince != expectedSince {
+		t.Errorf("Expected query '%s', got '%s'", expectedSince, actualSince)
+	}
+}
+
+func TestBuildQueryWithUntil(t *testing.T) {
+	resetFlags()
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	since = "2025-01-01"
+	until = "2025-03-31"
+	expected := "is%3Apr+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+created%3A2025-01-01..2025-03-31"
+	if actual := buildQuery("is:pr", "testuser"); actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+
+	since = ""
+	expected = "is%3Apr+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+created%3A%3C%3D2025-03-31"
+	if actual := buildQuery("is:pr", "testuser"); actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+}
+
+func TestUntilInclusive(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	until = "2025-04-30"
+	item, _ := time.Parse(time.RFC3339, "2025-04-30T20:00:00+09:00")
+
+	// By default the whole --until day is in the window
+	timezoneFlag = "Asia/Tokyo"
+	location, _ = time.LoadLocation(timezoneFlag)
+	if got := qualifierEndDate(until, true); got != "2025-04-30T23:59:59+09:00" {
+		t.Errorf("Expected the end of the until day, got %s", got)
+	}
+	if item.After(windowEnd()) {
+		t.Errorf("Expected an item at 20:00 on the until date to be in the window ending %v", windowEnd())
+	}
+	if got := dateQualifier(""); got != " created:<=2025-04-30T23:59:59+09:00" {
+		t.Errorf("Unexpected qualifier: %q", got)
+	}
+
+	// --until-inclusive=false ends the window as the until day starts
+	untilInclusive = false
+	if got := qualifierEndDate(until, false); got != "2025-04-29T23:59:59+09:00" {
+		t.Errorf("Expected the end of the previous day, got %s", got)
+	}
+	if !item.After(windowEnd()) {
+		t.Errorf("Expected an item at 20:00 on the until date to be outside the window ending %v", windowEnd())
+	}
+
+	// Without --timezone the bounds stay bare UTC dates
+	timezoneFlag, location = "", time.UTC
+	if got := qualifierEndDate(until, true); got != "2025-04-30" {
+		t.Errorf("Expected the bare until date, got %s", got)
+	}
+	if got := qualifierEndDate(until, false); got != "2025-04-29" {
+		t.Errorf("Expected the day before, got %s", got)
+	}
+}
+
+func TestBuildQueryWithDateField(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	dateField = "updated"
+	since = "2025-01-01"
+	expected := "is%3Apr+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+updated%3A%3E2025-01-01"
+	if actual := buildQuery("is:pr", "testuser"); actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+
+	until = "2025-03-31"
+	if link := buildWebURL("is:pr", "testuser"); !strings.Contains(link, "updated%3A2025-01-01..2025-03-31") || strings.Contains(link, "created%3A") {
+		t.Errorf("Expected the web link to filter on updated, got '%s'", link)
+	}
+}
+
+func TestBuildQueryWithBase(t *testing.T) {
+	resetFlags()
+	since = ""
+	baseFlag = "main"
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	expected := "is%3Apr+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+base%3Amain"
+	if actual := buildQuery("is:pr", "testuser"); actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+	if actual := buildQuery("is:issue", "testuser"); strings.Contains(actual, "base%3A") {
+		t.Errorf("Expected no base qualifier on issue query, got '%s'", actual)
+	}
+	if actual := buildWebURL("is:pr", "testuser"); !strings.Contains(actual, "base%3Amain") {
+		t.Errorf("Expected base qualifier in web URL, got '%s'", actual)
+	}
+
+	var buf bytes.Buffer
+	warnIgnoredBase(&buf, "pulls")
+	if buf.Len() != 0 {
+		t.Errorf("Expected no warning for pulls, got: %s", buf.String())
+	}
+	warnIgnoredBase(&buf, "issues")
+	if !strings.Contains(buf.String(), "--base only applies to pull request queries") {
+		t.Errorf("Expected warning for issues, got: %s", buf.String())
+	}
+}
+
+func TestBuildQueryWithMilestone(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	since = ""
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	milestoneFlag = "v2.0"
+	for _, itemType := range []string{"is:pr", "is:issue"} {
+		if actual := buildQuery(itemType, "testuser"); !strings.HasSuffix(actual, "+milestone%3Av2.0") {
+			t.Errorf("Expected milestone qualifier on %s query, got '%s'", itemType, actual)
+		}
+	}
+
+	milestoneFlag = "Q3 Launch"
+	if actual := buildQuery("is:issue", "testuser"); !strings.Contains(actual, "milestone%3A%22Q3+Launch%22") {
+		t.Errorf("Expected quoted milestone name, got '%s'", actual)
+	}
+	if actual := buildWebURL("", "testuser"); !strings.Contains(actual, "milestone%3A%22Q3+Launch%22") {
+		t.Errorf("Expected milestone qualifier in web URL, got '%s'", actual)
+	}
+
+	var buf bytes.Buffer
+	warnIgnoredMilestone(&buf, "issues")
+	if buf.Len() != 0 {
+		t.Errorf("Expected no warning for issues, got: %s", buf.String())
+	}
+	warnIgnoredMilestone(&buf, "reviews")
+	if !strings.Contains(buf.String(), "--milestone only applies to pull request and issue queries") {
+		t.Errorf("Expected warning for reviews, got: %s", buf.String())
+	}
+
+	// A milestone with no matches gets the usual friendly message
+	mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		return json.Unmarshal([]byte(`{"total_count": 0, "items": []}`), response)
+	}}
+	stdout, stderr := captureOutput(func() { handlePullsCommand([]string{"pulls", "testuser"}, mockClient) })
+	if !strings.Contains(stdout, "No pull requests found") || stderr != "" {
+		t.Errorf("Expected the no-results message, got %q / %q", stdout, stderr)
+	}
+	if !strings.Contains(mockClient.GetCalls[0], "milestone%3A%22Q3+Launch%22") {
+		t.Errorf("Expected the milestone in the search, got %s", mockClient.GetCalls[0])
+	}
+}
+
+func TestBuildQueryWithQueryExtra(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	since = ""
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	queryExtra = "  review:approved -label:wip "
+	expected := "is%3Apr+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+review%3Aapproved+-label%3Awip"
+	if actual := buildQuery("is:pr", "testuser"); actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+	if actual := buildWebURL("is:pr", "testuser"); !strings.Contains(actual, "review%3Aapproved+-label%3Awip") {
+		t.Errorf("Expected the extra qualifiers in the web URL, got '%s'", actual)
+	}
+}
+
+func TestBuildQueryWithLinked(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	since = ""
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	linkedFlag = "pr"
+	expected := "is%3Aissue+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+linked%3Apr"
+	if actual := buildQuery("is:issue", "testuser"); actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+	if actual := buildQuery("is:pr", "testuser"); strings.Contains(actual, "linked%3A") {
+		t.Errorf("Expected no linked qualifier on PR query for --linked pr, got '%s'", actual)
+	}
+	if actual := buildWebURL("is:issue", "testuser"); !strings.Contains(actual, "linked%3Apr") {
+		t.Errorf("Expected linked qualifier in web URL, got '%s'", actual)
+	}
+
+	var buf bytes.Buffer
+	warnIgnoredLinked(&buf, "issues")
+	if buf.Len() != 0 {
+		t.Errorf("Expected no warning for issues, got: %s", buf.String())
+	}
+	warnIgnoredLinked(&buf, "pulls")
+	if !strings.Contains(buf.String(), "--linked pr only applies to issue queries") {
+		t.Errorf("Expected warning for pulls, got: %s", buf.String())
+	}
+
+	linkedFlag = "issue"
+	if actual := buildQuery("is:pr", "testuser"); !strings.Contains(actual, "linked%3Aissue") {
+		t.Errorf("Expected linked:issue on PR query, got '%s'", actual)
+	}
+	if actual := buildQuery("is:issue", "testuser"); strings.Contains(actual, "linked%3A") {
+		t.Errorf("Expected no linked qualifier on issue query for --linked issue, got '%s'", actual)
+	}
+}
+
+func TestBuildWebURL_TooLong(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	since = "2025-01-01"
+	until = "2025-01-31"
+	orgFlag = "github"
+	defer func() { orgFlag = "" }()
+
+	short := buildWebURL("is:pr", "testuser")
+	if !strings.Contains(short, "sort%3Aupdated-desc") {
+		t.Fatalf("Expected the full query for a short link, got %s", short)
+	}
+
+	baseFlag = strings.Repeat("very-long-branch-name-", 100)
+	visibilityFlag = "private"
+	var link string
+	_, stderr := captureOutput(func() {
+		link = buildWebURL("is:pr", "testuser")
+	})
+
+	if len(link) > maxWebURLLength {
+		t.Errorf("Expected link within %d characters, got %d", maxWebURLLength, len(link))
+	}
+	if !strings.Contains(stderr, "over the 2000 limit") {
+		t.Errorf("Expected a warning, got: %q", stderr)
+	}
+	for _, kept := range []string{"is%3Apr", "org%3Agithub", "author%3Atestuser", "created%3A2025-01-01..2025-01-31", "is%3Aprivate"} {
+		if !strings.Contains(link, kept) {
+			t.Errorf("Expected %s to be kept, got %s", kept, link)
+		}
+	}
+	if strings.Contains(link, "base%3A") || strings.Contains(link, "sort%3A") {
+		t.Errorf("Expected base and sort to be dropped, got %s", link)
+	}
+}
+
+func TestDefaultSince(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	originalTimeNow := timeNowFunc
+	defer func() { timeNowFunc = originalTimeNow }()
+	timeNowFunc = func() time.Time { return time.Date(2025, 3, 31, 23, 30, 0, 0, time.UTC) }
+
+	if got := defaultSince(); got != "2025-03-01" {
+		t.Errorf("Expected 30 days before now, got %s", got)
+	}
+
+	// The default follows --timezone: it's already April 1 in Tokyo
+	location = time.FixedZone("UTC+9", 9*60*60)
+	if got := defaultSince(); got != "2025-03-02" {
+		t.Errorf("Expected 30 days before now in the configured zone, got %s", got)
+	}
+}
+
+func TestDefaultSince_NormalizesToUTC(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	originalTimeNow := timeNowFunc
+	defer func() { timeNowFunc = originalTimeNow }()
+	// Evening of March 31 on the US west coast is already April 1 in UTC
+	timeNowFunc = func() time.Time { return time.Date(2025, 3, 31, 20, 0, 0, 0, time.FixedZone("PDT", -7*60*60)) }
+
+	if got := defaultSince(); got != "2025-03-02" {
+		t.Errorf("Expected the default window to start from the UTC date, got %s", got)
+	}
+	if got := windowEnd().Format(dateFormat); got != "2025-04-01" {
+		t.Errorf("Expected today to be the UTC date, got %s", got)
+	}
+}
+
+func TestRefreshAuth(t *testing.T) {
+	originalRefresh := refreshTokenFunc
+	defer func() { refreshTokenFunc = originalRefresh }()
+
+	refreshTokenFunc = func() error { return nil }
+	var out bytes.Buffer
+	fetcher := &MockTokenFetcher{TokenToReturn: "fresh-token"}
+	if !refreshAuth(&out, fetcher) || out.String() != "Token refreshed\n" {
+		t.Errorf("Expected a successful refresh, got: %q", out.String())
+	}
+	if fetcher.FetchCount != 1 {
+		t.Errorf("Expected the token to be re-fetched once, got %d", fetcher.FetchCount)
+	}
+
+	refreshTokenFunc = func() error { return errors.New("error running gh auth refresh: exit status 1") }
+	out.Reset()
+	if refreshAuth(&out, fetcher) || !strings.Contains(out.String(), "token refresh failed: error running gh auth refresh") {
+		t.Errorf("Expected a failed refresh to be reported, got: %q", out.String())
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	resetFlags()
+	originalTimeNow := timeNowFunc
+	defer func() { timeNowFunc = originalTimeNow }()
+	serverTime := time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC)
+
+	mockClient := &MockGitHubClient{
+		GetWithResponseFunc: func(path string) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Date", serverTime.Format(http.TimeFormat))
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		offset   time.Duration
+		wantWarn bool
+	}{
+		{"in sync", 30 * time.Second, false},
+		{"ahead", 2 * time.Hour, true},
+		{"behind", -10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeNowFunc = func() time.Time { return serverTime.Add(tt.offset) }
+			var buf bytes.Buffer
+			checkClockSkew(mockClient, &buf)
+			warned := strings.Contains(buf.String(), "local clock differs from GitHub's by "+tt.offset.String())
+			if warned != tt.wantWarn {
+				t.Errorf("Expected warning=%v, got: %q", tt.wantWarn, buf.String())
+			}
+		})
+	}
+
+	// The debug skew line goes to w with the warnings, never to stdout
+	debug = true
+	defer func() { debug = false }()
+	timeNowFunc = func() time.Time { return serverTime.Add(30 * time.Second) }
+	var debugBuf bytes.Buffer
+	stdout, _ := captureOutput(func() { checkClockSkew(mockClient, &debugBuf) })
+	if stdout != "" || debugBuf.String() != "Clock skew vs GitHub: 30s\n" {
+		t.Errorf("Expected the debug skew on w only, got stdout %q and w %q", stdout, debugBuf.String())
+	}
+
+	var buf bytes.Buffer
+	checkClockSkew(&MockGitHubClient{}, &buf)
+	if !strings.Contains(buf.String(), "no valid Date header") {
+		t.Errorf("Expected a warning about the missing Date header, got: %q", buf.String())
+	}
+}
+
+func TestLastRunState(t *testing.T) {
+	resetFlags()
+	path := filepath.Join(t.TempDir(), "gh-contrib", "lastrun")
+
+	if _, ok, err := readLastRun(path); ok || err != nil {
+		t.Fatalf("Expected no previous run for a missing file, got ok=%v err=%v", ok, err)
+	}
+
+	runAt := time.Date(2025, 3, 10, 15, 4, 5, 0, time.UTC)
+	if err := writeLastRun(path, runAt); err != nil {
+		t.Fatalf("Unexpected error writing state: %v", err)
+	}
+	lastRun, ok, err := readLastRun(path)
+	if err != nil || !ok || !lastRun.Equal(runAt) {
+		t.Errorf("Expected %v, got %v (ok=%v, err=%v)", runAt, lastRun, ok, err)
+	}
+
+	location = time.UTC
+	defer resetFlags()
+	if got := sinceForLastRun(lastRun); got != "2025-03-09" {
+		t.Errorf("Expected the day before the last run, got %s", got)
+	}
+
+	if err := os.WriteFile(path, []byte("yesterday"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readLastRun(path); err == nil {
+		t.Error("Expected an error for an invalid timestamp")
+	}
+}
+
+func TestRunSucceeded(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		if strings.Contains(path, "q=broken") {
+			return fmt.Errorf("HTTP 502: Bad Gateway")
+		}
+		return json.Unmarshal([]byte(`{"total_count":0,"items":[]}`), response)
+	}}
+
+	if runSucceeded() {
+		t.Error("Expected a run that searched nothing (config resolve, summarize) not to count")
+	}
+	fetchAllResults(mockClient, "search/issues?q=fine")
+	if !runSucceeded() {
+		t.Error("Expected a successful search to count, even with no results")
+	}
+	fetchAllResults(mockClient, "search/issues?q=broken")
+	if runSucceeded() {
+		t.Error("Expected a failed search to keep the run from being recorded")
+	}
+}
+
+func TestResolveISOWeek(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"2025-W16", "2025-04-14", false},
+		{"2025-W01", "2024-12-30", false},
+		{"2026-W53", "2026-12-28", false},
+		{"2025-W53", "", true},
+		{"2025-W00", "", true},
+		{"2025-W7", "", true},
+		{"2025-w16", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if !looksLikeISOWeek(tt.value) {
+				t.Fatalf("Expected %q to be treated as an ISO week", tt.value)
+			}
+			got, err := resolveISOWeek(tt.value)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("resolveISOWeek(%q) = %q, %v; want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if looksLikeISOWeek("2025-04-14") || looksLikeISOWeek("issue:o/r#1") {
+		t.Error("Expected dates and issue references not to be treated as ISO weeks")
+	}
+}
+
+func TestResolveSinceRelease(t *testing.T) {
+	resetFlags()
+	mockClient := &MockGitHubClient{GetFunc: func(path string, response interface{}) error {
+		switch path {
+		case "repos/octo/alpha/releases/latest":
+			return json.Unmarshal([]byte(`{"tag_name":"v1.2.0","published_at":"2025-04-10T15:00:00Z"}`), response)
+		case "repos/octo/beta/releases/latest":
+			return json.Unmarshal([]byte(`{"tag_name":"v3.0.0","published_at":"2025-05-02T09:00:00Z"}`), response)
+		case "repos/other/gamma/releases/latest":
+			return json.Unmarshal([]byte(`{"tag_name":"v0.9.0","published_at":"2025-03-01T09:00:00Z"}`), response)
+		case "repos/octo/private/releases/latest":
+			return &api.HTTPError{StatusCode: http.StatusUnauthorized, Message: "Bad credentials"}
+		}
+		return &api.HTTPError{StatusCode: http.StatusNotFound, Message: "Not Found"}
+	}}
+
+	tests := []struct {
+		name     string
+		repos    string
+		anchor   string
+		want     string
+		wantNote string
+	}{
+		{"latest", "alpha,beta,no-releases", "latest", "2025-05-02", "Using --since 2025-05-02 from the latest release of octo/beta (v3.0.0, published 2025-05-02T09:00:00Z)"},
+		{"earliest", "alpha, beta, other/gamma", "earliest", "2025-03-01", "Using --since 2025-03-01 from the earliest release of other/gamma (v0.9.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var err error
+			_, stderr := captureOutput(func() {
+				got, err = resolveSinceRelease(mockClient, "octo", tt.repos, tt.anchor)
+			})
+			if err != nil || got != tt.want {
+				t.Errorf("Expected %s, got %q (err %v)", tt.want, got, err)
+			}
+			if !strings.Contains(stderr, tt.wantNote) {
+				t.Errorf("Expected the anchoring release on stderr, got %q", stderr)
+			}
+		})
+	}
+
+	t.Run("NoReleases", func(t *testing.T) {
+		_, err := resolveSinceRelease(mockClient, "octo", "no-releases,also-none", "latest")
+		if err == nil || !strings.Contains(err.Error(), "no releases found for --since release:no-releases,also-none") {
+			t.Errorf("Expected a no-releases error, got %v", err)
+		}
+	})
+
+	t.Run("OtherErrorsReturned", func(t *testing.T) {
+		_, err := resolveSinceRelease(mockClient, "octo", "alpha,private", "latest")
+		if err == nil || !strings.Contains(err.Error(), "error fetching the latest release of octo/private") {
+			t.Errorf("Expected the API error for octo/private, got %v", err)
+		}
+	})
+}
+
+func TestResolveSinceIssue(t *testing.T) {
+	resetFlags()
+	mockClient := &MockGitHubClient{
+		GetFunc: func(path string, response interface{}) error {
+			if path != "repos/octo/incidents/issues/123" {
+				return fmt.Errorf("HTTP 404: Not Found")
+			}
+			return json.Unmarshal([]byte(`{"created_at":"2025-04-15T23:30:00Z"}`), response)
+		},
+	}
+
+	got, err := resolveSinceIssue(mockClient, "octo/incidents#123")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "2025-04-15" {
+		t.Errorf("Expected 2025-04-15, got %s", got)
+	}
+
+	location = time.FixedZone("UTC+9", 9*60*60)
+	if got, _ := resolveSinceIssue(mockClient, "octo/incidents#123"); got != "2025-04-16" {
+		t.Errorf("Expected the date in --timezone, got %s", got)
+	}
+
+	if _, err := resolveSinceIssue(mockClient, "octo/incidents#999"); err == nil || !strings.Contains(err.Error(), "error fetching issue octo/incidents#999") {
+		t.Errorf("Expected fetch error, got %v", err)
+	}
+
+	for _, ref := range []string{"octo/incidents", "incidents#1", "octo/incidents#abc", "/repo#1"} {
+		if _, err := resolveSinceIssue(mockClient, ref); err == nil || !strings.Contains(err.Error(), "invalid --since issue reference") {
+			t.Errorf("Expected invalid reference error for %q, got %v", ref, err)
+		}
+	}
+}
+
+func TestBuildQueryWithTimezone(t *testing.T) {
+	resetFlags()
+
+	originalOrgConfigFunc := orgConfigFunc
+	orgConfigFunc = func() (string, error) {
+		return "github", nil
+	}
+	defer func() { orgConfigFunc = originalOrgConfigFunc }()
+
+	timezoneFlag = "Asia/Tokyo"
+	location, _ = time.LoadLocation(timezoneFlag)
+	since = "2025-04-15"
+	expected := "is%3Apr+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+created%3A%3E2025-04-15T00%3A00%3A00%2B09%3A00"
+	if actual := buildQuery("is:pr", "testuser"); actual != expected {
+		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	}
+}
+
+func TestResolvePeriod(t *testing.T) {
+	now := time.Date(2025, 5, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		period    string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{"this month", "this-month", "2025-05-01", "2025-05-15", false},
+		{"last month", "last-month", "2025-04-01", "2025-04-30", false},
+		{"this quarter", "this-quarter", "2025-04-01", "2025-05-15", false},
+		{"last quarter", "last-quarter", "2025-01-01", "2025-03-31", false},
+		{"this year", "this-year", "2025-01-01", "2025-05-15", false},
+		{"last year", "last-year", "2024-01-01", "2024-12-31", false},
+		{"unknown", "next-decade", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := resolvePeriod(tt.period, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolvePeriod(%q) error = %v, wantErr %v", tt.period, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := start.Format(dateFormat); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := end.Format(dateFormat); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
 
-	// Test default query includes since filter
-	expected := fmt.Sprintf("is%%3Apr+org%%3Agithub+author%%3Atestuser+sort%%3Acreated-desc+created%%3A%%3E%s", since)
-	actual := buildQuery("is:pr", testLogin)
-	if actual != expected {
-		t.Errorf("Expected query '%s', got '%s'", expected, actual)
+	// Last quarter from Q1 wraps into the previous year
+	start, end, _ := resolvePeriod("last-quarter", time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC))
+	if start.Format(dateFormat) != "2024-10-01" || end.Format(dateFormat) != "2024-12-31" {
+		t.Errorf("Expected 2024-10-01..2024-12-31, got %s..%s", start.Format(dateFormat), end.Format(dateFormat))
 	}
+}
 
-	// Test with custom since flag
-	since = "2025-01-15"
-	expectedSince := "is%3Aissue+org%3Agithub+author%3Atestuser+sort%3Acreated-desc+created%3A%3E2025-01-15"
-	actualSince := buildQuery("is:issue", testLogin)
-	if actualSince != expectedSince {
-		t.Errorf("Expected query '%s', got '%s'", expectedSince, actualSince)
+func TestPrintPeriods(t *testing.T) {
+	stdout, _ := captureOutput(func() {
+		printPeriods(time.Date(2025, 5, 15, 12, 0, 0, 0, time.UTC))
+	})
+
+	expected := "this-month    2025-05-01 .. 2025-05-15\n" +
+		"last-month    2025-04-01 .. 2025-04-30\n" +
+		"this-quarter  2025-04-01 .. 2025-05-15\n" +
+		"last-quarter  2025-01-01 .. 2025-03-31\n" +
+		"this-year     2025-01-01 .. 2025-05-15\n" +
+		"last-year     2024-01-01 .. 2024-12-31\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout:\n%s\nGot:\n%s", expected, stdout)
 	}
 }
 
@@ -752,6 +4112,69 @@ func TestGetModelFromConfig(t *testing.T) {
 	})
 }
 
+func TestReadExtensionConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	mockConfig := `extensions:
+  gh-contrib:
+    model: test-model
+    ignore_sections: [Testing]
+    contributor_types:
+      docs: doc
+    strict_org: true
+    score_weights:
+      pr: 3`
+	if err := os.WriteFile(configPath, []byte(mockConfig), 0644); err != nil {
+		t.Fatalf("Failed to write mock config file: %v", err)
+	}
+	t.Setenv("GH_CONFIG_PATH", configPath)
+
+	if got := getIgnoreSectionsFromConfig(); len(got) != 1 || got[0] != "Testing" {
+		t.Errorf("Expected ignore_sections [Testing], got %v", got)
+	}
+	if got := getContributorTypesFromConfig(); got["docs"] != "doc" {
+		t.Errorf("Expected contributor_types docs: doc, got %v", got)
+	}
+	if !getStrictOrgFromConfig() {
+		t.Error("Expected strict_org to be true")
+	}
+	if got := getScoreWeightsFromConfig(); got["pr"] != 3 {
+		t.Errorf("Expected score_weights pr: 3, got %v", got)
+	}
+
+	t.Setenv("GH_CONFIG_PATH", filepath.Join(t.TempDir(), "missing.yml"))
+	if got := readExtensionConfig(); got.StrictOrg || got.IgnoreSections != nil || got.ContributorTypes != nil || got.ScoreWeights != nil {
+		t.Errorf("Expected the zero value for a missing config file, got %+v", got)
+	}
+
+	t.Run("InvalidValueKeepsOtherKeys", func(t *testing.T) {
+		configWarning = sync.Once{}
+		defer func() { configWarning = sync.Once{} }()
+		configPath := filepath.Join(t.TempDir(), "config.yml")
+		mockConfig := `extensions:
+  gh-contrib:
+    strict_org: true
+    ignore_sections: [Testing]
+    score_weights:
+      review: two`
+		if err := os.WriteFile(configPath, []byte(mockConfig), 0644); err != nil {
+			t.Fatalf("Failed to write mock config file: %v", err)
+		}
+		t.Setenv("GH_CONFIG_PATH", configPath)
+
+		var got extensionConfig
+		_, stderr := captureOutput(func() {
+			got = readExtensionConfig()
+			readExtensionConfig()
+		})
+		if !got.StrictOrg || len(got.IgnoreSections) != 1 {
+			t.Errorf("Expected the valid keys to survive a bad score weight, got %+v", got)
+		}
+		if strings.Count(stderr, "Warning: ignoring invalid gh-contrib settings") != 1 || !strings.Contains(stderr, "two") {
+			t.Errorf("Expected one warning naming the bad value, got %q", stderr)
+		}
+	})
+}
+
 func TestGetEffectiveModel(t *testing.T) {
 	t.Run("ModelFlagOverridesConfig", func(t *testing.T) {
 		modelFlag = "test-model-flag"
@@ -793,6 +4216,159 @@ func TestGetEffectiveModel(t *testing.T) {
 	})
 }
 
+func TestGetEffectiveModel_AIModelFlag(t *testing.T) {
+	t.Run("AIModelFlagSetsModel", func(t *testing.T) {
+		aiModelFlag = "ai-model-flag"
+		defer func() { aiModelFlag = "" }()
+
+		if model := getEffectiveModel(); model != "ai-model-flag" {
+			t.Errorf("Expected model 'ai-model-flag', got '%s'", model)
+		}
+	})
+
+	t.Run("DeprecatedModelFlagSetsModel", func(t *testing.T) {
+		modelFlag = "model-flag"
+		defer func() { modelFlag = "" }()
+
+		if model := getEffectiveModel(); model != "model-flag" {
+			t.Errorf("Expected model 'model-flag', got '%s'", model)
+		}
+	})
+
+	t.Run("AIModelFlagWinsOverAlias", func(t *testing.T) {
+		aiModelFlag = "ai-model-flag"
+		modelFlag = "model-flag"
+		defer func() {
+			aiModelFlag = ""
+			modelFlag = ""
+		}()
+
+		if model := getEffectiveModel(); model != "ai-model-flag" {
+			t.Errorf("Expected model 'ai-model-flag', got '%s'", model)
+		}
+	})
+}
+
+func TestWarnDeprecatedFlags(t *testing.T) {
+	t.Run("ModelAliasWarns", func(t *testing.T) {
+		modelFlag = "model-flag"
+		defer func() { modelFlag = "" }()
+
+		var buf bytes.Buffer
+		warnDeprecatedFlags(&buf)
+		if !strings.Contains(buf.String(), "--model is deprecated") {
+			t.Errorf("Expected deprecation warning, got: %s", buf.String())
+		}
+	})
+
+	t.Run("AIModelFlagDoesNotWarn", func(t *testing.T) {
+		aiModelFlag = "ai-model-flag"
+		defer func() { aiModelFlag = "" }()
+
+		var buf bytes.Buffer
+		warnDeprecatedFlags(&buf)
+		if buf.Len() != 0 {
+			t.Errorf("Expected no warning, got: %s", buf.String())
+		}
+	})
+}
+
+func TestWarningWriters(t *testing.T) {
+	tests := []struct {
+		name             string
+		quiet            bool
+		suppressWarnings bool
+		wantRoutine      bool
+		wantData         bool
+	}{
+		{"Default", false, false, true, true},
+		{"Quiet", true, false, false, true},
+		{"SuppressWarnings", false, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags()
+			defer resetFlags()
+			quiet = tt.quiet
+			suppressWarnings = tt.suppressWarnings
+
+			if got := warningWriter() != io.Discard; got != tt.wantRoutine {
+				t.Errorf("routine warnings shown = %v, want %v", got, tt.wantRoutine)
+			}
+			if got := dataWarningWriter() != io.Discard; got != tt.wantData {
+				t.Errorf("data warnings shown = %v, want %v", got, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestLoadPromptFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	prompt, err := loadPromptFile(write("user.txt", "List the changes in:\n%s\n"), true)
+	if err != nil || prompt != "List the changes in:\n%s" {
+		t.Errorf("Expected trimmed prompt, got %q (err %v)", prompt, err)
+	}
+	if _, err := loadPromptFile(write("no-text.txt", "Summarize please"), true); err == nil {
+		t.Errorf("Expected an error for a user prompt without %%s")
+	}
+	if _, err := loadPromptFile(write("system.txt", "You are terse."), false); err != nil {
+		t.Errorf("Expected a system prompt without %%s to load, got %v", err)
+	}
+	if _, err := loadPromptFile(write("empty.txt", "\n"), false); err == nil {
+		t.Error("Expected an error for an empty prompt file")
+	}
+	if _, err := loadPromptFile(filepath.Join(dir, "missing.txt"), false); err == nil {
+		t.Error("Expected an error for a missing prompt file")
+	}
+}
+
+func TestBuildPrompt_Overrides(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	systemPromptOverride = "You are terse."
+	userPromptOverride = "Changes: %s (100% done)"
+
+	want := "System:\nYou are terse.\n\nUser:\nChanges: entry text (100% done)"
+	if got := BuildPrompt("entry text"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := BuildCombinedPrompt("entry text"); got != want {
+		t.Errorf("Expected the override for --combine too, got %q", got)
+	}
+}
+
+func TestWarnOverriddenPromptFlags(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	systemPromptOverride = "You are terse."
+	userPromptOverride = "Changes: %s"
+	summaryLengthFlag = "short"
+	summarizeFormat = "bullets"
+
+	_, stderr := captureOutput(func() { warnOverriddenPromptFlags(map[string]bool{}) })
+	if stderr != "" {
+		t.Errorf("Expected no warning for default flags, got: %s", stderr)
+	}
+
+	_, stderr = captureOutput(func() {
+		warnOverriddenPromptFlags(map[string]bool{"length": true, "summarize-format": true})
+	})
+	if !strings.Contains(stderr, "--user-prompt-file replaces the --length short wording") {
+		t.Errorf("Expected a --length warning, got: %s", stderr)
+	}
+	if !strings.Contains(stderr, "--system-prompt-file replaces the --summarize-format bullets instructions") {
+		t.Errorf("Expected a --summarize-format warning, got: %s", stderr)
+	}
+}
+
 func TestBuildPrompt(t *testing.T) {
 	text := "Some contribution text"
 	result := BuildPrompt(text)
@@ -801,7 +4377,7 @@ func TestBuildPrompt(t *testing.T) {
 	if !strings.Contains(result, "System:") {
 		t.Error("Expected prompt to contain 'System:' prefix")
 	}
-	if !strings.Contains(result, systemPrompt) {
+	if !strings.Contains(result, buildSystemPrompt()) {
 		t.Error("Expected prompt to contain the system prompt")
 	}
 
@@ -814,6 +4390,401 @@ func TestBuildPrompt(t *testing.T) {
 	}
 }
 
+func TestGetEffectiveIgnoreSections(t *testing.T) {
+	originalConfigFunc := ignoreSectionsConfigFunc
+	defer func() { ignoreSectionsConfigFunc = originalConfigFunc }()
+
+	t.Run("defaults when unset", func(t *testing.T) {
+		resetFlags()
+		ignoreSectionsConfigFunc = func() []string { return nil }
+		prompt := BuildPrompt("text")
+		if !strings.Contains(prompt, "'Mitigation and Rollback Strategies', 'Testing', 'Deployment Plan', 'Approval Responsibility'") {
+			t.Errorf("Expected default sections in prompt, got: %s", prompt)
+		}
+	})
+
+	t.Run("config overrides defaults", func(t *testing.T) {
+		resetFlags()
+		ignoreSectionsConfigFunc = func() []string { return []string{"Screenshots"} }
+		prompt := BuildPrompt("text")
+		if !strings.Contains(prompt, "such as 'Screenshots'.") || strings.Contains(prompt, "'Testing'") {
+			t.Errorf("Expected only configured sections in prompt, got: %s", prompt)
+		}
+	})
+
+	t.Run("flag overrides config", func(t *testing.T) {
+		resetFlags()
+		ignoreSectionsConfigFunc = func() []string { return []string{"Screenshots"} }
+		ignoreSections.Set("Checklist")
+		ignoreSections.Set("Rollout")
+		prompt := BuildPrompt("text")
+		if !strings.Contains(prompt, "(e.g., 'Checklist', 'Rollout')") || strings.Contains(prompt, "Screenshots") {
+			t.Errorf("Expected flag sections in prompt, got: %s", prompt)
+		}
+	})
+}
+
+func TestBuildPrompt_Length(t *testing.T) {
+	tests := []struct {
+		length string
+		phrase string
+	}{
+		{"short", "in one sentence"},
+		{"medium", "in a concise paragraph"},
+		{"long", "in a detailed paragraph"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.length, func(t *testing.T) {
+			resetFlags()
+			summaryLengthFlag = tt.length
+			if prompt := BuildPrompt("text"); !strings.Contains(prompt, "Summarize the following text "+tt.phrase+" per entry") {
+				t.Errorf("Expected prompt to contain %q, got: %s", tt.phrase, prompt)
+			}
+		})
+	}
+	if summaryLengths["short"].maxTokens >= summaryLengths["medium"].maxTokens ||
+		summaryLengths["medium"].maxTokens >= summaryLengths["long"].maxTokens {
+		t.Errorf("Expected token budgets to grow with length, got %+v", summaryLengths)
+	}
+}
+
+func TestHandleSummarizeCommand_Combine(t *testing.T) {
+	resetFlags()
+	combineFlag = true
+	mockSummarizer := &MockSummarizer{SummaryToReturn: "Combined summary"}
+	input := "First entry" + entryDelimiter + "Second entry" + entryDelimiter
+
+	stdout, stderr := captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", input}, mockSummarizer, false)
+	})
+
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+	if len(mockSummarizer.SummarizeCalls) != 0 {
+		t.Errorf("Expected no per-entry calls, got %d", len(mockSummarizer.SummarizeCalls))
+	}
+	if len(mockSummarizer.CombinedCalls) != 1 {
+		t.Fatalf("Expected 1 combined call, got %d", len(mockSummarizer.CombinedCalls))
+	}
+	if mockSummarizer.CombinedCalls[0] != "First entry"+combineSeparator+"Second entry" {
+		t.Errorf("Unexpected combined input: %q", mockSummarizer.CombinedCalls[0])
+	}
+	if strings.TrimSpace(stdout) != "Combined summary" {
+		t.Errorf("Expected a single summary, got: %s", stdout)
+	}
+}
+
+func TestHandleSummarizeCommand_BatchSize(t *testing.T) {
+	resetFlags()
+	batchSize = 2
+	mockSummarizer := &MockSummarizer{SummaryToReturn: "Batch summary"}
+	input := "First" + entryDelimiter + "Second" + entryDelimiter + "Third" + entryDelimiter
+
+	stdout, _ := captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", input}, mockSummarizer, false)
+	})
+
+	want := []string{"First" + combineSeparator + "Second", "Third"}
+	if len(mockSummarizer.SummarizeCalls) != len(want) {
+		t.Fatalf("Expected %d batched calls, got %d: %q", len(want), len(mockSummarizer.SummarizeCalls), mockSummarizer.SummarizeCalls)
+	}
+	for i, call := range mockSummarizer.SummarizeCalls {
+		if call != want[i] {
+			t.Errorf("Batch %d: expected %q, got %q", i, want[i], call)
+		}
+	}
+	if strings.Count(stdout, "Batch summary") != 2 {
+		t.Errorf("Expected one section per batch, got: %s", stdout)
+	}
+}
+
+func TestSummarizeBatch(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	batchSize = 2
+	preserveLinksFlag = true
+	batch := []string{"Entry one https://example.com/1", "Entry two https://example.com/2"}
+
+	if prompt := buildUserPrompt(strings.Join(batch, combineSeparator)); !strings.Contains(prompt, "Summarize each entry separately") || !strings.Contains(prompt, batchSummaryDelimiter) {
+		t.Errorf("Expected a per-entry batch prompt with the delimiter, got: %s", prompt)
+	}
+
+	mockSummarizer := &MockSummarizer{SummaryToReturn: "## One\nFirst https://example.com/1\n" + batchSummaryDelimiter + "\n## Two\nSecond\n" + batchSummaryDelimiter + "\n"}
+	summaries, err := summarizeBatch(mockSummarizer, batch)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"## One\nFirst https://example.com/1", "## Two\nSecond\n\n### Links\n- https://example.com/2"}
+	if strings.Join(summaries, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected one summary per entry with its own links:\n%q\ngot:\n%q", want, summaries)
+	}
+
+	// An answer that doesn't split into one section per entry stays whole
+	mockSummarizer.SummaryToReturn = "## Both\nMerged https://example.com/1 https://example.com/2"
+	if summaries, _ := summarizeBatch(mockSummarizer, batch); len(summaries) != 1 || summaries[0] != mockSummarizer.SummaryToReturn {
+		t.Errorf("Expected the whole answer as one section, got %q", summaries)
+	}
+
+	// JSON answers are one array per batch and already hold one object per entry
+	summarizeFormat = "json"
+	if prompt := buildUserPrompt("x"); strings.Contains(prompt, batchSummaryDelimiter) {
+		t.Errorf("Expected no delimiter in the JSON batch prompt, got: %s", prompt)
+	}
+}
+
+func TestBatchEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		size    int
+		budget  int
+		want    int
+	}{
+		{"one per batch", []string{"a", "b", "c"}, 1, 100, 3},
+		{"full batches", []string{"a", "b", "c", "d"}, 2, 100, 2},
+		{"partial last batch", []string{"a", "b", "c"}, 2, 100, 2},
+		{"budget splits early", []string{"aaaa", "bbbb", "cccc"}, 3, 10, 3},
+		{"oversized entry alone", []string{strings.Repeat("x", 20), "b"}, 2, 10, 2},
+		{"empty", nil, 2, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := batchEntries(tt.entries, tt.size, tt.budget); len(got) != tt.want {
+				t.Errorf("Expected %d batches, got %d: %q", tt.want, len(got), got)
+			}
+		})
+	}
+}
+
+func TestPreserveLinks(t *testing.T) {
+	source := "Fixes https://github.com/octo/repo/issues/1. See [the PR](https://github.com/octo/repo/pull/2), " +
+		"and again https://github.com/octo/repo/issues/1 or <https://example.com/docs?a=1&b=2>"
+
+	if got := extractURLs(source); strings.Join(got, " ") != "https://github.com/octo/repo/issues/1 https://github.com/octo/repo/pull/2 https://example.com/docs?a=1&b=2" {
+		t.Errorf("Unexpected URLs: %q", got)
+	}
+
+	summary := "Fixed the bug (https://github.com/octo/repo/pull/2).\n"
+	expected := "Fixed the bug (https://github.com/octo/repo/pull/2).\n\n### Links\n" +
+		"- https://github.com/octo/repo/issues/1\n" +
+		"- https://example.com/docs?a=1&b=2"
+	if got := preserveLinks(summary, source); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	complete := "All of https://github.com/octo/repo/issues/1 https://github.com/octo/repo/pull/2 https://example.com/docs?a=1&b=2"
+	if got := preserveLinks(complete, source); got != complete {
+		t.Errorf("Expected a summary with every link to be unchanged, got:\n%s", got)
+	}
+	// A longer URL with the same prefix doesn't count as the shorter one
+	if got := preserveLinks("See https://github.com/octo/repo/pull/23", "https://github.com/octo/repo/pull/2"); got != "See https://github.com/octo/repo/pull/23\n\n### Links\n- https://github.com/octo/repo/pull/2" {
+		t.Errorf("Expected pull/2 to be added despite pull/23, got:\n%s", got)
+	}
+
+	// Missing links join the summary's own Links section instead of a second one
+	withLinks := "## Fix\nDone.\n\n### Links\n  - [PR](https://github.com/octo/repo/pull/2)\n\n  <br /><br />\n\n## Next\nMore."
+	expected = "## Fix\nDone.\n\n### Links\n  - [PR](https://github.com/octo/repo/pull/2)\n" +
+		"  - https://github.com/octo/repo/issues/1\n  - https://example.com/docs?a=1&b=2\n\n  <br /><br />\n\n## Next\nMore."
+	if got := preserveLinks(withLinks, source); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestHandleSummarizeCommand_PreserveLinks(t *testing.T) {
+	resetFlags()
+	preserveLinksFlag = true
+	mockSummarizer := &MockSummarizer{SummaryToReturn: "Shipped the fix"}
+
+	stdout, _ := captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", "Fix in https://github.com/octo/repo/pull/7"}, mockSummarizer, false)
+	})
+
+	if stdout != "Shipped the fix\n\n### Links\n- https://github.com/octo/repo/pull/7\n" {
+		t.Errorf("Expected the dropped link to be appended, got: %q", stdout)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	text := "See https://wiki.corp.example.com/Runbook, https://docs.corp.example.com/x and https://pastebin.com/abc. " +
+		"Ask alice@corp.example.com or bob@gmail.com; notcorp.example.com.evil.io/x is not a link."
+
+	if got := redact(text); got != text {
+		t.Errorf("Expected text unchanged without --redact, got %q", got)
+	}
+
+	redactFlag = true
+	redactAllow = stringSliceFlag{"corp.example.com"}
+	expected := "See https://wiki.corp.example.com/Runbook, https://docs.corp.example.com/x and [redacted link]. " +
+		"Ask alice@corp.example.com or [redacted email]; notcorp.example.com.evil.io/x is not a link."
+	if got := redact(text); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// A lookalike domain that only ends with the allowed name is redacted
+	if got := redact("https://evilcorp.example.com.attacker.io/a https://notcorp.example.com/b"); got != "[redacted link] [redacted link]" {
+		t.Errorf("Expected lookalike domains to be redacted, got %q", got)
+	}
+
+	redactAllow = nil
+	if got := redact("mail carol@corp.example.com about https://wiki.corp.example.com/a"); got != "mail [redacted email] about [redacted link]" {
+		t.Errorf("Expected everything redacted without an allowlist, got %q", got)
+	}
+}
+
+func TestRedact_BodyAndSummaryOutput(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	redactFlag = true
+	redactAllow = stringSliceFlag{"wiki.internal"}
+
+	stdout, _ := captureOutput(func() {
+		printBodies([]GitHubItem{{Number: 3, Title: "Rollout", Body: "Plan at https://wiki.internal/plan, logs at https://logs.vendor.io/run/9"}}, "<PR>", "</PR>")
+	})
+	if !strings.Contains(stdout, "Plan at https://wiki.internal/plan, logs at [redacted link]\n") {
+		t.Errorf("Expected the body to be redacted, got %q", stdout)
+	}
+
+	mockSummarizer := &MockSummarizer{SummaryToReturn: "Documented in https://wiki.internal/plan; contact ops@vendor.io"}
+	stdout, _ = captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", "Rollout"}, mockSummarizer, false)
+	})
+	if stdout != "Documented in https://wiki.internal/plan; contact [redacted email]\n" {
+		t.Errorf("Expected the summary to be redacted, got %q", stdout)
+	}
+}
+
+func TestApplyTitleTemplate(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	titleTemplate = "#{number} {title}"
+
+	entries := []string{
+		markers.prStart + "\nFix login #42\nBody\n" + markers.prEnd,
+		markers.issueStart + "\nCrash on start #7\nFixes #99\n" + markers.issueEnd,
+		"Plain text without markers #5",
+	}
+	numbers := entryNumbers(entries)
+	if strings.Join(numbers, ",") != "42,7," {
+		t.Fatalf("Expected numbers 42,7 and none, got %q", numbers)
+	}
+
+	summary := "## Login fix\nFixed it.\n\n## Startup crash\nFixed that.\n\n## Misc\nOther."
+	expected := "## #42 Login fix\nFixed it.\n\n## #7 Startup crash\nFixed that.\n\n## Misc\nOther."
+	if got := applyTitleTemplate(summary, numbers); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// Titles can't be matched to entries when the counts differ
+	if got := applyTitleTemplate("## Everything\nAll of it.", numbers); got != "## Everything\nAll of it." {
+		t.Errorf("Expected the summary unchanged, got %q", got)
+	}
+
+	summarizeFormat = "bullets"
+	titleTemplate = "{title} (#{number})"
+	if got := applyTitleTemplate("- **Login fix**: Fixed it.\n  - https://example.com", []string{"42"}); got != "- **Login fix (#42)**: Fixed it.\n  - https://example.com" {
+		t.Errorf("Expected the bullet title rewritten, got %q", got)
+	}
+}
+
+func TestHandleSummarizeCommand_TitleTemplate(t *testing.T) {
+	resetFlags()
+	titleTemplate = "#{number} {title}"
+	mockSummarizer := &MockSummarizer{SummaryToReturn: "## Login fix\nFixed it."}
+
+	input := markers.prStart + "\nFix login #42\nBody\n" + markers.prEnd + "\n" + markers.entry + "\n" +
+		"No markers here\n" + markers.entry
+	stdout, _ := captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", input}, mockSummarizer, false)
+	})
+
+	expected := "## #42 Login fix\nFixed it.\n## Login fix\nFixed it.\n"
+	if stdout != expected {
+		t.Errorf("Expected stdout %q, got %q", expected, stdout)
+	}
+}
+
+// delayedSummarizer answers each entry after a delay taken from the entry,
+// so later entries can finish first.
+type delayedSummarizer struct {
+	MockSummarizer
+	delays map[string]time.Duration
+}
+
+func (d *delayedSummarizer) Summarize(text string) (string, error) {
+	time.Sleep(d.delays[text])
+	if text == "Broken" {
+		return "", errors.New("boom")
+	}
+	return "Summary of " + text, nil
+}
+
+func TestHandleSummarizeCommand_Parallel(t *testing.T) {
+	resetFlags()
+	summarizeParallel = 3
+	summarizer := &delayedSummarizer{delays: map[string]time.Duration{"First": 60 * time.Millisecond, "Broken": 30 * time.Millisecond}}
+	input := "First" + entryDelimiter + "Broken" + entryDelimiter + "Third" + entryDelimiter
+
+	stdout, stderr := captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", input}, summarizer, false)
+	})
+
+	// Third finishes first, but the output keeps the input order
+	if stdout != "Summary of First\nSummary of Third\n" {
+		t.Errorf("Expected summaries in input order, got: %q", stdout)
+	}
+	if !strings.Contains(stderr, "Error summarizing entry: boom") {
+		t.Errorf("Expected the failed entry to be reported, got: %q", stderr)
+	}
+}
+
+func TestHandleSummarizeCommand_JSONStream(t *testing.T) {
+	resetFlags()
+	jsonStream = true
+	input := "First entry" + entryDelimiter + "Second entry"
+
+	stdout, stderr := captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", input}, &MockSummarizer{SummaryToReturn: "A summary"}, false)
+	})
+	if stderr != "" {
+		t.Errorf("Expected no stderr, got: %s", stderr)
+	}
+	expected := `{"index":0,"summary":"A summary"}` + "\n" + `{"index":1,"summary":"A summary"}` + "\n"
+	if stdout != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, stdout)
+	}
+
+	stdout, _ = captureOutput(func() {
+		handleSummarizeCommand([]string{"summarize", "Only entry"}, &MockSummarizer{ErrorToReturn: fmt.Errorf("rate limited")}, false)
+	})
+	if expected := `{"index":0,"error":"rate limited"}` + "\n"; stdout != expected {
+		t.Errorf("Expected error line %s, got: %s", expected, stdout)
+	}
+}
+
+func TestSummarizeCombined_MapReduceOverBudget(t *testing.T) {
+	resetFlags()
+	mockSummarizer := &MockSummarizer{SummaryToReturn: "partial"}
+	big := strings.Repeat("x", combineCharBudget/2+1)
+
+	summary, err := summarizeCombined(mockSummarizer, []string{big, big, "small"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary != "partial" {
+		t.Errorf("Expected reduced summary, got %q", summary)
+	}
+	// Two chunks (big, big+small) plus one reduce call
+	if len(mockSummarizer.CombinedCalls) != 3 {
+		t.Fatalf("Expected 3 combined calls, got %d", len(mockSummarizer.CombinedCalls))
+	}
+	if reduce := mockSummarizer.CombinedCalls[2]; reduce != "partial"+combineSeparator+"partial" {
+		t.Errorf("Expected reduce call over partial summaries, got %q", reduce)
+	}
+}
+
 func TestHandleSummarizeCommand_PromptOnly(t *testing.T) {
 	resetFlags()
 	mockSummarizer := &MockSummarizer{
@@ -842,7 +4813,7 @@ func TestHandleSummarizeCommand_PromptOnly(t *testing.T) {
 	if !strings.Contains(stdout, "System:") {
 		t.Error("Expected stdout to contain 'System:' prefix")
 	}
-	if !strings.Contains(stdout, systemPrompt) {
+	if !strings.Contains(stdout, buildSystemPrompt()) {
 		t.Error("Expected stdout to contain the system prompt")
 	}
 	if !strings.Contains(stdout, "User:") {
@@ -911,15 +4882,7 @@ func TestHandleDiscussionsCommand_CSV(t *testing.T) {
 
 	mockGQLClient.DoFunc = func(query string, variables map[string]interface{}, response interface{}) error {
 		resp := response.(*DiscussionSearchResponse)
-		resp.Search.Nodes = []struct {
-			Title     string `json:"title"`
-			URL       string `json:"url"`
-			Body      string `json:"body"`
-			Number    int    `json:"number"`
-			CreatedAt string `json:"createdAt"`
-			ClosedAt  string `json:"closedAt"`
-			Closed    bool   `json:"closed"`
-		}{
+		resp.Search.Nodes = []discussionNode{
 			{Title: "Test Discussion", URL: "http://example.com/discussion/1", Number: 1, Closed: false, CreatedAt: "2025-01-20T00:00:00Z"},
 		}
 		resp.Search.PageInfo.HasNextPage = false
@@ -948,6 +4911,26 @@ func TestHandleDiscussionsCommand_CSV(t *testing.T) {
 	}
 }
 
+func TestHandleDiscussionsCommand_Export(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	exportPreset, outputFormat, normalizeURLs = true, "tsv", true
+	mockGQLClient := &MockGraphQLClient{DoFunc: func(query string, variables map[string]interface{}, response interface{}) error {
+		resp := response.(*DiscussionSearchResponse)
+		resp.Search.Nodes = []discussionNode{
+			{Title: "Roadmap", URL: "https://github.com/o/r/discussions/3", Number: 3, CreatedAt: "2025-01-20T00:00:00Z"},
+		}
+		return nil
+	}}
+
+	stdout, _ := captureOutput(func() {
+		handleDiscussionsCommand([]string{"discussions", "testuser"}, &MockGitHubClient{}, mockGQLClient)
+	})
+	if !strings.Contains(stdout, "o/r\t3\tDiscussion\topen\tRoadmap\t") {
+		t.Errorf("Expected the discussion typed as Discussion, got %q", stdout)
+	}
+}
+
 func TestHandleDiscussionsCommand_BodyOnly(t *testing.T) {
 	resetFlags()
 	bodyOnly = true
@@ -958,15 +4941,7 @@ func TestHandleDiscussionsCommand_BodyOnly(t *testing.T) {
 
 	mockGQLClient.DoFunc = func(query string, variables map[string]interface{}, response interface{}) error {
 		resp := response.(*DiscussionSearchResponse)
-		resp.Search.Nodes = []struct {
-			Title     string `json:"title"`
-			URL       string `json:"url"`
-			Body      string `json:"body"`
-			Number    int    `json:"number"`
-			CreatedAt string `json:"createdAt"`
-			ClosedAt  string `json:"closedAt"`
-			Closed    bool   `json:"closed"`
-		}{
+		resp.Search.Nodes = []discussionNode{
 			{Title: "Test Discussion", URL: "http://example.com/discussion/1", Body: "Discussion body.", Number: 1, Closed: false, CreatedAt: "2025-01-20T00:00:00Z"},
 		}
 		resp.Search.PageInfo.HasNextPage = false
@@ -994,15 +4969,7 @@ func TestFetchDiscussions(t *testing.T) {
 
 	mockGQLClient.DoFunc = func(query string, variables map[string]interface{}, response interface{}) error {
 		resp := response.(*DiscussionSearchResponse)
-		resp.Search.Nodes = []struct {
-			Title     string `json:"title"`
-			URL       string `json:"url"`
-			Body      string `json:"body"`
-			Number    int    `json:"number"`
-			CreatedAt string `json:"createdAt"`
-			ClosedAt  string `json:"closedAt"`
-			Closed    bool   `json:"closed"`
-		}{
+		resp.Search.Nodes = []discussionNode{
 			{Title: "Open Discussion", URL: "http://example.com/d/1", Number: 1, Closed: false, CreatedAt: "2025-01-20T00:00:00Z"},
 			{Title: "Closed Discussion", URL: "http://example.com/d/2", Number: 2, Closed: true, CreatedAt: "2025-01-15T00:00:00Z", ClosedAt: "2025-01-18T00:00:00Z"},
 		}